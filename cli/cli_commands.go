@@ -0,0 +1,345 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// TÉLÉCHARGEMENTS
+// ============================================
+
+// errUnknownVideo signale un argument absent du catalogue
+var errUnknownVideo = fmt.Errorf("vidéo absente du catalogue: %w", shared.ErrNotFound)
+
+// findVideo cherche une vidéo du catalogue par ID ou par nom de fichier
+func findVideo(view *shared.CatalogView, arg string) *shared.CatalogEntry {
+	for i, entry := range view.Videos {
+		if entry.ID == arg || entry.Filename == arg {
+			return &view.Videos[i]
+		}
+	}
+	return nil
+}
+
+// resolveFilename accepte un ID du catalogue à la place d'un nom de fichier.
+// Sans catalogue (daemon hors ligne...), l'argument est pris tel quel.
+func (app *App) resolveFilename(arg string) string {
+	view, err := app.daemon.Catalog(app.ctx)
+	if err != nil {
+		return arg
+	}
+	if entry := findVideo(view, arg); entry != nil {
+		return entry.Filename
+	}
+	return arg
+}
+
+// downloadResult est la sortie JSON de download
+type downloadResult struct {
+	ID       string                 `json:"id"`
+	Filename string                 `json:"filename"`
+	Title    string                 `json:"title"`
+	Cached   bool                   `json:"cached"`             // déjà en cache: rien à télécharger
+	Download *shared.DownloadStatus `json:"download,omitempty"` // état après la mise en file
+}
+
+func (app *App) cmdDownload(fs *flag.FlagSet, args []string) error {
+	priority := fs.Int("priority", 0, "priorité (la plus haute part en premier)")
+	args, err := app.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	view, err := app.daemon.Catalog(app.ctx)
+	if err != nil {
+		return err
+	}
+	entry := findVideo(view, args[0])
+	if entry == nil {
+		return fmt.Errorf("%s: %w", args[0], errUnknownVideo)
+	}
+
+	if err := app.daemon.Download(app.ctx, entry.Filename, *priority); err != nil {
+		return err
+	}
+
+	result := downloadResult{ID: entry.ID, Filename: entry.Filename, Title: entry.Title, Cached: entry.Cached}
+	status, err := app.daemon.DownloadStatus(app.ctx, entry.Filename)
+	switch {
+	case errors.Is(err, shared.ErrNotFound):
+		result.Cached = true // déjà en cache: le daemon le seede sans créer de téléchargement
+	case err != nil:
+		return err
+	default:
+		result.Download = status
+	}
+
+	if app.json {
+		return app.printJSON(result)
+	}
+	if result.Download == nil {
+		fmt.Fprintf(app.stdout, "%s (%s) est déjà en cache\n", entry.Title, entry.Filename)
+		return nil
+	}
+	fmt.Fprintf(app.stdout, "%s (%s): %s, priorité %d\n", entry.Title, entry.Filename,
+		downloadState(*result.Download), result.Download.Priority)
+	return nil
+}
+
+func (app *App) cmdStatus(fs *flag.FlagSet, args []string) error {
+	watch := fs.Bool("watch", false, "suivre en direct (Ctrl-C pour quitter); avec un fichier, s'arrête à la fin du téléchargement")
+	args, err := app.parse(fs, args, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	filename := ""
+	if len(args) == 1 {
+		filename = app.resolveFilename(args[0])
+	}
+	if *watch {
+		return app.watchDownloads(filename)
+	}
+
+	if filename != "" {
+		status, err := app.daemon.DownloadStatus(app.ctx, filename)
+		if err != nil {
+			return err
+		}
+		if app.json {
+			return app.printJSON(status)
+		}
+		app.printDownloads([]shared.DownloadStatus{*status})
+		return nil
+	}
+
+	list, err := app.daemon.Downloads(app.ctx)
+	if err != nil {
+		return err
+	}
+	if app.json {
+		return app.printJSON(list)
+	}
+	if len(list.Downloads) == 0 {
+		fmt.Fprintln(app.stdout, "Aucun téléchargement")
+		return nil
+	}
+	app.printDownloads(list.Downloads)
+	fmt.Fprintf(app.stdout, "\n%d téléchargement(s) simultané(s) au plus\n", list.MaxConcurrent)
+	return nil
+}
+
+// printDownloads affiche un tableau de téléchargements
+func (app *App) printDownloads(downloads []shared.DownloadStatus) {
+	table := app.table()
+	fmt.Fprintln(table, "FICHIER\tÉTAT\tPRIO\tPROGRESSION\tTAILLE\tDÉBIT\tPEERS")
+	for _, ds := range downloads {
+		fmt.Fprintf(table, "%s\t%s\t%d\t%s\t%s\t%s\t%d\n", ds.Filename, downloadState(ds), ds.Priority,
+			progressBar(ds.Progress, 20), formatBytes(ds.TotalBytes), formatRate(ds.DownloadSpeed*1024), ds.PeersConnected)
+	}
+	table.Flush()
+}
+
+// downloadAction applique pause ou resume et affiche l'état obtenu
+func (app *App) downloadAction(fs *flag.FlagSet, args []string,
+	action func(filename string) (*shared.DownloadStatus, error), done string) error {
+	args, err := app.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	status, err := action(app.resolveFilename(args[0]))
+	if err != nil {
+		return err
+	}
+	if app.json {
+		return app.printJSON(status)
+	}
+	fmt.Fprintf(app.stdout, "%s: %s (%s)\n", status.Filename, done, downloadState(*status))
+	return nil
+}
+
+func (app *App) cmdPause(fs *flag.FlagSet, args []string) error {
+	return app.downloadAction(fs, args, func(filename string) (*shared.DownloadStatus, error) {
+		return app.daemon.Pause(app.ctx, filename)
+	}, "en pause")
+}
+
+func (app *App) cmdResume(fs *flag.FlagSet, args []string) error {
+	return app.downloadAction(fs, args, func(filename string) (*shared.DownloadStatus, error) {
+		return app.daemon.Resume(app.ctx, filename)
+	}, "repris")
+}
+
+func (app *App) cmdRemove(fs *flag.FlagSet, args []string) error {
+	args, err := app.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	filename := app.resolveFilename(args[0])
+	if err := app.daemon.Cancel(app.ctx, filename); err != nil {
+		return err
+	}
+	if app.json {
+		return app.printJSON(map[string]interface{}{"filename": filename, "cancelled": true})
+	}
+	fmt.Fprintf(app.stdout, "%s: téléchargement annulé\n", filename)
+	return nil
+}
+
+// ============================================
+// CATALOGUE ET CACHE
+// ============================================
+
+func (app *App) cmdList(fs *flag.FlagSet, args []string) error {
+	cachedOnly := fs.Bool("cached", false, "seulement les vidéos en cache")
+	if _, err := app.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	view, err := app.daemon.Catalog(app.ctx)
+	if err != nil {
+		return err
+	}
+	downloads, err := app.daemon.Downloads(app.ctx)
+	if err != nil {
+		return err
+	}
+	byFile := make(map[string]shared.DownloadStatus, len(downloads.Downloads))
+	for _, ds := range downloads.Downloads {
+		byFile[ds.Filename] = ds
+	}
+
+	if *cachedOnly {
+		videos := view.Videos[:0]
+		for _, entry := range view.Videos {
+			if entry.Cached {
+				videos = append(videos, entry)
+			}
+		}
+		view.Videos = videos
+	}
+	if app.json {
+		return app.printJSON(view)
+	}
+
+	if !view.Online {
+		fmt.Fprintf(app.stdout, "Serveur injoignable: catalogue limité au cache (synchronisé le %s)\n\n",
+			view.SyncedAt.Local().Format("02/01/2006 15:04"))
+	}
+	if len(view.Videos) == 0 {
+		fmt.Fprintln(app.stdout, "Aucune vidéo")
+		return nil
+	}
+
+	table := app.table()
+	fmt.Fprintln(table, "ID\tTITRE\tDURÉE\tTAILLE\tLOCAL")
+	for _, entry := range view.Videos {
+		local := "-"
+		switch ds, ok := byFile[entry.Filename]; {
+		case entry.Cached:
+			local = "en cache"
+		case ok:
+			local = fmt.Sprintf("%s %.0f %%", downloadState(ds), ds.Progress)
+		case entry.Status == shared.VideoProcessing:
+			local = "en préparation"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", entry.ID, entry.Title,
+			formatDuration(entry.Duration), formatBytes(entry.Size), local)
+	}
+	table.Flush()
+	return nil
+}
+
+func (app *App) cmdPin(fs *flag.FlagSet, args []string) error {
+	off := fs.Bool("off", false, "libérer le fichier (il redevient évinçable)")
+	args, err := app.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	filename := app.resolveFilename(args[0])
+	view, err := app.daemon.Pin(app.ctx, filename, !*off)
+	if err != nil {
+		return err
+	}
+
+	var file *shared.CacheFileInfo
+	for i := range view.Files {
+		if view.Files[i].Filename == filename {
+			file = &view.Files[i]
+		}
+	}
+	if app.json {
+		return app.printJSON(file)
+	}
+	if *off {
+		fmt.Fprintf(app.stdout, "%s: libéré\n", filename)
+	} else {
+		fmt.Fprintf(app.stdout, "%s: épinglé\n", filename)
+	}
+	return nil
+}
+
+// ============================================
+// P2P
+// ============================================
+
+func (app *App) cmdPeers(fs *flag.FlagSet, args []string) error {
+	if _, err := app.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	view, err := app.daemon.Peers(app.ctx)
+	if err != nil {
+		return err
+	}
+	if app.json {
+		return app.printJSON(view)
+	}
+	if len(view.Peers) == 0 {
+		fmt.Fprintln(app.stdout, "Aucun peer connu")
+		return nil
+	}
+
+	sort.Slice(view.Peers, func(i, j int) bool { return view.Peers[i].DownloadRate > view.Peers[j].DownloadRate })
+	table := app.table()
+	fmt.Fprintln(table, "PEER\tDÉBLOQUÉ\tOPTIMISTE\tINTÉRESSÉ\tREÇU\tENVOYÉ")
+	for _, p := range view.Peers {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n", shortPeerID(p.ID), yesNo(p.Unchoked), yesNo(p.Optimistic),
+			yesNo(p.Interested), formatRate(p.DownloadRate), formatRate(p.UploadRate))
+	}
+	table.Flush()
+	fmt.Fprintf(app.stdout, "\n%d slot(s) de déblocage\n", view.UnchokeSlots)
+	return nil
+}
+
+func (app *App) cmdStats(fs *flag.FlagSet, args []string) error {
+	if _, err := app.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	stats, err := app.daemon.Stats(app.ctx)
+	if err != nil {
+		return err
+	}
+	if app.json {
+		return app.printJSON(stats)
+	}
+
+	table := app.table()
+	fmt.Fprintf(table, "Peer ID\t%s\n", stats.PeerID)
+	fmt.Fprintf(table, "Peers connectés\t%d (%d débloqués)\n", stats.ConnectedPeers, stats.UnchokedPeers)
+	fmt.Fprintf(table, "Téléchargements\t%d en cours, %d en file (max %d)\n",
+		stats.DownloadingFiles, stats.QueuedFiles, stats.MaxConcurrentDownloads)
+	fmt.Fprintf(table, "Fichiers seedés\t%d\n", stats.SeedingFiles)
+	fmt.Fprintf(table, "Fichiers en cache\t%d\n", stats.CacheFiles)
+	table.Flush()
+	return nil
+}
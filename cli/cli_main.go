@@ -0,0 +1,244 @@
+// Commande pipbingo: pilote le daemon P2P local depuis un terminal ou un script,
+// à travers son API HTTP (http://localhost:9090 par défaut).
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// CODES DE SORTIE
+// ============================================
+
+// Codes de sortie, stables pour les scripts
+const (
+	ExitOK          = 0
+	ExitError       = 1 // erreur inattendue
+	ExitUsage       = 2 // commande, option ou argument invalide
+	ExitNotFound    = 3 // vidéo, téléchargement ou fichier introuvable
+	ExitConflict    = 4 // action impossible dans l'état courant (déjà en pause...)
+	ExitUnavailable = 5 // daemon injoignable ou pas prêt
+	ExitFailed      = 6 // status --watch: le téléchargement suivi a échoué ou a été annulé
+)
+
+const DefaultDaemonURL = "http://localhost:9090"
+
+// usageError signale une ligne de commande invalide
+type usageError struct{ msg string }
+
+func (e *usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// exitError termine la commande avec un code choisi, sans autre message que le sien
+type exitError struct {
+	code int
+	msg  string
+}
+
+func (e *exitError) Error() string { return e.msg }
+
+// exitCode traduit une erreur en code de sortie
+func exitCode(err error) int {
+	var usage *usageError
+	var exit *exitError
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &exit):
+		return exit.code
+	case errors.As(err, &usage):
+		return ExitUsage
+	case errors.Is(err, shared.ErrNotFound):
+		return ExitNotFound
+	case errors.Is(err, shared.ErrConflict):
+		return ExitConflict
+	case errors.Is(err, shared.ErrUnavailable), errors.As(err, &netErr), errors.Is(err, syscall.ECONNREFUSED):
+		return ExitUnavailable
+	}
+	return ExitError
+}
+
+// ============================================
+// COMMANDES
+// ============================================
+
+// command est une sous-commande: ses options sont déclarées sur fs, args reçoit le reste
+type command struct {
+	name    string
+	args    string // arguments attendus, pour l'aide
+	summary string
+	run     func(app *App, fs *flag.FlagSet, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"download", "[-priority N] <id|fichier>", "Télécharger une vidéo du catalogue", (*App).cmdDownload},
+		{"ls", "[-cached]", "Lister le catalogue et l'état local de chaque vidéo", (*App).cmdList},
+		{"status", "[-watch] [fichier]", "Afficher les téléchargements (-watch: tableau en direct)", (*App).cmdStatus},
+		{"pause", "<fichier>", "Mettre un téléchargement en pause", (*App).cmdPause},
+		{"resume", "<fichier>", "Reprendre un téléchargement en pause ou en erreur", (*App).cmdResume},
+		{"rm", "<fichier>", "Annuler un téléchargement et retirer son entrée", (*App).cmdRemove},
+		{"pin", "[-off] <fichier>", "Épingler un fichier du cache (-off: le libérer)", (*App).cmdPin},
+		{"peers", "", "Afficher l'état de choke des peers", (*App).cmdPeers},
+		{"stats", "", "Afficher les statistiques P2P", (*App).cmdStats},
+	}
+}
+
+// App porte les options globales et le client du daemon
+type App struct {
+	ctx    context.Context
+	global *globalFlags
+	daemon *shared.DaemonClient
+	json   bool
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// globalFlags déclare les options communes à toutes les commandes
+type globalFlags struct {
+	daemonURL string
+	json      bool
+	timeout   time.Duration
+}
+
+func (g *globalFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.daemonURL, "daemon", g.daemonURL, "URL de l'API du daemon (PIPBINGO_DAEMON)")
+	fs.BoolVar(&g.json, "json", g.json, "sortie JSON, pour les scripts")
+	fs.DurationVar(&g.timeout, "timeout", g.timeout, "durée max d'une requête au daemon")
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		var exit *exitError
+		if !errors.As(err, &exit) || exit.msg != "" {
+			fmt.Fprintf(os.Stderr, "pipbingo: %v\n", err)
+		}
+		os.Exit(exitCode(err))
+	}
+}
+
+// run analyse la ligne de commande: options globales, commande, options de la commande
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	global := globalFlags{daemonURL: DefaultDaemonURL, timeout: shared.DefaultRequestTimeout}
+	if url := os.Getenv("PIPBINGO_DAEMON"); url != "" {
+		global.daemonURL = url
+	}
+
+	fs := flag.NewFlagSet("pipbingo", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	global.register(fs)
+	fs.Usage = func() { printUsage(stderr, fs) }
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return usagef("commande manquante")
+	}
+
+	name := fs.Arg(0)
+	if name == "help" {
+		fs.Usage()
+		return nil
+	}
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == name {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		return usagef("commande inconnue %q (pipbingo help)", name)
+	}
+
+	// Les options globales sont aussi acceptées après la commande (pipbingo status -json):
+	// déclarées à nouveau, avec pour défaut ce qui a été lu avant la commande
+	cmdFlags := flag.NewFlagSet("pipbingo "+cmd.name, flag.ContinueOnError)
+	cmdFlags.SetOutput(stderr)
+	global.register(cmdFlags)
+	cmdFlags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: pipbingo %s %s\n\n%s\n\nOptions:\n", cmd.name, cmd.args, cmd.summary)
+		cmdFlags.PrintDefaults()
+	}
+
+	app := &App{ctx: ctx, global: &global, stdout: stdout, stderr: stderr}
+	err := cmd.run(app, cmdFlags, fs.Args()[1:])
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		err = fmt.Errorf("daemon injoignable à %s (pipbingo-daemon est-il lancé ?): %w", global.daemonURL, err)
+	}
+	if err != nil && app.json && !errors.Is(err, flag.ErrHelp) {
+		// Mode script: l'erreur aussi est en JSON, le code de sortie est conservé
+		writeJSONError(stderr, err)
+		return &exitError{code: exitCode(err)}
+	}
+	return err
+}
+
+// parse analyse les options d'une commande puis crée le client du daemon
+func (app *App) parse(fs *flag.FlagSet, args []string, minArgs, maxArgs int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() < minArgs || fs.NArg() > maxArgs {
+		fs.Usage()
+		return nil, usagef("nombre d'arguments invalide")
+	}
+
+	app.json = app.global.json
+	app.daemon = shared.NewDaemonClient(app.global.daemonURL)
+	app.daemon.Timeout = app.global.timeout
+	app.daemon.Retry = shared.NoRetry // un script préfère un échec immédiat et un code de sortie
+	app.daemon.Language = language()
+	return fs.Args(), nil
+}
+
+// language choisit la langue des messages d'erreur du daemon d'après la locale
+func language() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			if strings.HasPrefix(value, "en") {
+				return shared.LangEN
+			}
+			return shared.LangFR
+		}
+	}
+	return shared.LangFR
+}
+
+func printUsage(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprintln(w, "Usage: pipbingo [options] <commande> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commandes:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-9s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Options (avant ou après la commande):")
+	fs.PrintDefaults()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Codes de sortie: 0 succès, 1 erreur, 2 usage, 3 introuvable, 4 conflit d'état,")
+	fmt.Fprintln(w, "5 daemon injoignable, 6 téléchargement suivi en échec (status -watch)")
+}
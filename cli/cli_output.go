@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// SORTIES TEXTE ET JSON
+// ============================================
+
+// printJSON écrit v en JSON indenté: c'est la sortie stable destinée aux scripts
+func (app *App) printJSON(v interface{}) error {
+	encoder := json.NewEncoder(app.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// table aligne des colonnes séparées par des tabulations
+func (app *App) table() *tabwriter.Writer {
+	return tabwriter.NewWriter(app.stdout, 0, 0, 2, ' ', 0)
+}
+
+// jsonError est l'erreur écrite sur la sortie d'erreur en mode -json
+type jsonError struct {
+	Error    string            `json:"error"`
+	ExitCode int               `json:"exit_code"`
+	API      *shared.ErrorBody `json:"api,omitempty"` // réponse d'erreur du daemon, s'il a répondu
+}
+
+// writeJSONError écrit err en JSON, avec le code et les détails renvoyés par le daemon
+func writeJSONError(w io.Writer, err error) {
+	out := jsonError{Error: err.Error(), ExitCode: exitCode(err)}
+	var apiErr *shared.APIError
+	if errors.As(err, &apiErr) {
+		out.API = &shared.ErrorBody{
+			Code:      apiErr.Code,
+			Status:    apiErr.StatusCode,
+			Message:   apiErr.Message,
+			Details:   apiErr.Details,
+			RequestID: apiErr.RequestID,
+		}
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+// formatBytes affiche une taille en unités lisibles (1,5 Go)
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d o", n)
+	}
+	value, suffix := float64(n)/unit, 0
+	for value >= unit && suffix < 3 {
+		value /= unit
+		suffix++
+	}
+	return strings.Replace(fmt.Sprintf("%.1f %s", value, []string{"Ko", "Mo", "Go", "To"}[suffix]), ".", ",", 1)
+}
+
+// formatRate affiche un débit en octets par seconde
+func formatRate(bytesPerSecond float64) string {
+	if bytesPerSecond <= 0 {
+		return "-"
+	}
+	return formatBytes(int64(bytesPerSecond)) + "/s"
+}
+
+// formatDuration affiche une durée en secondes sous la forme 1:02:03 ou 4:05
+func formatDuration(seconds int) string {
+	if seconds <= 0 {
+		return "-"
+	}
+	h, m, s := seconds/3600, seconds/60%60, seconds%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// progressBar dessine une barre de progression: [#######-----]  58,3 %
+func progressBar(percent float64, width int) string {
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	filled := int(percent / 100 * float64(width))
+	bar := strings.Repeat("#", filled) + strings.Repeat("-", width-filled)
+	return strings.Replace(fmt.Sprintf("[%s] %5.1f %%", bar, percent), ".", ",", 1)
+}
+
+// shortPeerID abrège un identifiant libp2p (même préfixe 12D3KooW pour tous)
+func shortPeerID(id string) string {
+	if len(id) <= 12 {
+		return id
+	}
+	return "…" + id[len(id)-10:]
+}
+
+// yesNo affiche un booléen dans un tableau
+func yesNo(b bool) string {
+	if b {
+		return "oui"
+	}
+	return "-"
+}
+
+// downloadState résume l'état d'un téléchargement pour un tableau
+func downloadState(ds shared.DownloadStatus) string {
+	if ds.Status == shared.StateError && ds.ErrorCode != "" {
+		return ds.Status + " (" + ds.ErrorCode + ")"
+	}
+	return ds.Status
+}
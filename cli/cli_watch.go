@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// SUIVI EN DIRECT (status -watch)
+// ============================================
+
+const (
+	watchRedrawInterval = 250 * time.Millisecond // le daemon publie la progression plusieurs fois par seconde
+	watchRetryDelay     = 2 * time.Second        // avant de se réabonner après une coupure
+)
+
+// watchUpdate est un événement du daemon, ou reset après une reconnexion (l'état est rejoué)
+type watchUpdate struct {
+	event shared.Event
+	reset bool
+}
+
+// followEvents relaie les événements de téléchargement jusqu'à l'annulation de ctx,
+// en se réabonnant après une coupure. Seul l'échec du premier abonnement est renvoyé.
+func (app *App) followEvents(ctx context.Context, filter shared.EventFilter, updates chan<- watchUpdate, failed chan<- error) {
+	first := true
+	for ctx.Err() == nil {
+		stream, err := app.daemon.OpenEvents(ctx, filter)
+		if err != nil {
+			if first {
+				failed <- err
+				return
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(watchRetryDelay):
+			}
+			continue
+		}
+		if !first {
+			select {
+			case updates <- watchUpdate{reset: true}:
+			case <-ctx.Done():
+			}
+		}
+		first = false
+
+		for {
+			ev, err := stream.Next()
+			if err != nil {
+				break
+			}
+			select {
+			case updates <- watchUpdate{event: ev}:
+			case <-ctx.Done():
+			}
+		}
+		stream.Close()
+	}
+}
+
+// watchDownloads affiche les téléchargements en direct. Avec un fichier, s'arrête quand
+// son téléchargement se termine (code 0) ou échoue (ExitFailed). En -json, un événement par ligne.
+func (app *App) watchDownloads(filename string) error {
+	if filename != "" {
+		// Un fichier inconnu ne produirait aucun événement: échouer tout de suite
+		if _, err := app.daemon.DownloadStatus(app.ctx, filename); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(app.ctx)
+	defer cancel()
+	updates := make(chan watchUpdate)
+	failed := make(chan error, 1)
+	go app.followEvents(ctx, shared.EventFilter{
+		Video: filename,
+		Types: []string{shared.EventDownload, shared.EventDownloadRemoved},
+	}, updates, failed)
+
+	downloads := make(map[string]shared.DownloadStatus)
+	ticker := time.NewTicker(watchRedrawInterval)
+	defer ticker.Stop()
+	dirty := false
+
+	for {
+		select {
+		case <-app.ctx.Done():
+			if dirty {
+				app.drawWatch(downloads)
+			}
+			return nil
+
+		case err := <-failed:
+			return err
+
+		case <-ticker.C:
+			if dirty {
+				app.drawWatch(downloads)
+				dirty = false
+			}
+
+		case update := <-updates:
+			if update.reset {
+				downloads = make(map[string]shared.DownloadStatus)
+				continue
+			}
+			ev := update.event
+			if app.json {
+				line, _ := json.Marshal(ev)
+				fmt.Fprintln(app.stdout, string(line))
+			}
+
+			switch ev.Type {
+			case shared.EventDownload:
+				var ds shared.DownloadStatus
+				if err := ev.Decode(&ds); err != nil {
+					continue
+				}
+				downloads[ds.Filename] = ds
+			case shared.EventDownloadRemoved:
+				delete(downloads, ev.Filename)
+			}
+			dirty = true
+
+			if filename != "" && ev.Filename == filename {
+				if done, err := watchOutcome(filename, ev, downloads); done {
+					app.drawWatch(downloads)
+					return err
+				}
+			}
+		}
+	}
+}
+
+// watchOutcome dit si le téléchargement suivi est terminé, et avec quel résultat
+func watchOutcome(filename string, ev shared.Event, downloads map[string]shared.DownloadStatus) (bool, error) {
+	if ev.Type == shared.EventDownloadRemoved {
+		return true, &exitError{code: ExitFailed, msg: filename + ": téléchargement retiré"}
+	}
+
+	ds := downloads[filename]
+	switch ds.Status {
+	case shared.StateCompleted, shared.StateSeeding:
+		return true, nil
+	case shared.StateError:
+		return true, &exitError{code: ExitFailed, msg: fmt.Sprintf("%s: échec du téléchargement: %s", filename, ds.Error)}
+	case shared.StateCancelled:
+		return true, &exitError{code: ExitFailed, msg: filename + ": téléchargement annulé"}
+	}
+	return false, nil
+}
+
+// drawWatch redessine le tableau (terminal) ou l'ajoute à la suite (sortie redirigée)
+func (app *App) drawWatch(downloads map[string]shared.DownloadStatus) {
+	if app.json {
+		return
+	}
+
+	list := make([]shared.DownloadStatus, 0, len(downloads))
+	for _, ds := range downloads {
+		list = append(list, ds)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Priority != list[j].Priority {
+			return list[i].Priority > list[j].Priority
+		}
+		return list[i].Filename < list[j].Filename
+	})
+
+	if isTerminal(app.stdout) {
+		fmt.Fprint(app.stdout, "\033[H\033[2J") // curseur en haut, écran effacé
+		fmt.Fprintf(app.stdout, "pipbingo - %s - Ctrl-C pour quitter\n\n", time.Now().Format("15:04:05"))
+	} else {
+		fmt.Fprintln(app.stdout)
+	}
+	if len(list) == 0 {
+		fmt.Fprintln(app.stdout, "Aucun téléchargement")
+		return
+	}
+	app.printDownloads(list)
+}
+
+// isTerminal indique si w est un terminal (pas un fichier ni un pipe)
+func isTerminal(w interface{}) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// CACHE BORNÉ (LRU + ÉPINGLAGE)
+// ============================================
+
+var (
+	errCacheFull           = errors.New("cache plein: pas assez de vidéos évinçables")
+	errInsufficientStorage = errors.New("espace disque insuffisant")
+)
+
+// CacheEntry mémorise l'usage d'un fichier du cache
+type CacheEntry struct {
+	LastAccess time.Time `json:"last_access"`
+	Pinned     bool      `json:"pinned"`
+}
+
+// cacheState est le contenu persisté dans StateDir/cache.json. La taille max n'y
+// figure que si elle a été changée par PUT /cache: sinon max_cache_size s'applique.
+type cacheState struct {
+	MaxSize *override[int64]       `json:"max_size_override,omitempty"`
+	Files   map[string]*CacheEntry `json:"files"`
+}
+
+// cacheStatePath renvoie le chemin du fichier d'état du cache
+func cacheStatePath() string {
+	return filepath.Join(cfg.StateDir(), "cache.json")
+}
+
+// cachedFile est un fichier complet présent dans CacheDir
+type cachedFile struct {
+	name       string
+	size       int64
+	lastAccess time.Time
+	pinned     bool
+}
+
+// listCacheLocked liste les fichiers complets du cache (appelé sous cacheLock)
+func (d *Daemon) listCacheLocked() ([]cachedFile, error) {
+	entries, err := os.ReadDir(cfg.CacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []cachedFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		file := cachedFile{name: entry.Name(), size: info.Size(), lastAccess: info.ModTime()}
+		if meta, ok := d.cacheEntries[entry.Name()]; ok {
+			if !meta.LastAccess.IsZero() {
+				file.lastAccess = meta.LastAccess
+			}
+			file.pinned = meta.Pinned
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// cacheUsageLocked renvoie l'espace occupé par les fichiers complets et réservé
+// par les téléchargements en cours (appelé sous cacheLock)
+func (d *Daemon) cacheUsageLocked(files []cachedFile) int64 {
+	var used int64
+	for _, file := range files {
+		used += file.size
+	}
+	for _, size := range d.cacheReserved {
+		used += size
+	}
+	return used
+}
+
+// reserveCache réserve la place d'un téléchargement, en évinçant au besoin
+// les vidéos non épinglées regardées le moins récemment
+func (d *Daemon) reserveCache(filename string, size int64) error {
+	d.cacheLock.Lock()
+	defer d.cacheLock.Unlock()
+
+	delete(d.cacheReserved, filename)
+	if err := d.makeRoomLocked(size); err != nil {
+		return err
+	}
+	d.cacheReserved[filename] = size
+	return nil
+}
+
+// releaseCache libère la réservation d'un téléchargement terminé ou abandonné
+func (d *Daemon) releaseCache(filename string) {
+	d.cacheLock.Lock()
+	delete(d.cacheReserved, filename)
+	d.cacheLock.Unlock()
+}
+
+// makeRoomLocked évince des fichiers jusqu'à pouvoir ajouter needed octets (appelé sous cacheLock)
+func (d *Daemon) makeRoomLocked(needed int64) error {
+	if d.maxCacheSize <= 0 {
+		return nil
+	}
+
+	files, err := d.listCacheLocked()
+	if err != nil {
+		return err
+	}
+	used := d.cacheUsageLocked(files)
+	if used+needed <= d.maxCacheSize {
+		return nil
+	}
+
+	for _, file := range d.evictableLocked(files) {
+		if used+needed <= d.maxCacheSize {
+			break
+		}
+		if d.evictLogLocked(file) {
+			used -= file.size
+		}
+	}
+
+	if used+needed > d.maxCacheSize {
+		return fmt.Errorf("%w (%d/%d octets utilisés, %d demandés)", errCacheFull, used, d.maxCacheSize, needed)
+	}
+	d.saveCacheStateLocked()
+	return nil
+}
+
+// evictableLocked renvoie les fichiers évinçables, le moins récemment regardé d'abord (appelé sous cacheLock)
+func (d *Daemon) evictableLocked(files []cachedFile) []cachedFile {
+	var evictable []cachedFile
+	for _, file := range files {
+		if !file.pinned && d.getPartial(file.name) == nil {
+			evictable = append(evictable, file)
+		}
+	}
+	sort.Slice(evictable, func(i, j int) bool { return evictable[i].lastAccess.Before(evictable[j].lastAccess) })
+	return evictable
+}
+
+// evictLogLocked évince un fichier et journalise le résultat (appelé sous cacheLock)
+func (d *Daemon) evictLogLocked(file cachedFile) bool {
+	if err := d.evictLocked(file.name); err != nil {
+		logCache.Warn("éviction impossible", "file", file.name, "err", err)
+		return false
+	}
+	logCache.Info("fichier évincé du cache", "file", file.name, "bytes", file.size, "last_access", file.lastAccess)
+	return true
+}
+
+// ensureDiskSpace vérifie que le disque peut accueillir needed octets en gardant
+// DiskReserve de libre, en évinçant au besoin des vidéos du cache
+func (d *Daemon) ensureDiskSpace(needed int64) error {
+	d.cacheLock.Lock()
+	defer d.cacheLock.Unlock()
+
+	free, err := shared.DiskFree(cfg.CacheDir)
+	if err != nil {
+		return fmt.Errorf("espace disque inconnu: %w", err)
+	}
+	if free-needed >= cfg.DiskReserve {
+		return nil
+	}
+
+	files, err := d.listCacheLocked()
+	if err != nil {
+		return err
+	}
+	for _, file := range d.evictableLocked(files) {
+		if !d.evictLogLocked(file) {
+			continue
+		}
+		if free, err = shared.DiskFree(cfg.CacheDir); err != nil || free-needed >= cfg.DiskReserve {
+			break
+		}
+	}
+	d.saveCacheStateLocked()
+
+	if err != nil {
+		return fmt.Errorf("espace disque inconnu: %w", err)
+	}
+	if free-needed < cfg.DiskReserve {
+		return fmt.Errorf("%w: %d Mo libres, %d Mo nécessaires, %d Mo de réserve",
+			errInsufficientStorage, free>>20, needed>>20, cfg.DiskReserve>>20)
+	}
+	return nil
+}
+
+// evictLocked retire un fichier du cache et arrête son seeding (appelé sous cacheLock)
+func (d *Daemon) evictLocked(filename string) error {
+	if err := os.Remove(filepath.Join(cfg.CacheDir, filename)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	os.Remove(manifestPath(filename))
+	d.forgetCachedLocked(filename)
+	return nil
+}
+
+// forgetCachedLocked oublie un fichier retiré du cache: accès, seeding et
+// entrée de téléchargement (appelé sous cacheLock)
+func (d *Daemon) forgetCachedLocked(filename string) {
+	delete(d.cacheEntries, filename)
+
+	d.seedersLock.Lock()
+	delete(d.activeSeeders, filename)
+	delete(d.seedStats, filename)
+	d.seedersLock.Unlock()
+	d.publishSeeding(filename)
+
+	d.downloadsLock.Lock()
+	if ds, exists := d.downloads[filename]; exists && (ds.Status == StateCompleted || ds.Status == StateSeeding) {
+		d.transition(ds, StateCancelled)
+	}
+	d.downloadsLock.Unlock()
+}
+
+// touchCache note l'accès à une vidéo (lecture ou fin de téléchargement)
+func (d *Daemon) touchCache(filename string) {
+	d.cacheLock.Lock()
+	defer d.cacheLock.Unlock()
+
+	entry, ok := d.cacheEntries[filename]
+	if !ok {
+		entry = &CacheEntry{}
+		d.cacheEntries[filename] = entry
+	}
+
+	// Les requêtes Range arrivent en rafale: ne persister qu'une fois par minute
+	persist := time.Since(entry.LastAccess) > time.Minute
+	entry.LastAccess = time.Now()
+	if persist {
+		d.saveCacheStateLocked()
+	}
+}
+
+// setPinned épingle ou libère une vidéo du cache
+func (d *Daemon) setPinned(filename string, pinned bool) error {
+	if _, err := os.Stat(filepath.Join(cfg.CacheDir, filename)); err != nil {
+		return errNotSeedable
+	}
+
+	d.cacheLock.Lock()
+	defer d.cacheLock.Unlock()
+
+	entry, ok := d.cacheEntries[filename]
+	if !ok {
+		entry = &CacheEntry{}
+		d.cacheEntries[filename] = entry
+	}
+	entry.Pinned = pinned
+	d.saveCacheStateLocked()
+	return nil
+}
+
+// setMaxCacheSize change la taille max du cache et évince l'excédent
+func (d *Daemon) setMaxCacheSize(size int64) error {
+	if size < 0 {
+		return fmt.Errorf("taille max négative")
+	}
+
+	d.cacheLock.Lock()
+	defer d.cacheLock.Unlock()
+
+	d.maxCacheSize = size
+	d.cacheOverride = newOverride(size, cfg.MaxCacheSize)
+	d.saveCacheStateLocked()
+	return d.makeRoomLocked(0)
+}
+
+// loadCacheState recharge les accès persistés et la taille max fixée par l'API
+func (d *Daemon) loadCacheState() {
+	data, err := os.ReadFile(cacheStatePath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logCache.Warn("état du cache illisible", "err", err)
+		}
+		return
+	}
+
+	var state cacheState
+	if err := json.Unmarshal(data, &state); err != nil {
+		logCache.Warn("état du cache corrompu, ignoré", "err", err)
+		return
+	}
+
+	d.cacheLock.Lock()
+	d.maxCacheSize, d.cacheOverride = state.MaxSize.resolve(cfg.MaxCacheSize)
+	if state.MaxSize != nil && d.cacheOverride == nil {
+		logCache.Info("max_cache_size modifié depuis PUT /cache, la configuration s'applique", "max_size", d.maxCacheSize)
+	}
+	for filename, entry := range state.Files {
+		d.cacheEntries[filename] = entry
+	}
+	d.cacheLock.Unlock()
+}
+
+// saveCacheStateLocked persiste les accès et la surcharge de taille max (appelé sous cacheLock)
+func (d *Daemon) saveCacheStateLocked() {
+	data, err := json.MarshalIndent(cacheState{MaxSize: d.cacheOverride, Files: d.cacheEntries}, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := writeFileAtomic(cacheStatePath(), data); err != nil {
+		logCache.Error("état du cache non sauvegardé", "err", err)
+	}
+}
+
+// ============================================
+// API HTTP DU CACHE
+// ============================================
+
+// CacheFileInfo est l'état d'un fichier du cache tel que renvoyé par l'API (pipbingo/shared)
+type CacheFileInfo = shared.CacheFileInfo
+
+// handleGetCache renvoie l'occupation du cache et l'état de chaque fichier
+func (d *Daemon) handleGetCache(w http.ResponseWriter, r *http.Request) {
+	d.cacheLock.Lock()
+	files, err := d.listCacheLocked()
+	used := d.cacheUsageLocked(files)
+	reserved := int64(0)
+	for _, size := range d.cacheReserved {
+		reserved += size
+	}
+	maxSize := d.maxCacheSize
+	d.cacheLock.Unlock()
+
+	if err != nil {
+		shared.WriteError(w, r, shared.CodeStorageError, shared.Details{"reason": err.Error()})
+		return
+	}
+
+	list := make([]CacheFileInfo, 0, len(files))
+	for _, file := range files {
+		list = append(list, CacheFileInfo{
+			Filename:   file.name,
+			Size:       file.size,
+			LastAccess: file.lastAccess,
+			Pinned:     file.pinned,
+			Seeding:    d.isSeeding(file.name),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].LastAccess.After(list[j].LastAccess) })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shared.CacheView{
+		MaxSize:  maxSize,
+		Used:     used,
+		Reserved: reserved,
+		Files:    list,
+	})
+}
+
+// handleSetCache change la taille max du cache
+func (d *Daemon) handleSetCache(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MaxSize int64 `json:"max_size"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		shared.WriteError(w, r, shared.CodeInvalidRequest, shared.Details{"reason": err.Error()})
+		return
+	}
+
+	if err := d.setMaxCacheSize(req.MaxSize); err != nil {
+		// La nouvelle taille est appliquée même si les fichiers épinglés la dépassent
+		if errors.Is(err, errCacheFull) {
+			shared.WriteError(w, r, shared.CodeCacheFull, shared.Details{"reason": err.Error()})
+			return
+		}
+		shared.WriteError(w, r, shared.CodeInvalidRequest, shared.Details{"reason": err.Error()})
+		return
+	}
+
+	d.handleGetCache(w, r)
+}
+
+// handlePinRequest épingle (POST) ou libère (DELETE) une vidéo du cache
+func (d *Daemon) handlePinRequest(w http.ResponseWriter, r *http.Request) {
+	filename := filepath.Base(mux.Vars(r)["filename"])
+
+	if err := d.setPinned(filename, r.Method == http.MethodPost); err != nil {
+		shared.WriteError(w, r, shared.CodeNotInCache, shared.Details{"filename": filename})
+		return
+	}
+
+	requestLogger(r).Info("épinglage modifié", "file", filename, "pinned", r.Method == http.MethodPost)
+	d.handleGetCache(w, r)
+}
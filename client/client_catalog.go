@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// MIROIR HORS LIGNE DU CATALOGUE
+// ============================================
+
+// CatalogChange est un changement du catalogue publié par le serveur (pipbingo/shared)
+type CatalogChange = shared.CatalogChange
+
+// catalogMirror est la copie locale du catalogue, persistée dans StateDir/catalog.json
+type catalogMirror struct {
+	Seq      uint64            `json:"seq"` // dernier changement appliqué
+	SyncedAt time.Time         `json:"synced_at"`
+	Videos   map[string]*Video `json:"videos"`
+}
+
+func catalogStatePath() string {
+	return filepath.Join(cfg.StateDir(), "catalog.json")
+}
+
+// loadCatalogMirror recharge la copie locale du catalogue
+func (d *Daemon) loadCatalogMirror() {
+	data, err := os.ReadFile(catalogStatePath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logCatalog.Warn("catalogue local illisible", "err", err)
+		}
+		return
+	}
+
+	var mirror catalogMirror
+	if err := json.Unmarshal(data, &mirror); err != nil {
+		logCatalog.Warn("catalogue local corrompu, ignoré", "err", err)
+		return
+	}
+	if mirror.Videos == nil {
+		mirror.Videos = make(map[string]*Video)
+	}
+
+	d.catalogLock.Lock()
+	d.catalog = mirror
+	d.catalogLock.Unlock()
+	logCatalog.Info("catalogue local chargé", "videos", len(mirror.Videos), "seq", mirror.Seq)
+}
+
+// saveCatalogMirrorLocked persiste la copie locale du catalogue (appelé sous catalogLock)
+func (d *Daemon) saveCatalogMirrorLocked() {
+	data, err := json.MarshalIndent(d.catalog, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := writeFileAtomic(catalogStatePath(), data); err != nil {
+		logCatalog.Error("catalogue local non sauvegardé", "err", err)
+	}
+}
+
+// setCatalogOnline note si le serveur répond, pour marquer les entrées hors ligne
+func (d *Daemon) setCatalogOnline(online bool) {
+	d.catalogLock.Lock()
+	changed := d.catalogOnline != online
+	d.catalogOnline = online
+	d.catalogLock.Unlock()
+
+	if changed && online {
+		logCatalog.Info("catalogue synchronisé avec le serveur")
+	} else if changed {
+		logCatalog.Warn("serveur injoignable, catalogue servi hors ligne")
+	}
+}
+
+// syncCatalog suit le flux de changements du serveur et s'y reconnecte en cas de coupure
+func (d *Daemon) syncCatalog() {
+	for {
+		err := d.followCatalog()
+		d.setCatalogOnline(false)
+		logCatalog.Debug("synchronisation du catalogue interrompue", "err", err)
+		time.Sleep(CatalogRetryInterval)
+	}
+}
+
+// followCatalog rattrape les changements manqués puis applique les suivants au fil de l'eau
+func (d *Daemon) followCatalog() error {
+	d.catalogLock.Lock()
+	seq := d.catalog.Seq
+	d.catalogLock.Unlock()
+
+	// Premier lancement: partir du catalogue complet
+	if seq == 0 {
+		if err := d.fetchFullCatalog(); err != nil {
+			return err
+		}
+		d.catalogLock.Lock()
+		seq = d.catalog.Seq
+		d.catalogLock.Unlock()
+	}
+
+	// Le serveur envoie un keepalive régulier: sans nouvelle ligne pendant
+	// CatalogStreamTimeout, le flux est coupé et le serveur considéré injoignable
+	stream, err := d.server.OpenChanges(context.Background(), seq)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	d.setCatalogOnline(true)
+
+	for {
+		event, err := stream.Next()
+		if err != nil {
+			return err
+		}
+		if event.Reset {
+			// L'historique du serveur ne remonte plus jusqu'à notre dernier changement
+			logCatalog.Info("catalogue local trop ancien, rechargement complet")
+			if err := d.fetchFullCatalog(); err != nil {
+				return err
+			}
+			continue
+		}
+		d.applyCatalogChange(event.Change)
+	}
+}
+
+// fetchFullCatalog remplace la copie locale par le catalogue complet du serveur
+func (d *Daemon) fetchFullCatalog() error {
+	videos, seq, err := d.server.List(context.Background())
+	if err != nil {
+		return fmt.Errorf("catalogue: %w", err)
+	}
+
+	d.catalogLock.Lock()
+	d.catalog.Seq = seq
+	d.catalog.SyncedAt = time.Now()
+	d.catalog.Videos = make(map[string]*Video, len(videos))
+	for _, video := range videos {
+		d.catalog.Videos[video.ID] = video
+	}
+	d.saveCatalogMirrorLocked()
+	d.catalogLock.Unlock()
+
+	logCatalog.Info("catalogue rechargé", "videos", len(videos), "seq", seq)
+	d.mirrorThumbnails()
+	return nil
+}
+
+// applyCatalogChange applique un changement, sauf s'il est déjà inclus dans la copie locale
+func (d *Daemon) applyCatalogChange(change CatalogChange) {
+	d.catalogLock.Lock()
+	if change.Seq <= d.catalog.Seq {
+		d.catalogLock.Unlock()
+		return
+	}
+
+	switch {
+	case change.Type == shared.ChangeDeleted:
+		delete(d.catalog.Videos, change.VideoID)
+	case change.Video != nil:
+		d.catalog.Videos[change.VideoID] = change.Video
+	}
+	d.catalog.Seq = change.Seq
+	d.catalog.SyncedAt = time.Now()
+	d.saveCatalogMirrorLocked()
+	d.catalogLock.Unlock()
+
+	if change.Video != nil {
+		d.mirrorThumbnails()
+	}
+}
+
+// ============================================
+// MINIATURES DES VIDÉOS EN CACHE
+// ============================================
+
+// thumbnailPath renvoie le chemin local de la miniature d'une vidéo
+func thumbnailPath(thumbnail string) string {
+	return filepath.Join(cfg.ThumbnailCacheDir(), filepath.Base(thumbnail))
+}
+
+// mirrorThumbnails télécharge les miniatures manquantes des vidéos présentes dans le cache
+func (d *Daemon) mirrorThumbnails() {
+	d.catalogLock.Lock()
+	var thumbnails []string
+	for _, video := range d.catalog.Videos {
+		if video.Thumbnail == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(cfg.CacheDir, video.Filename)); err == nil {
+			thumbnails = append(thumbnails, video.Thumbnail)
+		}
+	}
+	d.catalogLock.Unlock()
+
+	d.thumbnailsLock.Lock()
+	defer d.thumbnailsLock.Unlock()
+
+	for _, thumbnail := range thumbnails {
+		if _, err := os.Stat(thumbnailPath(thumbnail)); err == nil {
+			continue
+		}
+		if err := fetchThumbnail(thumbnail); err != nil {
+			logCatalog.Warn("miniature non récupérée", "thumbnail", thumbnail, "err", err)
+		}
+	}
+}
+
+// fetchThumbnail télécharge une miniature du serveur
+func fetchThumbnail(thumbnail string) error {
+	url := thumbnail
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = cfg.Server.HTTPURL + "/" + strings.TrimPrefix(thumbnail, "/")
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxThumbnailSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > MaxThumbnailSize {
+		return fmt.Errorf("miniature trop volumineuse")
+	}
+	return writeFileAtomic(thumbnailPath(thumbnail), data)
+}
+
+// ============================================
+// API HTTP DU CATALOGUE
+// ============================================
+
+// CatalogEntry est une vidéo du catalogue telle que servie par le daemon (pipbingo/shared)
+type CatalogEntry = shared.CatalogEntry
+
+// handleGetCatalog renvoie le catalogue. Serveur joignable: toutes les vidéos, avec
+// celles du cache signalées. Hors ligne: seulement les vidéos du cache, marquées offline.
+func (d *Daemon) handleGetCatalog(w http.ResponseWriter, r *http.Request) {
+	d.catalogLock.Lock()
+	online := d.catalogOnline
+	seq := d.catalog.Seq
+	syncedAt := d.catalog.SyncedAt
+	videos := make([]Video, 0, len(d.catalog.Videos))
+	for _, video := range d.catalog.Videos {
+		videos = append(videos, *video)
+	}
+	d.catalogLock.Unlock()
+
+	entries := make([]CatalogEntry, 0, len(videos))
+	for _, video := range videos {
+		entry := CatalogEntry{Video: video, Offline: !online}
+		if _, err := os.Stat(filepath.Join(cfg.CacheDir, video.Filename)); err == nil {
+			entry.Cached = true
+		}
+		if !entry.Cached && !online {
+			continue
+		}
+		if video.Thumbnail != "" {
+			if _, err := os.Stat(thumbnailPath(video.Thumbnail)); err == nil {
+				entry.LocalThumbnail = "/catalog/thumbnails/" + filepath.Base(video.Thumbnail)
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	// Les plus récentes d'abord, comme sur le serveur
+	sort.Slice(entries, func(i, j int) bool { return entries[i].UploadedAt.After(entries[j].UploadedAt) })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shared.CatalogView{
+		Online:   online,
+		Seq:      seq,
+		SyncedAt: syncedAt,
+		Videos:   entries,
+	})
+}
+
+// handleCatalogThumbnail sert une miniature conservée localement
+func (d *Daemon) handleCatalogThumbnail(w http.ResponseWriter, r *http.Request) {
+	path := thumbnailPath(mux.Vars(r)["name"])
+	if _, err := os.Stat(path); err != nil {
+		shared.WriteError(w, r, shared.CodeNotFound, nil)
+		return
+	}
+	http.ServeFile(w, r, path)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// CHOKING / UNCHOKING
+// ============================================
+
+// errPeerChoked est renvoyée quand un peer refuse de nous envoyer des chunks pour l'instant
+var errPeerChoked = shared.ErrChoked
+
+// peerActivity retient les échanges récents avec un peer
+type peerActivity struct {
+	firstSeen    time.Time
+	lastRequest  time.Time // dernière demande de chunk (peer intéressé)
+	downloaded   int64     // octets reçus de ce peer depuis le dernier rechoke
+	uploaded     int64     // octets envoyés à ce peer depuis le dernier rechoke
+	downloadRate float64   // moyenne glissante en o/s
+	uploadRate   float64
+}
+
+// Choker décide à quels peers on envoie des chunks, à la manière de BitTorrent:
+// quelques peers débloqués (unchoked) choisis selon ce qu'ils nous envoient,
+// plus un déblocage optimiste qui laisse sa chance à un nouveau venu.
+type Choker struct {
+	slots      int
+	peers      map[peer.ID]*peerActivity
+	unchoked   map[peer.ID]bool
+	optimistic peer.ID
+	rounds     int
+	lock       sync.Mutex
+}
+
+func NewChoker(slots int) *Choker {
+	return &Choker{
+		slots:    slots,
+		peers:    make(map[peer.ID]*peerActivity),
+		unchoked: make(map[peer.ID]bool),
+	}
+}
+
+// activity renvoie l'activité d'un peer, créée à la demande (appelé sous lock)
+func (c *Choker) activity(id peer.ID) *peerActivity {
+	a, ok := c.peers[id]
+	if !ok {
+		a = &peerActivity{firstSeen: time.Now()}
+		c.peers[id] = a
+	}
+	return a
+}
+
+// Allow enregistre l'intérêt d'un peer et indique s'il peut être servi.
+// Tant que des slots sont libres, un nouveau peer est débloqué sans attendre le prochain rechoke.
+func (c *Choker) Allow(id peer.ID) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.activity(id).lastRequest = time.Now()
+	if c.unchoked[id] || c.optimistic == id {
+		return true
+	}
+	if len(c.unchoked) < c.slots {
+		c.unchoked[id] = true
+		logP2P.Debug("unchoke (slot libre)", "peer", id.ShortString())
+		return true
+	}
+	return false
+}
+
+// RecordDownload comptabilise les octets reçus d'un peer
+func (c *Choker) RecordDownload(id peer.ID, bytes int) {
+	c.lock.Lock()
+	c.activity(id).downloaded += int64(bytes)
+	c.lock.Unlock()
+}
+
+// RecordUpload comptabilise les octets envoyés à un peer
+func (c *Choker) RecordUpload(id peer.ID, bytes int) {
+	c.lock.Lock()
+	c.activity(id).uploaded += int64(bytes)
+	c.lock.Unlock()
+}
+
+// run relance l'algorithme à intervalle régulier
+func (c *Choker) run() {
+	ticker := time.NewTicker(ChokeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.rechoke()
+	}
+}
+
+// rechoke met à jour les débits et choisit les peers débloqués
+func (c *Choker) rechoke() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	seconds := ChokeInterval.Seconds()
+	var interested []peer.ID
+	for id, a := range c.peers {
+		// Moyenne glissante: le passé compte autant que la dernière période
+		a.downloadRate = (a.downloadRate + float64(a.downloaded)/seconds) / 2
+		a.uploadRate = (a.uploadRate + float64(a.uploaded)/seconds) / 2
+		a.downloaded, a.uploaded = 0, 0
+
+		switch {
+		case now.Sub(a.lastRequest) < PeerInterestTimeout:
+			interested = append(interested, id)
+		case now.Sub(a.lastRequest) > 2*PeerInterestTimeout && a.downloadRate < 1:
+			// Ni demande ni envoi récents: oublier le peer
+			delete(c.peers, id)
+		}
+	}
+
+	// Réciprocité: d'abord les peers qui nous envoient le plus, puis ceux
+	// à qui l'on envoie le plus (cas d'un fichier complet qu'on ne fait que seeder)
+	sort.Slice(interested, func(i, j int) bool {
+		a, b := c.peers[interested[i]], c.peers[interested[j]]
+		if a.downloadRate != b.downloadRate {
+			return a.downloadRate > b.downloadRate
+		}
+		return a.uploadRate > b.uploadRate
+	})
+
+	unchoked := make(map[peer.ID]bool)
+	for _, id := range interested {
+		if len(unchoked) >= c.slots {
+			break
+		}
+		unchoked[id] = true
+	}
+
+	// Déblocage optimiste renouvelé tous les OptimisticUnchokeRounds rechokes
+	if c.rounds%OptimisticUnchokeRounds == 0 || !c.isInterested(c.optimistic, now) || unchoked[c.optimistic] {
+		c.optimistic = c.pickOptimistic(interested, unchoked, now)
+	}
+	c.rounds++
+
+	for id := range c.unchoked {
+		if !unchoked[id] && id != c.optimistic {
+			logP2P.Debug("choke", "peer", id.ShortString())
+		}
+	}
+	c.unchoked = unchoked
+}
+
+// isInterested indique si un peer a demandé des chunks récemment (appelé sous lock)
+func (c *Choker) isInterested(id peer.ID, now time.Time) bool {
+	a, ok := c.peers[id]
+	return ok && now.Sub(a.lastRequest) < PeerInterestTimeout
+}
+
+// pickOptimistic tire un peer choké au hasard, en favorisant les nouveaux venus (appelé sous lock)
+func (c *Choker) pickOptimistic(interested []peer.ID, unchoked map[peer.ID]bool, now time.Time) peer.ID {
+	var candidates []peer.ID
+	for _, id := range interested {
+		if unchoked[id] {
+			continue
+		}
+		candidates = append(candidates, id)
+		// Comme BitTorrent: un nouveau venu a trois fois plus de chances d'être tiré
+		if now.Sub(c.peers[id].firstSeen) < 3*ChokeInterval {
+			candidates = append(candidates, id, id)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	id := candidates[rand.Intn(len(candidates))]
+	logP2P.Debug("unchoke optimiste", "peer", id.ShortString())
+	return id
+}
+
+// PeerChokeInfo est l'état d'un peer tel que renvoyé par l'API (pipbingo/shared)
+type PeerChokeInfo = shared.PeerChokeInfo
+
+// snapshot renvoie l'état de tous les peers connus
+func (c *Choker) snapshot() []PeerChokeInfo {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	list := make([]PeerChokeInfo, 0, len(c.peers))
+	for id, a := range c.peers {
+		list = append(list, PeerChokeInfo{
+			ID:           id.String(),
+			Unchoked:     c.unchoked[id] || c.optimistic == id,
+			Optimistic:   c.optimistic == id,
+			Interested:   now.Sub(a.lastRequest) < PeerInterestTimeout,
+			DownloadRate: a.downloadRate,
+			UploadRate:   a.uploadRate,
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// unchokedCount renvoie le nombre de peers actuellement servis
+func (c *Choker) unchokedCount() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	count := len(c.unchoked)
+	if c.optimistic != "" && !c.unchoked[c.optimistic] {
+		count++
+	}
+	return count
+}
+
+// handlePeersRequest renvoie l'état de choke des peers connus
+func (d *Daemon) handlePeersRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shared.PeersView{
+		UnchokeSlots: cfg.UnchokeSlots,
+		Peers:        d.choker.snapshot(),
+	})
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/multiformats/go-multiaddr"
+	"gopkg.in/yaml.v3"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// CONFIGURATION
+// ============================================
+
+// DefaultConfigFile est lu s'il existe et qu'aucun fichier n'est indiqué par -config ou PIPBINGO_CONFIG
+const DefaultConfigFile = "pipbingo-daemon.yaml"
+
+// Config regroupe les réglages du daemon. Ils sont appliqués par couches:
+// valeurs par défaut, fichier YAML, variables d'environnement PIPBINGO_*, puis flags.
+// Deux daemons peuvent tourner sur la même machine avec des ports et un cache différents.
+type Config struct {
+	APIAddr                string         `yaml:"api_addr" json:"api_addr"`   // Adresse de l'API HTTP locale
+	P2PPort                int            `yaml:"p2p_port" json:"p2p_port"`   // Port TCP du nœud libp2p
+	CacheDir               string         `yaml:"cache_dir" json:"cache_dir"` // Vidéos complètes et état du daemon
+	Server                 ServerConfig   `yaml:"server" json:"server"`
+	Peers                  []string       `yaml:"peers" json:"peers"` // Multiaddrs /p2p/ contactés au démarrage
+	MaxConcurrentDownloads int            `yaml:"max_concurrent_downloads" json:"max_concurrent_downloads"`
+	UploadRate             int64          `yaml:"upload_rate" json:"upload_rate"`               // o/s, 0 = illimité
+	DownloadRate           int64          `yaml:"download_rate" json:"download_rate"`           // o/s, 0 = illimité
+	PeerUploadRate         int64          `yaml:"peer_upload_rate" json:"peer_upload_rate"`     // o/s vers chaque peer, 0 = illimité
+	PeerDownloadRate       int64          `yaml:"peer_download_rate" json:"peer_download_rate"` // o/s depuis chaque peer, 0 = illimité
+	Schedule               []ScheduleRule `yaml:"schedule" json:"schedule"`                     // Limites par plage horaire (YAML seulement)
+	MaxCacheSize           int64          `yaml:"max_cache_size" json:"max_cache_size"`         // octets, 0 = illimité
+	DiskReserve            int64          `yaml:"disk_reserve" json:"disk_reserve"`             // octets gardés libres après un téléchargement
+	MaxSeedRatio           float64        `yaml:"max_seed_ratio" json:"max_seed_ratio"`         // 0 = illimité
+	MaxSeedTime            int64          `yaml:"max_seed_time" json:"max_seed_time"`           // secondes, 0 = illimité
+	MaxSeedingFiles        int            `yaml:"max_seeding_files" json:"max_seeding_files"`
+	MaxUploadSlots         int            `yaml:"max_upload_slots" json:"max_upload_slots"`
+	UnchokeSlots           int            `yaml:"unchoke_slots" json:"unchoke_slots"`
+	ScrubInterval          time.Duration  `yaml:"scrub_interval" json:"scrub_interval"` // 0 = au démarrage seulement
+	QuarantineCorrupt      bool           `yaml:"quarantine_corrupt" json:"quarantine_corrupt"`
+	ShutdownTimeout        time.Duration  `yaml:"shutdown_timeout" json:"shutdown_timeout"` // Délai laissé aux transferts en cours à l'arrêt
+	Log                    LogConfig      `yaml:"log" json:"log"`
+}
+
+// ServerConfig indique où joindre le serveur central
+type ServerConfig struct {
+	HTTPURL string `yaml:"http_url" json:"http_url"`
+	P2PAddr string `yaml:"p2p_addr" json:"p2p_addr"`
+}
+
+// cfg est la configuration effective, chargée au démarrage par loadConfig
+var cfg = defaultConfig()
+
+func defaultConfig() *Config {
+	return &Config{
+		APIAddr:  ":9090",
+		P2PPort:  10001,
+		CacheDir: "./cache",
+		Server: ServerConfig{
+			HTTPURL: "http://localhost:8080",
+			P2PAddr: "/ip4/127.0.0.1/tcp/10000",
+		},
+		MaxConcurrentDownloads: 3,
+		MaxCacheSize:           20 << 30,  // 20 Go
+		DiskReserve:            512 << 20, // 512 Mo
+		MaxUploadSlots:         4,
+		UnchokeSlots:           4,
+		ScrubInterval:          24 * time.Hour,
+		QuarantineCorrupt:      true,
+		ShutdownTimeout:        30 * time.Second,
+		Log:                    LogConfig{Format: "text", Level: "info"},
+	}
+}
+
+// Sous-dossiers internes du cache
+func (c *Config) PartialDir() string        { return filepath.Join(c.CacheDir, ".incomplete") } // Fichiers .part et bitfields en cours
+func (c *Config) ManifestDir() string       { return filepath.Join(c.CacheDir, ".manifests") }  // Manifests des fichiers téléchargés
+func (c *Config) StateDir() string          { return filepath.Join(c.CacheDir, ".state") }      // État persisté du daemon
+func (c *Config) QuarantineDir() string     { return filepath.Join(c.CacheDir, ".quarantine") } // Fichiers dont l'intégrité a échoué
+func (c *Config) ThumbnailCacheDir() string { return filepath.Join(c.CacheDir, ".thumbnails") } // Miniatures pour le catalogue hors ligne
+
+// setting relie un réglage à sa variable d'environnement et à son flag
+type setting struct {
+	env   string      // variable d'environnement
+	flag  string      // nom du flag
+	value interface{} // pointeur vers le champ de Config
+	usage string
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"PIPBINGO_API_ADDR", "api-addr", &c.APIAddr, "adresse de l'API HTTP locale"},
+		{"PIPBINGO_P2P_PORT", "p2p-port", &c.P2PPort, "port TCP du nœud libp2p"},
+		{"PIPBINGO_CACHE_DIR", "cache-dir", &c.CacheDir, "dossier du cache"},
+		{"PIPBINGO_SERVER_HTTP_URL", "server-http-url", &c.Server.HTTPURL, "URL HTTP du serveur central"},
+		{"PIPBINGO_SERVER_P2P_ADDR", "server-p2p-addr", &c.Server.P2PAddr, "multiaddr P2P du serveur central"},
+		{"PIPBINGO_PEERS", "peers", &c.Peers, "multiaddrs /p2p/ de peers à contacter au démarrage, séparées par des virgules"},
+		{"PIPBINGO_MAX_CONCURRENT_DOWNLOADS", "max-concurrent-downloads", &c.MaxConcurrentDownloads, "téléchargements simultanés"},
+		{"PIPBINGO_UPLOAD_RATE", "upload-rate", &c.UploadRate, "débit d'upload global en o/s (0 = illimité)"},
+		{"PIPBINGO_DOWNLOAD_RATE", "download-rate", &c.DownloadRate, "débit de download global en o/s (0 = illimité)"},
+		{"PIPBINGO_PEER_UPLOAD_RATE", "peer-upload-rate", &c.PeerUploadRate, "débit d'upload par peer en o/s (0 = illimité)"},
+		{"PIPBINGO_PEER_DOWNLOAD_RATE", "peer-download-rate", &c.PeerDownloadRate, "débit de download par peer en o/s (0 = illimité)"},
+		{"PIPBINGO_MAX_CACHE_SIZE", "max-cache-size", &c.MaxCacheSize, "taille max du cache en octets (0 = illimité)"},
+		{"PIPBINGO_DISK_RESERVE", "disk-reserve", &c.DiskReserve, "espace disque gardé libre en octets"},
+		{"PIPBINGO_MAX_SEED_RATIO", "max-seed-ratio", &c.MaxSeedRatio, "ratio upload/download avant d'arrêter le seeding (0 = illimité)"},
+		{"PIPBINGO_MAX_SEED_TIME", "max-seed-time", &c.MaxSeedTime, "durée de seeding max par fichier en secondes (0 = illimité)"},
+		{"PIPBINGO_MAX_SEEDING_FILES", "max-seeding-files", &c.MaxSeedingFiles, "fichiers seedés en même temps (0 = illimité)"},
+		{"PIPBINGO_MAX_UPLOAD_SLOTS", "max-upload-slots", &c.MaxUploadSlots, "chunks envoyés en même temps (0 = illimité)"},
+		{"PIPBINGO_UNCHOKE_SLOTS", "unchoke-slots", &c.UnchokeSlots, "peers servis en même temps"},
+		{"PIPBINGO_SCRUB_INTERVAL", "scrub-interval", &c.ScrubInterval, "revérification du cache (0 = au démarrage seulement)"},
+		{"PIPBINGO_QUARANTINE_CORRUPT", "quarantine-corrupt", &c.QuarantineCorrupt, "mettre en quarantaine plutôt que supprimer les fichiers invalides"},
+		{"PIPBINGO_SHUTDOWN_TIMEOUT", "shutdown-timeout", &c.ShutdownTimeout, "délai laissé aux transferts en cours à l'arrêt"},
+		{"PIPBINGO_LOG_FORMAT", "log-format", &c.Log.Format, "format des logs: text ou json"},
+		{"PIPBINGO_LOG_LEVEL", "log-level", &c.Log.Level, "niveau des logs: debug, info, warn ou error"},
+		{"PIPBINGO_LOG_LEVELS", "log-levels", &c.Log.Levels, "niveaux par sous-système (download=debug,p2p=warn)"},
+	}
+}
+
+// loadConfig construit la configuration effective à partir des arguments de la ligne de commande
+func loadConfig(args []string) (*Config, error) {
+	c := defaultConfig()
+	settings := c.settings()
+
+	fs := flag.NewFlagSet("pipbingo-daemon", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("PIPBINGO_CONFIG"), "fichier de configuration YAML")
+	raw := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		raw[s.flag] = fs.String(s.flag, shared.FormatValue(s.value), s.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// 1. Fichier YAML (facultatif s'il n'a pas été demandé explicitement)
+	path, explicit := *configPath, *configPath != ""
+	if !explicit {
+		path = DefaultConfigFile
+	}
+	if err := c.loadFile(path, explicit); err != nil {
+		return nil, err
+	}
+
+	// 2. Variables d'environnement
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok {
+			if err := shared.SetValue(s.value, value); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+
+	// 3. Flags passés explicitement
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && flagErr == nil {
+				if err := shared.SetValue(s.value, *raw[s.flag]); err != nil {
+					flagErr = fmt.Errorf("-%s: %w", s.flag, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	return c, c.validate()
+}
+
+// loadFile applique un fichier YAML; les clés inconnues sont refusées pour repérer les fautes de frappe
+func (c *Config) loadFile(path string, required bool) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("configuration: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("configuration %s: %w", path, err)
+	}
+	return nil
+}
+
+// validate vérifie la cohérence des réglages
+func (c *Config) validate() error {
+	var errs []error
+	if _, _, err := net.SplitHostPort(c.APIAddr); err != nil {
+		errs = append(errs, fmt.Errorf("api_addr invalide %q: %w", c.APIAddr, err))
+	}
+	if c.P2PPort < 1 || c.P2PPort > 65535 {
+		errs = append(errs, fmt.Errorf("p2p_port invalide: %d", c.P2PPort))
+	}
+	if c.CacheDir == "" {
+		errs = append(errs, fmt.Errorf("cache_dir vide"))
+	}
+	if u, err := url.Parse(c.Server.HTTPURL); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		errs = append(errs, fmt.Errorf("server.http_url invalide: %q", c.Server.HTTPURL))
+	}
+	if _, err := multiaddr.NewMultiaddr(c.Server.P2PAddr); err != nil {
+		errs = append(errs, fmt.Errorf("server.p2p_addr invalide %q: %w", c.Server.P2PAddr, err))
+	}
+	for _, addr := range c.Peers {
+		if _, err := multiaddr.NewMultiaddr(addr); err != nil {
+			errs = append(errs, fmt.Errorf("peer invalide %q: %w", addr, err))
+		}
+	}
+	if c.MaxConcurrentDownloads < 1 {
+		errs = append(errs, fmt.Errorf("max_concurrent_downloads doit être au moins 1"))
+	}
+	if c.MaxCacheSize < 0 || c.DiskReserve < 0 {
+		errs = append(errs, fmt.Errorf("taille du cache et réserve disque ne peuvent pas être négatives"))
+	}
+	if err := validateLimits(c.limits(), c.Schedule); err != nil {
+		errs = append(errs, fmt.Errorf("limites de débit: %w", err))
+	}
+	if c.MaxSeedRatio < 0 || c.MaxSeedTime < 0 || c.MaxSeedingFiles < 0 || c.MaxUploadSlots < 0 {
+		errs = append(errs, fmt.Errorf("les limites de seeding ne peuvent pas être négatives"))
+	}
+	if c.UnchokeSlots < 1 {
+		errs = append(errs, fmt.Errorf("unchoke_slots doit être au moins 1"))
+	}
+	if c.ScrubInterval < 0 {
+		errs = append(errs, fmt.Errorf("scrub_interval négatif"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdown_timeout doit être positif"))
+	}
+	errs = append(errs, shared.ValidateLogConfig(c.Log, logSubsystems)...)
+
+	return errors.Join(errs...)
+}
+
+// limits renvoie les limites de débit par défaut de la configuration
+func (c *Config) limits() Limits {
+	return Limits{
+		UploadRate:       c.UploadRate,
+		DownloadRate:     c.DownloadRate,
+		PeerUploadRate:   c.PeerUploadRate,
+		PeerDownloadRate: c.PeerDownloadRate,
+	}
+}
+
+// redacted renvoie une copie de la configuration sans les secrets
+func (c *Config) redacted() Config {
+	safe := *c
+	if u, err := url.Parse(safe.Server.HTTPURL); err == nil && u.User != nil {
+		u.User = url.User(u.User.Username())
+		safe.Server.HTTPURL = u.String()
+	}
+	return safe
+}
+
+// configView est la réponse de GET /config
+type configView struct {
+	Config
+	Overrides []string `json:"overrides,omitempty"` // clés dont la valeur vient de l'API et non de la configuration
+}
+
+// handleGetConfig renvoie la configuration effective, secrets masqués. Les réglages
+// modifiés par l'API (PUT /cache, PUT /seeding/policy, PUT /limits) y figurent avec la valeur utilisée.
+func (d *Daemon) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	view := configView{Config: cfg.redacted()}
+
+	d.cacheLock.Lock()
+	if d.cacheOverride != nil {
+		view.MaxCacheSize = d.maxCacheSize
+		view.Overrides = append(view.Overrides, "max_cache_size")
+	}
+	d.cacheLock.Unlock()
+
+	d.seedersLock.RLock()
+	if d.seedingOverride != nil {
+		limits := d.seedingLimits
+		view.MaxSeedRatio = limits.Default.MaxRatio
+		view.MaxSeedTime = limits.Default.MaxSeedTime
+		view.MaxSeedingFiles = limits.MaxSeedingFiles
+		view.MaxUploadSlots = limits.MaxUploadSlots
+		view.Overrides = append(view.Overrides, "max_seed_ratio", "max_seed_time", "max_seeding_files", "max_upload_slots")
+	}
+	d.seedersLock.RUnlock()
+
+	// Les limites de débit changées par PUT /limits ne durent que jusqu'au redémarrage
+	bandwidth := d.bandwidth.view()
+	if bandwidth.Limits != cfg.limits() || !slices.Equal(bandwidth.Schedule, cfg.Schedule) {
+		view.UploadRate = bandwidth.Limits.UploadRate
+		view.DownloadRate = bandwidth.Limits.DownloadRate
+		view.PeerUploadRate = bandwidth.Limits.PeerUploadRate
+		view.PeerDownloadRate = bandwidth.Limits.PeerDownloadRate
+		view.Schedule = bandwidth.Schedule
+		view.Overrides = append(view.Overrides, "upload_rate", "download_rate", "peer_upload_rate", "peer_download_rate", "schedule")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(view)
+}
+
+// ============================================
+// SURCHARGES PAR L'API
+// ============================================
+
+// override est un réglage modifié par l'API et persisté dans StateDir. Il garde la
+// valeur de configuration qu'il remplace: si la configuration a changé depuis,
+// elle reprend la main et la surcharge est abandonnée.
+type override[T comparable] struct {
+	Value  T `json:"value"`
+	Config T `json:"config"`
+}
+
+// newOverride renvoie la surcharge de config par value, nil si les deux sont égales
+func newOverride[T comparable](value, config T) *override[T] {
+	if value == config {
+		return nil
+	}
+	return &override[T]{Value: value, Config: config}
+}
+
+// resolve renvoie la valeur à appliquer et la surcharge encore valable (nil sinon)
+func (o *override[T]) resolve(config T) (T, *override[T]) {
+	if o == nil || o.Config != config {
+		return config, nil
+	}
+	return o.Value, o
+}
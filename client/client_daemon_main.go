@@ -3,13 +3,15 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -20,67 +22,58 @@ import (
 	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/multiformats/go-multiaddr"
 	"github.com/rs/cors"
+
+	"pipbingo/shared"
 )
 
 // ============================================
 // CONFIGURATION
 // ============================================
 
+// Réglages fixes; les réglages modifiables sont dans Config (client_config.go)
 const (
-	LocalAPIPort      = ":9090"
-	P2PListenPort     = 10001
-	CacheDir          = "./cache"
-	ServerHTTPURL     = "http://localhost:8080"
-	ServerP2PAddr     = "/ip4/127.0.0.1/tcp/10000"
-	P2PProtocolID     = "/pipbingo/get/1.0.0"
-	ChunkSize         = 256 * 1024 // 256 Ko
-	MaxConcurrentDL   = 3          // Téléchargements simultanés max
+	P2PProtocolID           = shared.ProtocolID
+	ChunkSize               = 256 * 1024             // Taille de chunk quand le manifest n'est pas disponible (256 Ko)
+	SwarmRefreshInterval    = 30 * time.Second       // Redécouverte des peers pendant un téléchargement
+	PlaybackWindow          = 16                     // Chunks prioritaires devant la position de lecture (4 Mo)
+	ParallelChunkRequests   = 4                      // Requêtes de chunks simultanées par téléchargement
+	UrgentChunkTimeout      = 5 * time.Second        // Délai max chez un peer pour un chunk de la fenêtre
+	ChunkRequestTimeout     = 30 * time.Second       // Délai max pour les autres chunks
+	MaxChunkFailures        = 5                      // Échecs consécutifs avant d'abandonner
+	StreamChunkTimeout      = 30 * time.Second       // Attente max d'un chunk pendant le streaming
+	SeedingCheckInterval    = time.Minute            // Vérification de la politique de seeding
+	ChokeInterval           = 10 * time.Second       // Période de réévaluation des peers servis
+	OptimisticUnchokeRounds = 3                      // Rechokes entre deux unchokes optimistes (30 s)
+	PeerInterestTimeout     = 30 * time.Second       // Un peer sans demande depuis ce délai n'est plus intéressé
+	ProgressEventInterval   = 500 * time.Millisecond // Événement de progression max par téléchargement
+	EventKeepAlive          = 15 * time.Second       // Commentaire SSE envoyé aux clients inactifs
+	CatalogRetryInterval    = 15 * time.Second       // Délai avant de se reconnecter au flux de changements du catalogue
+	CatalogStreamTimeout    = 45 * time.Second       // Flux de changements muet au-delà: serveur considéré injoignable
+	MaxThumbnailSize        = 5 << 20                // Taille max d'une miniature conservée (5 Mo)
 )
 
 // ============================================
 // MODÈLES
 // ============================================
 
-// DownloadStatus représente l'état d'un téléchargement
-type DownloadStatus struct {
-	Filename       string    `json:"filename"`
-	Status         string    `json:"status"` // downloading, seeding, completed, error
-	Progress       float64   `json:"progress"`
-	BytesDownloaded int64    `json:"bytes_downloaded"`
-	TotalBytes     int64     `json:"total_bytes"`
-	PeersConnected int       `json:"peers_connected"`
-	DownloadSpeed  float64   `json:"download_speed"` // Ko/s
-	StartedAt      time.Time `json:"started_at"`
-	CompletedAt    *time.Time `json:"completed_at,omitempty"`
-}
-
-// P2PRequest structure de requête P2P (doit correspondre au serveur)
-type P2PRequest struct {
-	Action     string `json:"action"`
-	Filename   string `json:"filename"`
-	ChunkIndex int    `json:"chunk_index"`
-}
+// Les types échangés avec le serveur, les autres daemons et les clients de l'API
+// locale sont définis dans le module partagé pipbingo/shared, utilisé aussi par le serveur
+type (
+	Video       = shared.Video
+	Manifest    = shared.Manifest
+	P2PRequest  = shared.P2PRequest
+	P2PResponse = shared.P2PResponse
+)
 
-// P2PResponse structure de réponse P2P
-type P2PResponse struct {
-	Status      string `json:"status"`
-	ChunkData   []byte `json:"chunk_data,omitempty"`
-	ChunkIndex  int    `json:"chunk_index"`
-	TotalChunks int    `json:"total_chunks"`
-	Error       string `json:"error,omitempty"`
-}
+// DownloadStatus représente l'état d'un téléchargement: l'état exposé par l'API
+// (shared.DownloadStatus) et ce qui ne sert qu'à l'ordonnancement
+type DownloadStatus struct {
+	shared.DownloadStatus
 
-// Video représente une vidéo (copié du serveur)
-type Video struct {
-	ID          string    `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Filename    string    `json:"filename"`
-	Thumbnail   string    `json:"thumbnail"`
-	Duration    int       `json:"duration"`
-	Size        int64     `json:"size"`
-	Creator     string    `json:"creator"`
-	UploadedAt  time.Time `json:"uploaded_at"`
+	queuedAt  time.Time          // Ordre d'arrivée dans la file
+	cancel    context.CancelFunc // Arrête le téléchargement en cours
+	stopAs    string             // État à appliquer après l'arrêt (paused ou cancelled)
+	lastEvent time.Time          // Dernier événement publié (limite les événements de progression)
 }
 
 // ============================================
@@ -88,32 +81,84 @@ type Video struct {
 // ============================================
 
 type Daemon struct {
-	p2pHost          host.Host
-	downloads        map[string]*DownloadStatus
-	downloadsLock    sync.RWMutex
-	serverPeerID     peer.ID
-	serverMultiAddr  multiaddr.Multiaddr
-	downloadQueue    chan string
-	activeSeeders    map[string]bool
-	seedersLock      sync.RWMutex
+	p2pHost         host.Host
+	p2p             *shared.P2PClient // Requêtes du protocole P2P vers le serveur et les peers
+	downloads       map[string]*DownloadStatus
+	downloadsLock   sync.RWMutex
+	serverPeerID    peer.ID
+	serverMultiAddr multiaddr.Multiaddr
+	maxConcurrent   int
+	activeSeeders   map[string]bool
+	seedStats       map[string]*SeedStats // Statistiques de seeding, y compris des fichiers arrêtés
+	seedingLimits   SeedingLimits
+	seedingOverride *override[SeedingLimits] // Limites fixées par PUT /seeding/policy
+	uploadsActive   int
+	seedersLock     sync.RWMutex
+	partials        map[string]*partialDownload
+	partialsLock    sync.RWMutex
+	swarm           *Swarm
+	playback        map[string]int64 // Position de lecture (octets) signalée par le player
+	playbackLock    sync.Mutex
+	bandwidth       *BandwidthManager
+	choker          *Choker
+	cacheEntries    map[string]*CacheEntry // Dernier accès et épinglage des vidéos du cache
+	cacheReserved   map[string]int64       // Place réservée par les téléchargements en cours
+	maxCacheSize    int64
+	cacheOverride   *override[int64] // Taille fixée par PUT /cache
+	cacheLock       sync.Mutex
+	scrub           *ScrubReport // Dernière vérification d'intégrité du cache
+	scrubLock       sync.Mutex
+	events          *EventHub
+	statsDirty      chan struct{} // Demande de republication des statistiques globales
+	catalog         catalogMirror // Copie locale du catalogue du serveur
+	catalogOnline   bool          // Flux de changements du serveur connecté
+	catalogLock     sync.Mutex
+	server          *shared.ServerClient // API HTTP du serveur central (catalogue)
+	thumbnailsLock  sync.Mutex
+	transfers       sync.WaitGroup // Téléchargements et streams P2P en cours, attendus à l'arrêt
+	shuttingDown    bool
+	transfersLock   sync.Mutex
 }
 
 func NewDaemon() *Daemon {
 	return &Daemon{
 		downloads:     make(map[string]*DownloadStatus),
-		downloadQueue: make(chan string, MaxConcurrentDL),
+		maxConcurrent: cfg.MaxConcurrentDownloads,
 		activeSeeders: make(map[string]bool),
+		seedStats:     make(map[string]*SeedStats),
+		seedingLimits: cfg.seedingLimits(),
+		partials:      make(map[string]*partialDownload),
+		swarm:         NewSwarm(),
+		playback:      make(map[string]int64),
+		bandwidth:     NewBandwidthManager(cfg.limits(), cfg.Schedule),
+		choker:        NewChoker(cfg.UnchokeSlots),
+		cacheEntries:  make(map[string]*CacheEntry),
+		cacheReserved: make(map[string]int64),
+		maxCacheSize:  cfg.MaxCacheSize,
+		events:        NewEventHub(),
+		statsDirty:    make(chan struct{}, 1),
+		catalog:       catalogMirror{Videos: make(map[string]*Video)},
+		server:        newServerClient(),
 	}
 }
 
+// newServerClient crée le client de l'API HTTP du serveur central
+func newServerClient() *shared.ServerClient {
+	client := shared.NewServerClient(cfg.Server.HTTPURL)
+	client.StreamIdleTimeout = CatalogStreamTimeout
+	return client
+}
+
 // ============================================
 // INITIALISATION
 // ============================================
 
 func (d *Daemon) Initialize() error {
-	// Créer le dossier cache
-	if err := os.MkdirAll(CacheDir, 0755); err != nil {
-		return fmt.Errorf("impossible de créer %s: %w", CacheDir, err)
+	// Créer le dossier cache et ses sous-dossiers internes
+	for _, dir := range []string{cfg.CacheDir, cfg.PartialDir(), cfg.ManifestDir(), cfg.StateDir(), cfg.QuarantineDir(), cfg.ThumbnailCacheDir()} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("impossible de créer %s: %w", dir, err)
+		}
 	}
 
 	// Initialiser le nœud P2P
@@ -121,26 +166,48 @@ func (d *Daemon) Initialize() error {
 		return fmt.Errorf("erreur P2P: %w", err)
 	}
 
-	// Se connecter au serveur
+	// Exposer l'état du daemon sur /metrics
+	d.registerDaemonMetrics()
+
+	// Se connecter au serveur; s'il est injoignable, le cache reste lisible hors ligne
 	if err := d.connectToServer(); err != nil {
-		return fmt.Errorf("erreur connexion serveur: %w", err)
+		logMain.Warn("serveur injoignable, démarrage hors ligne", "err", err)
 	}
 
-	// Démarrer les workers de téléchargement
-	d.startDownloadWorkers()
+	// Contacter les peers configurés, utiles quand le serveur n'est pas joignable
+	go d.connectToPeers()
+
+	// Garder une copie locale du catalogue pour naviguer hors ligne
+	d.loadCatalogMirror()
+	go d.syncCatalog()
+
+	// Vérifier l'intégrité du cache puis seeder les fichiers valides, selon la politique persistée
+	d.loadSeedingState()
+	d.loadCacheState()
+	d.startScrub(!cfg.QuarantineCorrupt)
+	go d.scheduleScrubs()
+	go d.enforceSeedingPolicies()
+
+	// Restaurer la file de téléchargements du dernier arrêt, puis reprendre les autres .part
+	d.restoreDownloads()
+	go d.resumeIncompleteDownloads()
 
-	// Démarrer le seeding des fichiers existants
-	go d.seedExistingFiles()
+	// Appliquer les plages horaires des limites de débit
+	go d.bandwidth.run()
 
-	log.Println("✅ Daemon initialisé avec succès")
+	// Choisir périodiquement les peers à qui envoyer des chunks
+	go d.choker.run()
+
+	// Pousser les statistiques globales aux clients de /events
+	go d.watchStats()
+
+	logMain.Info("daemon initialisé")
 	return nil
 }
 
 // initP2PNode crée le nœud libp2p local
 func (d *Daemon) initP2PNode() error {
-	ctx := context.Background()
-
-	listenAddr := fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", P2PListenPort)
+	listenAddr := fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", cfg.P2PPort)
 	addr, err := multiaddr.NewMultiaddr(listenAddr)
 	if err != nil {
 		return err
@@ -150,19 +217,20 @@ func (d *Daemon) initP2PNode() error {
 	h, err := libp2p.New(
 		libp2p.ListenAddrs(addr),
 		libp2p.EnableRelay(),
+		libp2p.BandwidthReporter(bandwidthCounter),
 	)
 	if err != nil {
 		return err
 	}
 
 	d.p2pHost = h
+	d.p2p = &shared.P2PClient{Host: h, WrapStream: d.bandwidth.wrap}
+	d.bandwidth.watchPeers(h.Network())
 
 	// Configurer le handler pour les requêtes entrantes (quand on seede)
 	h.SetStreamHandler(protocol.ID(P2PProtocolID), d.handleIncomingP2PRequest)
 
-	log.Printf("🌐 Nœud P2P client démarré")
-	log.Printf("   ID: %s", h.ID())
-	log.Printf("   Port: %d", P2PListenPort)
+	logP2P.Info("nœud P2P client démarré", "peer_id", h.ID(), "port", cfg.P2PPort)
 
 	return nil
 }
@@ -170,7 +238,7 @@ func (d *Daemon) initP2PNode() error {
 // connectToServer établit la connexion au serveur central
 func (d *Daemon) connectToServer() error {
 	// Parser l'adresse du serveur
-	serverAddr, err := multiaddr.NewMultiaddr(ServerP2PAddr)
+	serverAddr, err := multiaddr.NewMultiaddr(cfg.Server.P2PAddr)
 	if err != nil {
 		return err
 	}
@@ -181,10 +249,10 @@ func (d *Daemon) connectToServer() error {
 	addrInfo, err := peer.AddrInfoFromP2pAddr(serverAddr)
 	if err != nil {
 		// Si l'adresse n'a pas de peer ID, on essaie de se connecter quand même
-		log.Printf("⚠️ Pas de peer ID dans l'adresse, tentative de connexion directe...")
+		logMain.Warn("pas de peer ID dans l'adresse du serveur, tentative de connexion directe", "addr", cfg.Server.P2PAddr)
 	} else {
 		d.serverPeerID = addrInfo.ID
-		
+
 		// Se connecter
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
@@ -193,278 +261,424 @@ func (d *Daemon) connectToServer() error {
 			return fmt.Errorf("connexion au serveur échouée: %w", err)
 		}
 
-		log.Printf("✅ Connecté au serveur P2P: %s", d.serverPeerID)
+		logMain.Info("connecté au serveur P2P", "peer", d.serverPeerID)
 	}
 
 	return nil
 }
 
+// connectToPeers se connecte aux peers listés dans la configuration
+func (d *Daemon) connectToPeers() {
+	for _, addr := range cfg.Peers {
+		maddr, err := multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			continue // déjà refusé par validate
+		}
+		addrInfo, err := peer.AddrInfoFromP2pAddr(maddr)
+		if err != nil {
+			logMain.Warn("peer ignoré", "addr", addr, "err", err)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := d.p2pHost.Connect(ctx, *addrInfo); err != nil {
+			logMain.Warn("connexion au peer échouée", "peer", addrInfo.ID, "err", err)
+		} else {
+			logMain.Info("connecté au peer", "peer", addrInfo.ID)
+		}
+		cancel()
+	}
+}
+
 // ============================================
 // TÉLÉCHARGEMENT P2P
 // ============================================
 
-// DownloadAndSeed télécharge un fichier et commence à le seeder
-func (d *Daemon) DownloadAndSeed(filename string) error {
+// DownloadAndSeed met un fichier en file de téléchargement puis le seedera.
+// Ne bloque jamais: le gestionnaire démarre le téléchargement dès qu'une place se libère.
+func (d *Daemon) DownloadAndSeed(filename string, priority int) error {
+	filename = filepath.Base(filename)
+
 	// Vérifier si déjà en cache
-	cachedPath := filepath.Join(CacheDir, filename)
+	cachedPath := filepath.Join(cfg.CacheDir, filename)
 	if _, err := os.Stat(cachedPath); err == nil {
-		log.Printf("✅ Fichier déjà en cache: %s", filename)
+		logDownload.Info("fichier déjà en cache", "file", filename)
+		d.touchCache(filename)
 		d.startSeeding(filename)
 		return nil
 	}
 
-	// Créer le statut de téléchargement
 	d.downloadsLock.Lock()
-	d.downloads[filename] = &DownloadStatus{
-		Filename:       filename,
-		Status:         "downloading",
-		Progress:       0,
-		PeersConnected: 1,
-		StartedAt:      time.Now(),
+	if ds, exists := d.downloads[filename]; exists {
+		ds.Priority = priority
+		switch ds.Status {
+		case StatePaused, StateError:
+			// Une nouvelle demande relance un téléchargement arrêté
+			d.transition(ds, StateQueued)
+		default:
+			d.publishDownload(ds)
+		}
+		d.downloadsLock.Unlock()
+		d.schedule()
+		return nil
 	}
-	d.downloadsLock.Unlock()
 
-	// Ajouter à la queue
-	d.downloadQueue <- filename
+	// Créer le statut de téléchargement et l'ajouter à la file
+	ds := &DownloadStatus{
+		DownloadStatus: shared.DownloadStatus{
+			Filename:  filename,
+			Status:    StateQueued,
+			Priority:  priority,
+			StartedAt: time.Now(),
+		},
+		queuedAt: time.Now(),
+	}
+	d.downloads[filename] = ds
+	d.publishDownload(ds)
+	d.downloadsLock.Unlock()
 
+	logDownload.Info("téléchargement en file", "file", filename, "priority", priority)
+	d.schedule()
 	return nil
 }
 
-// startDownloadWorkers démarre les workers pour télécharger
-func (d *Daemon) startDownloadWorkers() {
-	for i := 0; i < MaxConcurrentDL; i++ {
-		go d.downloadWorker()
-	}
-}
+// performDownload effectue le téléchargement réel via P2P.
+// Les chunks sont écrits dans un fichier .part et le bitfield est persisté
+// après chaque chunk vérifié: un redémarrage reprend là où on s'était arrêté.
+func (d *Daemon) performDownload(ctx context.Context, filename string) error {
+	logDownload.Info("début du téléchargement P2P", "file", filename)
 
-// downloadWorker traite les téléchargements en queue
-func (d *Daemon) downloadWorker() {
-	for filename := range d.downloadQueue {
-		if err := d.performDownload(filename); err != nil {
-			log.Printf("❌ Erreur téléchargement %s: %v", filename, err)
-			d.updateDownloadStatus(filename, "error", 0)
-		} else {
-			log.Printf("✅ Téléchargement terminé: %s", filename)
-			d.updateDownloadStatus(filename, "completed", 100)
-			d.startSeeding(filename)
-		}
+	// Récupérer le manifest pour connaître la taille et les empreintes
+	manifest, err := d.fetchManifest(ctx, d.serverPeerID, filename)
+	if err != nil {
+		return err
 	}
-}
 
-// performDownload effectue le téléchargement réel via P2P
-func (d *Daemon) performDownload(filename string) error {
-	log.Printf("📥 Début du téléchargement P2P: %s", filename)
+	// Faire de la place dans le cache, quitte à évincer d'anciennes vidéos
+	if err := d.reserveCache(filename, manifest.Size); err != nil {
+		return err
+	}
+	defer d.releaseCache(filename)
 
-	// Ouvrir une connexion stream vers le serveur
-	ctx := context.Background()
-	stream, err := d.p2pHost.NewStream(ctx, d.serverPeerID, protocol.ID(P2PProtocolID))
+	// Ouvrir (ou reprendre) le fichier partiel
+	partial, err := openPartialDownload(filename, manifest)
 	if err != nil {
-		return fmt.Errorf("impossible d'ouvrir le stream: %w", err)
+		return err
 	}
-	defer stream.Close()
+	defer partial.close()
 
-	// Créer le fichier de destination
-	destPath := filepath.Join(CacheDir, filename)
-	destFile, err := os.Create(destPath)
-	if err != nil {
+	// Vérifier l'espace disque restant à remplir, quitte à évincer d'anciennes vidéos
+	if err := d.ensureDiskSpace(manifest.Size - partial.bytesHave()); err != nil {
 		return err
 	}
-	defer destFile.Close()
 
-	// Télécharger chunk par chunk
-	chunkIndex := 0
-	totalChunks := 0
-	startTime := time.Now()
+	totalChunks := manifest.TotalChunks()
+	if have := partial.bitfield().Count(); have > 0 {
+		logDownload.Info("reprise du téléchargement", "file", filename, "chunks_have", have, "total_chunks", totalChunks)
+	}
 
-	for {
-		// Envoyer la requête pour le chunk
-		request := P2PRequest{
-			Action:     "request_file",
-			Filename:   filename,
-			ChunkIndex: chunkIndex,
-		}
+	// Fenêtre de lecture en priorité, puis les chunks les plus rares du swarm
+	partial.picker = NewPiecePicker(totalChunks, PlaybackWindow, partial.has,
+		func(i int) int { return d.swarm.availability(filename, i) })
+	partial.picker.SetPosition(d.playbackChunk(filename, manifest))
 
-		encoder := json.NewEncoder(stream)
-		if err := encoder.Encode(request); err != nil {
-			return fmt.Errorf("erreur envoi requête chunk %d: %w", chunkIndex, err)
-		}
+	// Servir les chunks vérifiés pendant le téléchargement
+	d.registerPartial(partial)
+	defer d.unregisterPartial(filename)
 
-		// Lire la réponse
-		decoder := json.NewDecoder(stream)
-		var response P2PResponse
-		if err := decoder.Decode(&response); err != nil {
-			return fmt.Errorf("erreur lecture réponse chunk %d: %w", chunkIndex, err)
-		}
+	// Rejoindre le swarm pour télécharger aussi depuis les autres peers
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	d.joinSwarm(ctx, filename, partial.bitfield())
+	go d.refreshSwarm(ctx, partial)
 
-		// Vérifier le statut
-		if response.Status == "error" {
-			return fmt.Errorf("erreur serveur: %s", response.Error)
-		}
+	d.updateDownloadProgress(filename, partial, 0)
 
-		// Mémoriser le nombre total de chunks
-		if totalChunks == 0 {
-			totalChunks = response.TotalChunks
-		}
+	// Télécharger les chunks manquants avec plusieurs requêtes en parallèle
+	var (
+		fetched   int64
+		startTime = time.Now()
+		wg        sync.WaitGroup
+		errOnce   sync.Once
+		firstErr  error
+	)
 
-		// Écrire les données
-		if _, err := destFile.Write(response.ChunkData); err != nil {
-			return fmt.Errorf("erreur écriture chunk %d: %w", chunkIndex, err)
-		}
+	for w := 0; w < ParallelChunkRequests; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			failures := 0
+			for {
+				chunkIndex, urgent, reqCtx, ok := partial.picker.Next(ctx)
+				if !ok {
+					return
+				}
+
+				data, err := d.fetchChunk(reqCtx, partial, chunkIndex, urgent)
+				cancelled := reqCtx.Err() != nil && ctx.Err() == nil
+				partial.picker.Done(chunkIndex)
+
+				if err != nil {
+					if cancelled {
+						continue // Requête devenue inutile après un seek
+					}
+					if failures++; failures >= MaxChunkFailures {
+						errOnce.Do(func() {
+							firstErr = err
+							cancel()
+						})
+						return
+					}
+					continue
+				}
+				failures = 0
+				d.broadcastHave(filename, chunkIndex)
+
+				// Mettre à jour le progrès
+				total := atomic.AddInt64(&fetched, int64(len(data)))
+				speed := float64(total) / 1024 / time.Since(startTime).Seconds() // Ko/s
+				progress := d.updateDownloadProgress(filename, partial, speed)
+
+				logDownload.Debug("chunk reçu", "file", filename, "chunk", chunkIndex, "total_chunks", totalChunks,
+					"progress", fmt.Sprintf("%.1f%%", progress), "speed_kbps", fmt.Sprintf("%.2f", speed))
+			}
+		}()
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+
+	// Vérifier l'intégralité et déplacer dans le cache
+	return partial.finalize()
+}
 
-		// Mettre à jour le progrès
-		progress := float64(chunkIndex+1) / float64(totalChunks) * 100
-		bytesDownloaded := int64(chunkIndex+1) * ChunkSize
-		elapsed := time.Since(startTime).Seconds()
-		speed := float64(bytesDownloaded) / 1024 / elapsed // Ko/s
-
-		d.downloadsLock.Lock()
-		if status, exists := d.downloads[filename]; exists {
-			status.Progress = progress
-			status.BytesDownloaded = bytesDownloaded
-			status.TotalBytes = int64(totalChunks) * ChunkSize
-			status.DownloadSpeed = speed
-		}
-		d.downloadsLock.Unlock()
+// refreshSwarm redécouvre régulièrement les peers arrivés pendant un téléchargement
+func (d *Daemon) refreshSwarm(ctx context.Context, partial *partialDownload) {
+	ticker := time.NewTicker(SwarmRefreshInterval)
+	defer ticker.Stop()
 
-		log.Printf("   Chunk %d/%d (%.1f%%) - %.2f Ko/s", 
-			chunkIndex+1, totalChunks, progress, speed)
+	for {
+		select {
+		case <-ticker.C:
+			d.joinSwarm(ctx, partial.filename, partial.bitfield())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
 
-		// Dernier chunk ?
-		chunkIndex++
-		if chunkIndex >= totalChunks {
-			break
+// fetchChunk récupère un chunk auprès d'un peer qui le possède, puis du serveur en secours.
+// Un chunk urgent (fenêtre de lecture) n'attend pas longtemps un peer lent.
+// Le chunk est vérifié et persisté avant d'être renvoyé.
+func (d *Daemon) fetchChunk(ctx context.Context, partial *partialDownload, chunkIndex int, urgent bool) ([]byte, error) {
+	request := P2PRequest{
+		Action:     shared.ActionRequestFile,
+		Filename:   partial.filename,
+		ChunkIndex: chunkIndex,
+	}
+
+	source := d.pickSource(partial.filename, chunkIndex)
+	if source != d.serverPeerID {
+		timeout := ChunkRequestTimeout
+		if urgent {
+			timeout = UrgentChunkTimeout
+		}
+		peerCtx, cancel := context.WithTimeout(ctx, timeout)
+		response, err := d.p2p.Do(peerCtx, source, request)
+		cancel()
+		if err == nil {
+			if err = partial.writeChunk(chunkIndex, response.ChunkData); err == nil {
+				d.choker.RecordDownload(source, len(response.ChunkData))
+				return response.ChunkData, nil
+			}
+		}
+		logDownload.Debug("chunk indisponible chez le peer, repli sur le serveur",
+			"file", partial.filename, "chunk", chunkIndex, "peer", source.ShortString(), "err", err)
+		// Un peer occupé ou qui nous choke reste dans le swarm: il nous servira plus tard
+		switch {
+		case errors.Is(err, errPeerChoked):
+			d.swarm.setChokedBy(source)
+		case !errors.Is(err, errPeerBusy):
+			d.swarm.removePeer(partial.filename, source)
 		}
 
-		// Rouvrir le stream pour le prochain chunk
-		stream.Close()
-		stream, err = d.p2pHost.NewStream(ctx, d.serverPeerID, protocol.ID(P2PProtocolID))
-		if err != nil {
-			return fmt.Errorf("impossible de rouvrir le stream: %w", err)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
 		}
-		defer stream.Close()
 	}
 
-	return nil
+	reqCtx, cancel := context.WithTimeout(ctx, ChunkRequestTimeout)
+	defer cancel()
+	response, err := d.p2p.Do(reqCtx, d.serverPeerID, request)
+	if err != nil {
+		return nil, fmt.Errorf("erreur chunk %d: %w", chunkIndex, err)
+	}
+	if err := partial.writeChunk(chunkIndex, response.ChunkData); err != nil {
+		return nil, err
+	}
+	return response.ChunkData, nil
 }
 
-// updateDownloadStatus met à jour le statut
-func (d *Daemon) updateDownloadStatus(filename, status string, progress float64) {
-	d.downloadsLock.Lock()
-	defer d.downloadsLock.Unlock()
-
-	if ds, exists := d.downloads[filename]; exists {
-		ds.Status = status
-		ds.Progress = progress
-		if status == "completed" {
-			now := time.Now()
-			ds.CompletedAt = &now
+// connectedPeers compte les sources d'un fichier réellement connectées: peers du swarm et serveur
+func (d *Daemon) connectedPeers(filename string) int {
+	n := d.p2pHost.Network()
+	count := 0
+	server := false
+	for _, id := range d.swarm.peers(filename) {
+		if n.Connectedness(id) == network.Connected {
+			count++
+			server = server || id == d.serverPeerID
 		}
 	}
+	if !server && d.serverPeerID != "" && n.Connectedness(d.serverPeerID) == network.Connected {
+		count++
+	}
+	return count
 }
 
-// ============================================
-// SEEDING
-// ============================================
+// updateDownloadProgress recalcule la progression à partir du bitfield
+func (d *Daemon) updateDownloadProgress(filename string, partial *partialDownload, speed float64) float64 {
+	bytesDownloaded := partial.bytesHave()
+	progress := 100.0
+	if partial.manifest.Size > 0 {
+		progress = float64(bytesDownloaded) / float64(partial.manifest.Size) * 100
+	}
 
-// startSeeding commence à seeder un fichier
-func (d *Daemon) startSeeding(filename string) {
-	d.seedersLock.Lock()
-	d.activeSeeders[filename] = true
-	d.seedersLock.Unlock()
+	d.downloadsLock.Lock()
+	if status, exists := d.downloads[filename]; exists {
+		status.Progress = progress
+		status.BytesDownloaded = bytesDownloaded
+		status.TotalBytes = partial.manifest.Size
+		status.DownloadSpeed = speed
+		status.PeersConnected = d.connectedPeers(filename)
+		if time.Since(status.lastEvent) >= ProgressEventInterval || progress >= 100 {
+			d.publishDownload(status)
+		}
+	}
+	d.downloadsLock.Unlock()
 
-	d.updateDownloadStatus(filename, "seeding", 100)
-	log.Printf("🌱 Début du seeding: %s", filename)
+	return progress
 }
 
-// seedExistingFiles seede tous les fichiers déjà en cache
-func (d *Daemon) seedExistingFiles() {
-	files, err := os.ReadDir(CacheDir)
+// fetchManifest demande le manifest d'un fichier à un peer
+func (d *Daemon) fetchManifest(ctx context.Context, peerID peer.ID, filename string) (*Manifest, error) {
+	manifest, err := d.p2p.Manifest(ctx, peerID, filename)
 	if err != nil {
-		log.Printf("⚠️ Impossible de lire le cache: %v", err)
-		return
-	}
-
-	for _, file := range files {
-		if !file.IsDir() {
-			d.startSeeding(file.Name())
-		}
+		return nil, fmt.Errorf("manifest indisponible pour %s: %w", filename, err)
 	}
 
-	log.Printf("🌱 Seeding de %d fichiers existants", len(files))
+	return manifest, nil
 }
 
-// handleIncomingP2PRequest gère les requêtes P2P entrantes (quand on seede)
+// ============================================
+// SEEDING
+// ============================================
+
+// handleIncomingP2PRequest gère les requêtes P2P entrantes (seeding et swarm)
 func (d *Daemon) handleIncomingP2PRequest(stream network.Stream) {
+	if !d.beginTransfer() {
+		stream.Reset()
+		return
+	}
+	defer d.endTransfer()
 	defer stream.Close()
 
+	metrics.p2p.ActiveStreams.Inc()
+	defer metrics.p2p.ActiveStreams.Dec()
+	call := &shared.P2PCall{
+		Stream: d.bandwidth.wrap(stream),
+		Log:    logP2P.With("stream_id", shared.NewCorrelationID(), "peer", stream.Conn().RemotePeer().ShortString()),
+	}
+	start := time.Now()
+
 	// Lire la requête
-	decoder := json.NewDecoder(stream)
+	decoder := json.NewDecoder(call)
 	var req P2PRequest
 	if err := decoder.Decode(&req); err != nil {
-		log.Printf("❌ Erreur décodage requête P2P: %v", err)
+		call.Log.Warn("requête P2P illisible", "err", err)
+		d.sendP2PError(call, shared.CodeInvalidRequest, shared.Details{"reason": err.Error()})
+		metrics.p2p.Observe("", call, start)
 		return
 	}
-
-	// Vérifier qu'on possède le fichier
-	d.seedersLock.RLock()
-	isSeeding := d.activeSeeders[req.Filename]
-	d.seedersLock.RUnlock()
-
-	if !isSeeding {
-		d.sendP2PError(stream, "file_not_available")
-		return
+	defer metrics.p2p.Observe(req.Action, call, start)
+	call.Log = call.Log.With("action", req.Action, "file", req.Filename)
+	call.Log.Debug("requête P2P reçue", "chunk", req.ChunkIndex)
+
+	// Traiter selon l'action
+	switch req.Action {
+	case shared.ActionRequestFile:
+		d.handleChunkRequest(call, req)
+	case shared.ActionGetManifest:
+		d.handleManifestRequest(call, req)
+	case shared.ActionBitfield:
+		d.handleBitfieldRequest(call, req)
+	case shared.ActionHave:
+		d.handleHaveRequest(call, req)
+	default:
+		d.sendP2PError(call, shared.CodeUnknownAction, shared.Details{"action": req.Action})
 	}
-
-	// Envoyer le chunk demandé
-	d.sendFileChunk(stream, req)
 }
 
 // sendFileChunk envoie un chunk de fichier
 func (d *Daemon) sendFileChunk(stream network.Stream, req P2PRequest) {
-	filePath := filepath.Join(CacheDir, filepath.Base(req.Filename))
+	filePath := filepath.Join(cfg.CacheDir, filepath.Base(req.Filename))
 
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
-		d.sendP2PError(stream, "file_not_found")
+		d.sendP2PError(stream, shared.CodeFileNotFound, shared.Details{"filename": filepath.Base(req.Filename)})
 		return
 	}
 
-	totalChunks := int(fileInfo.Size()/ChunkSize) + 1
+	// La taille des chunks est fixée par le serveur dans le manifest
+	chunkSize := int64(ChunkSize)
+	if manifest, err := loadManifest(filepath.Base(req.Filename)); err == nil && manifest.ChunkSize > 0 {
+		chunkSize = int64(manifest.ChunkSize)
+	}
+
+	totalChunks := int((fileInfo.Size() + chunkSize - 1) / chunkSize)
+	if req.ChunkIndex < 0 || req.ChunkIndex >= totalChunks {
+		d.sendP2PError(stream, shared.CodeInvalidChunk, shared.Details{"chunk_index": req.ChunkIndex, "total_chunks": totalChunks})
+		return
+	}
 
 	file, err := os.Open(filePath)
 	if err != nil {
-		d.sendP2PError(stream, "read_error")
+		d.sendP2PError(stream, shared.CodeReadError, nil)
 		return
 	}
 	defer file.Close()
 
 	// Se positionner au bon chunk
-	offset := int64(req.ChunkIndex) * ChunkSize
+	offset := int64(req.ChunkIndex) * chunkSize
 	file.Seek(offset, 0)
 
 	// Lire le chunk
-	chunkData := make([]byte, ChunkSize)
+	chunkData := make([]byte, chunkSize)
 	n, _ := file.Read(chunkData)
 
 	// Envoyer la réponse
 	response := P2PResponse{
-		Status:      "success",
+		Status:      shared.StatusSuccess,
 		ChunkData:   chunkData[:n],
 		ChunkIndex:  req.ChunkIndex,
 		TotalChunks: totalChunks,
 	}
 
-	json.NewEncoder(stream).Encode(response)
-	log.Printf("📤 Chunk %d/%d envoyé pour %s", req.ChunkIndex+1, totalChunks, req.Filename)
+	if err := json.NewEncoder(stream).Encode(response); err != nil {
+		shared.SetP2PResult(stream, "send_error")
+		return
+	}
+	d.recordUpload(filepath.Base(req.Filename), stream.Conn().RemotePeer(), n)
+	streamLogger(stream).Debug("chunk envoyé", "chunk", req.ChunkIndex, "total_chunks", totalChunks, "bytes", n)
 }
 
-// sendP2PError envoie une erreur P2P
-func (d *Daemon) sendP2PError(stream network.Stream, errMsg string) {
-	response := P2PResponse{
-		Status: "error",
-		Error:  errMsg,
-	}
-	json.NewEncoder(stream).Encode(response)
+// sendP2PError envoie une erreur P2P: code, message et détails (voir shared.P2PErrorResponse)
+func (d *Daemon) sendP2PError(stream network.Stream, code string, details shared.Details) {
+	shared.SetP2PResult(stream, code)
+	json.NewEncoder(stream).Encode(shared.P2PErrorResponse(code, details))
 }
 
 // ============================================
@@ -475,15 +689,16 @@ func (d *Daemon) sendP2PError(stream network.Stream, errMsg string) {
 func (d *Daemon) handleDownloadRequest(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Filename string `json:"filename"`
+		Priority int    `json:"priority"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+		shared.WriteError(w, r, shared.CodeInvalidRequest, shared.Details{"reason": err.Error()})
 		return
 	}
 
-	if err := d.DownloadAndSeed(req.Filename); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := d.DownloadAndSeed(req.Filename, req.Priority); err != nil {
+		shared.WriteError(w, r, shared.CodeInternal, shared.Details{"reason": err.Error()})
 		return
 	}
 
@@ -503,88 +718,204 @@ func (d *Daemon) handleStatusRequest(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(d.downloads)
 }
 
-// handleStreamRequest sert un fichier en cache
+// handleStreamRequest sert un fichier en cache, ou en cours de téléchargement
 func (d *Daemon) handleStreamRequest(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	filename := vars["filename"]
+	filename := filepath.Base(vars["filename"])
 
-	filePath := filepath.Join(CacheDir, filepath.Base(filename))
+	filePath := filepath.Join(cfg.CacheDir, filename)
 
-	// Vérifier l'existence
-	if _, err := os.Stat(filePath); err != nil {
-		http.Error(w, "File not in cache", http.StatusNotFound)
+	// Fichier complet: le servir directement
+	if _, err := os.Stat(filePath); err == nil {
+		d.touchCache(filename)
+		http.ServeFile(w, r, filePath)
 		return
 	}
 
-	// Servir le fichier
-	http.ServeFile(w, r, filePath)
+	// Téléchargement en cours (ou sur le point de démarrer): streamer les chunks vérifiés
+	if partial := d.waitForPartial(r.Context(), filename); partial != nil {
+		d.touchCache(filename)
+		d.servePartial(w, r, partial)
+		return
+	}
+
+	// Téléchargement en pause: streamer les chunks déjà vérifiés du .part
+	d.downloadsLock.RLock()
+	status, exists := d.downloads[filename]
+	paused := exists && status.Status == StatePaused
+	d.downloadsLock.RUnlock()
+	if paused {
+		if partial, err := openPartialSnapshot(filename); err == nil {
+			d.touchCache(filename)
+			d.servePartial(w, r, partial)
+			return
+		}
+	}
+
+	shared.WriteError(w, r, shared.CodeNotInCache, shared.Details{"filename": filename})
 }
 
+// StatsSnapshot regroupe les statistiques P2P globales (pipbingo/shared)
+type StatsSnapshot = shared.StatsSnapshot
+
 // handleStatsRequest renvoie les statistiques P2P
 func (d *Daemon) handleStatsRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d.statsSnapshot())
+}
+
+// statsSnapshot calcule les statistiques P2P globales
+func (d *Daemon) statsSnapshot() StatsSnapshot {
 	d.seedersLock.RLock()
 	seedingCount := len(d.activeSeeders)
 	d.seedersLock.RUnlock()
 
+	cacheCount := 0
+	if files, err := os.ReadDir(cfg.CacheDir); err == nil {
+		for _, file := range files {
+			if !file.IsDir() {
+				cacheCount++
+			}
+		}
+	}
+
 	d.downloadsLock.RLock()
-	downloadingCount := 0
+	downloadingCount, queuedCount := 0, 0
 	for _, status := range d.downloads {
-		if status.Status == "downloading" {
+		switch status.Status {
+		case StateDownloading:
 			downloadingCount++
+		case StateQueued:
+			queuedCount++
 		}
 	}
+	maxConcurrent := d.maxConcurrent
 	d.downloadsLock.RUnlock()
 
-	stats := map[string]interface{}{
-		"peer_id":          d.p2pHost.ID().String(),
-		"connected_peers":  len(d.p2pHost.Network().Peers()),
-		"seeding_files":    seedingCount,
-		"downloading_files": downloadingCount,
-		"cache_files":      seedingCount,
+	return StatsSnapshot{
+		PeerID:                 d.p2pHost.ID().String(),
+		ConnectedPeers:         len(d.p2pHost.Network().Peers()),
+		SeedingFiles:           seedingCount,
+		DownloadingFiles:       downloadingCount,
+		QueuedFiles:            queuedCount,
+		CacheFiles:             cacheCount,
+		MaxConcurrentDownloads: maxConcurrent,
+		UnchokedPeers:          d.choker.unchokedCount(),
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(stats)
 }
 
 // ============================================
-// MAIN
+// ROUTEUR HTTP
 // ============================================
 
-func main() {
-	log.Println("🚀 Démarrage du pip bin Go Client Daemon...")
-
-	daemon := NewDaemon()
-	if err := daemon.Initialize(); err != nil {
-		log.Fatalf("❌ Erreur initialisation: %v", err)
-	}
-
-	// Configurer le routeur
+// newRouter déclare les routes de l'API; chacune doit être décrite dans client_openapi.go
+func newRouter(daemon *Daemon) *mux.Router {
 	router := mux.NewRouter()
+	router.NotFoundHandler = shared.NotFoundHandler()
+	router.MethodNotAllowedHandler = shared.MethodNotAllowedHandler()
 
 	// Routes API
 	router.HandleFunc("/download", daemon.handleDownloadRequest).Methods("POST")
 	router.HandleFunc("/status", daemon.handleStatusRequest).Methods("GET")
+	router.HandleFunc("/downloads", daemon.handleListDownloads).Methods("GET")
+	router.HandleFunc("/downloads/concurrency", daemon.handleDownloadConcurrency).Methods("PUT")
+	router.HandleFunc("/downloads/{id}", daemon.handleGetDownload).Methods("GET")
+	router.HandleFunc("/downloads/{id}", daemon.handleCancelDownload).Methods("DELETE")
+	router.HandleFunc("/downloads/{id}/pause", daemon.handlePauseDownload).Methods("POST")
+	router.HandleFunc("/downloads/{id}/resume", daemon.handleResumeDownload).Methods("POST")
+	router.HandleFunc("/downloads/{id}/priority", daemon.handleDownloadPriority).Methods("POST")
 	router.HandleFunc("/stats", daemon.handleStatsRequest).Methods("GET")
+	router.HandleFunc("/config", daemon.handleGetConfig).Methods("GET")
+	router.Handle("/metrics", handleMetrics()).Methods("GET")
+	router.HandleFunc("/events", daemon.handleEvents).Methods("GET")
+	router.HandleFunc("/catalog", daemon.handleGetCatalog).Methods("GET")
+	router.HandleFunc("/catalog/thumbnails/{name}", daemon.handleCatalogThumbnail).Methods("GET")
 	router.HandleFunc("/stream/{filename}", daemon.handleStreamRequest).Methods("GET")
+	router.HandleFunc("/playback/{filename}", daemon.handlePlaybackRequest).Methods("POST")
+	router.HandleFunc("/limits", daemon.handleGetLimits).Methods("GET")
+	router.HandleFunc("/limits", daemon.handleSetLimits).Methods("PUT")
+	router.HandleFunc("/peers", daemon.handlePeersRequest).Methods("GET")
+	router.HandleFunc("/cache", daemon.handleGetCache).Methods("GET")
+	router.HandleFunc("/cache", daemon.handleSetCache).Methods("PUT")
+	router.HandleFunc("/cache/{filename}/pin", daemon.handlePinRequest).Methods("POST", "DELETE")
+	router.HandleFunc("/scrub", daemon.handleGetScrub).Methods("GET")
+	router.HandleFunc("/scrub", daemon.handleStartScrub).Methods("POST")
+	router.HandleFunc("/seeding", daemon.handleGetSeeding).Methods("GET")
+	router.HandleFunc("/seeding/policy", daemon.handleSetSeedingLimits).Methods("PUT")
+	router.HandleFunc("/seeding/{filename}/policy", daemon.handleSetFilePolicy).Methods("PUT")
+	router.HandleFunc("/seeding/{filename}/start", daemon.handleStartSeeding).Methods("POST")
+	router.HandleFunc("/seeding/{filename}/stop", daemon.handleStopSeeding).Methods("POST")
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	}).Methods("GET")
+	router.HandleFunc("/health/live", daemon.handleLive).Methods("GET")
+	router.HandleFunc("/health/ready", daemon.handleReady).Methods("GET")
+	router.HandleFunc("/openapi.json", handleOpenAPI()).Methods("GET")
+
+	return router
+}
+
+// ============================================
+// MAIN
+// ============================================
+
+func main() {
+	// Charger la configuration: défauts, fichier YAML, environnement puis flags
+	loaded, err := loadConfig(os.Args[1:])
+	if err != nil {
+		fatal("configuration invalide", "err", err)
+	}
+	cfg = loaded
+	setupLogging(cfg.Log)
+	logMain.Info("démarrage du pip bin Go Client Daemon")
+
+	daemon := NewDaemon()
+	if err := daemon.Initialize(); err != nil {
+		fatal("initialisation impossible", "err", err)
+	}
+
+	// Configurer le routeur HTTP
+	router := newRouter(daemon)
 
 	// CORS
 	corsHandler := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"*"},
+		ExposedHeaders:   []string{"X-Request-ID"},
 		AllowCredentials: true,
 	})
 
-	log.Printf("🌐 API locale démarrée sur http://localhost%s", LocalAPIPort)
-	log.Printf("🔗 Nœud P2P actif sur le port %d", P2PListenPort)
-	log.Println("📡 Prêt à télécharger et seeder des vidéos!")
+	logMain.Info("prêt à télécharger et seeder des vidéos", "api", "http://localhost"+cfg.APIAddr, "p2p_port", cfg.P2PPort)
 
-	if err := http.ListenAndServe(LocalAPIPort, corsHandler.Handler(router)); err != nil {
-		log.Fatalf("❌ Erreur serveur HTTP: %v", err)
+	// Arrêt propre sur SIGINT ou SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Chaque requête reçoit un request_id repris dans ses logs
+	httpServer := &http.Server{Addr: cfg.APIAddr, Handler: shared.WithRequestLogging(logHTTP, corsHandler.Handler(router))}
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("serveur HTTP arrêté", "err", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop() // Un second signal arrête immédiatement le processus
+	logMain.Info("arrêt demandé, fin des transferts en cours", "timeout", cfg.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// Plus de nouveau travail, puis les requêtes HTTP (streams vidéo) et les transferts P2P se terminent
+	daemon.stopAccepting()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		logMain.Warn("requêtes HTTP interrompues", "err", err)
+		httpServer.Close()
+	}
+	if err := daemon.Shutdown(shutdownCtx); err != nil {
+		logMain.Warn("fermeture du nœud P2P", "err", err)
 	}
+	logMain.Info("daemon arrêté")
 }
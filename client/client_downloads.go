@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// GESTIONNAIRE DE TÉLÉCHARGEMENTS
+// ============================================
+
+// États d'un téléchargement (pipbingo/shared)
+const (
+	StateQueued      = shared.StateQueued
+	StateDownloading = shared.StateDownloading
+	StatePaused      = shared.StatePaused
+	StateCompleted   = shared.StateCompleted
+	StateSeeding     = shared.StateSeeding
+	StateError       = shared.StateError
+	StateCancelled   = shared.StateCancelled
+)
+
+// downloadTransitions liste les changements d'état autorisés.
+// Tout changement d'état passe par transition(), qui s'appuie sur cette table.
+var downloadTransitions = map[string][]string{
+	StateQueued:      {StateDownloading, StatePaused, StateCancelled},
+	StateDownloading: {StateCompleted, StatePaused, StateError, StateCancelled},
+	StatePaused:      {StateQueued, StateCompleted, StateCancelled}, // Completed: fichier déjà dans le cache à la reprise
+	StateCompleted:   {StateSeeding, StateCancelled},
+	StateSeeding:     {StateCompleted, StateCancelled},
+	StateError:       {StateQueued, StateCompleted, StateCancelled},
+}
+
+var (
+	errDownloadNotFound  = errors.New("téléchargement introuvable")
+	errInvalidTransition = errors.New("changement d'état impossible")
+)
+
+// canTransition indique si le passage de from à to est autorisé
+func canTransition(from, to string) bool {
+	for _, allowed := range downloadTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transition change l'état d'un téléchargement (appelé sous downloadsLock)
+func (d *Daemon) transition(ds *DownloadStatus, to string) error {
+	if !canTransition(ds.Status, to) {
+		return fmt.Errorf("%w: %s → %s", errInvalidTransition, ds.Status, to)
+	}
+
+	from := ds.Status
+	ds.Status = to
+
+	switch to {
+	case StateQueued:
+		ds.queuedAt = time.Now()
+		ds.Error = ""
+		ds.ErrorCode = ""
+	case StateDownloading:
+		ds.StartedAt = time.Now()
+	case StateCompleted:
+		now := time.Now()
+		ds.CompletedAt = &now
+		ds.Progress = 100
+	case StateCancelled:
+		// Un téléchargement annulé disparaît de la liste
+		delete(d.downloads, ds.Filename)
+	}
+
+	if to != StateDownloading {
+		ds.DownloadSpeed = 0
+	}
+
+	logDownload.Debug("changement d'état", "file", ds.Filename, "from", from, "to", to)
+	d.publishDownload(ds)
+	return nil
+}
+
+// schedule démarre les téléchargements en attente tant qu'il reste des places
+func (d *Daemon) schedule() {
+	d.downloadsLock.Lock()
+	defer d.downloadsLock.Unlock()
+
+	running := 0
+	var queued []*DownloadStatus
+	for _, ds := range d.downloads {
+		switch ds.Status {
+		case StateDownloading:
+			running++
+		case StateQueued:
+			queued = append(queued, ds)
+		}
+	}
+
+	// Priorité la plus haute d'abord, puis le plus ancien dans la file
+	sort.Slice(queued, func(i, j int) bool {
+		if queued[i].Priority != queued[j].Priority {
+			return queued[i].Priority > queued[j].Priority
+		}
+		return queued[i].queuedAt.Before(queued[j].queuedAt)
+	})
+
+	for _, ds := range queued {
+		if running >= d.maxConcurrent {
+			break
+		}
+		// Pendant l'arrêt, la file reste en l'état pour le prochain démarrage
+		if !d.beginTransfer() {
+			return
+		}
+		if err := d.transition(ds, StateDownloading); err != nil {
+			d.endTransfer()
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ds.cancel = cancel
+		ds.stopAs = ""
+		running++
+
+		go func(filename string) {
+			defer d.endTransfer()
+			d.runDownload(ctx, filename)
+		}(ds.Filename)
+	}
+}
+
+// runDownload exécute un téléchargement et applique l'état final
+func (d *Daemon) runDownload(ctx context.Context, filename string) {
+	err := d.performDownload(ctx, filename)
+
+	d.downloadsLock.Lock()
+	ds, exists := d.downloads[filename]
+	if !exists {
+		d.downloadsLock.Unlock()
+		d.schedule()
+		return
+	}
+	ds.cancel = nil
+
+	// Un téléchargement qui a abouti pendant qu'on l'arrêtait est terminé: le fichier
+	// est déjà dans le cache (pause de l'arrêt du daemon comprise)
+	switch {
+	case err == nil:
+		logDownload.Info("téléchargement terminé", "file", filename)
+		d.transition(ds, StateCompleted)
+	case ds.stopAs == StatePaused:
+		logDownload.Info("téléchargement en pause", "file", filename)
+		d.transition(ds, StatePaused)
+	case ds.stopAs == StateCancelled:
+		logDownload.Info("téléchargement annulé", "file", filename)
+		d.transition(ds, StateCancelled)
+		removePartialFiles(filename)
+	default:
+		logDownload.Error("téléchargement échoué", "file", filename, "err", err)
+		ds.Error = err.Error()
+		if errors.Is(err, errInsufficientStorage) {
+			ds.ErrorCode = shared.CodeInsufficientStorage
+		}
+		d.transition(ds, StateError)
+	}
+	completed := ds.Status == StateCompleted
+	metrics.downloads.WithLabelValues(ds.Status).Inc()
+	d.downloadsLock.Unlock()
+
+	if completed {
+		d.touchCache(filename)
+		d.startSeeding(filename)
+		go d.mirrorThumbnails()
+	}
+
+	d.schedule()
+}
+
+// PauseDownload met un téléchargement en attente ou en cours en pause
+func (d *Daemon) PauseDownload(filename string) error {
+	return d.stopDownload(filename, StatePaused)
+}
+
+// CancelDownload annule un téléchargement, supprime ses données partielles et son entrée.
+// Pour un téléchargement terminé, seule l'entrée est retirée: le fichier reste en cache.
+func (d *Daemon) CancelDownload(filename string) error {
+	return d.stopDownload(filename, StateCancelled)
+}
+
+// stopDownload applique une pause ou une annulation
+func (d *Daemon) stopDownload(filename, to string) error {
+	d.downloadsLock.Lock()
+	defer d.downloadsLock.Unlock()
+
+	ds, exists := d.downloads[filename]
+	if !exists {
+		return errDownloadNotFound
+	}
+
+	// En cours: le worker appliquera l'état une fois le téléchargement arrêté
+	if ds.Status == StateDownloading {
+		if !canTransition(ds.Status, to) {
+			return fmt.Errorf("%w: %s → %s", errInvalidTransition, ds.Status, to)
+		}
+		ds.stopAs = to
+		if ds.cancel != nil {
+			ds.cancel()
+		}
+		return nil
+	}
+
+	from := ds.Status
+	if err := d.transition(ds, to); err != nil {
+		return err
+	}
+	// Terminé, le fichier est dans le cache et toujours seedé avec son manifest:
+	// seule l'entrée est oubliée (l'éviction passe par le cache)
+	if to == StateCancelled && from != StateCompleted && from != StateSeeding {
+		removePartialFiles(filename)
+	}
+	return nil
+}
+
+// ResumeDownload remet en file un téléchargement en pause ou en erreur. Si le fichier
+// est déjà dans le cache, le téléchargement passe directement à terminé.
+func (d *Daemon) ResumeDownload(filename string) error {
+	_, statErr := os.Stat(filepath.Join(cfg.CacheDir, filename))
+	cached := statErr == nil
+
+	d.downloadsLock.Lock()
+	ds, exists := d.downloads[filename]
+	if !exists {
+		d.downloadsLock.Unlock()
+		return errDownloadNotFound
+	}
+	to := StateQueued
+	if cached {
+		to = StateCompleted
+	}
+	err := d.transition(ds, to)
+	if err == nil && cached {
+		logDownload.Info("fichier déjà dans le cache, téléchargement terminé", "file", filename)
+		metrics.downloads.WithLabelValues(ds.Status).Inc()
+	}
+	d.downloadsLock.Unlock()
+
+	if err != nil {
+		return err
+	}
+	if cached {
+		d.touchCache(filename)
+		d.startSeeding(filename)
+		return nil
+	}
+	d.schedule()
+	return nil
+}
+
+// SetDownloadPriority change la priorité d'un téléchargement (la plus haute part en premier)
+func (d *Daemon) SetDownloadPriority(filename string, priority int) error {
+	d.downloadsLock.Lock()
+	ds, exists := d.downloads[filename]
+	if exists {
+		ds.Priority = priority
+		d.publishDownload(ds)
+	}
+	d.downloadsLock.Unlock()
+
+	if !exists {
+		return errDownloadNotFound
+	}
+	d.schedule()
+	return nil
+}
+
+// SetMaxConcurrentDownloads change le nombre de téléchargements simultanés.
+// Les téléchargements déjà lancés au-delà de la limite se terminent normalement.
+func (d *Daemon) SetMaxConcurrentDownloads(n int) error {
+	if n < 1 {
+		return fmt.Errorf("au moins un téléchargement simultané requis")
+	}
+
+	d.downloadsLock.Lock()
+	d.maxConcurrent = n
+	d.downloadsLock.Unlock()
+
+	d.schedule()
+	return nil
+}
+
+// ============================================
+// API HTTP DES TÉLÉCHARGEMENTS
+// ============================================
+
+// writeDownloadError traduit une erreur du gestionnaire en code d'erreur
+func writeDownloadError(w http.ResponseWriter, r *http.Request, err error) {
+	details := shared.Details{"reason": err.Error()}
+	switch {
+	case errors.Is(err, errDownloadNotFound):
+		shared.WriteError(w, r, shared.CodeDownloadNotFound, details)
+	case errors.Is(err, errInvalidTransition):
+		shared.WriteError(w, r, shared.CodeInvalidTransition, details)
+	default:
+		shared.WriteError(w, r, shared.CodeInvalidRequest, details)
+	}
+}
+
+// writeDownload renvoie l'état courant d'un téléchargement
+func (d *Daemon) writeDownload(w http.ResponseWriter, filename string) {
+	d.downloadsLock.RLock()
+	ds, exists := d.downloads[filename]
+	var snapshot DownloadStatus
+	if exists {
+		snapshot = *ds
+	}
+	d.downloadsLock.RUnlock()
+
+	if !exists {
+		// Annulé: plus d'entrée à renvoyer
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// handleListDownloads renvoie les téléchargements triés par priorité
+func (d *Daemon) handleListDownloads(w http.ResponseWriter, r *http.Request) {
+	d.downloadsLock.RLock()
+	list := make([]DownloadStatus, 0, len(d.downloads))
+	for _, ds := range d.downloads {
+		list = append(list, *ds)
+	}
+	maxConcurrent := d.maxConcurrent
+	d.downloadsLock.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Priority != list[j].Priority {
+			return list[i].Priority > list[j].Priority
+		}
+		return list[i].Filename < list[j].Filename
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"max_concurrent": maxConcurrent,
+		"downloads":      list,
+	})
+}
+
+// handleGetDownload renvoie un téléchargement
+func (d *Daemon) handleGetDownload(w http.ResponseWriter, r *http.Request) {
+	filename := filepath.Base(mux.Vars(r)["id"])
+
+	d.downloadsLock.RLock()
+	_, exists := d.downloads[filename]
+	d.downloadsLock.RUnlock()
+	if !exists {
+		writeDownloadError(w, r, errDownloadNotFound)
+		return
+	}
+
+	d.writeDownload(w, filename)
+}
+
+// handleCancelDownload annule un téléchargement et retire son entrée
+func (d *Daemon) handleCancelDownload(w http.ResponseWriter, r *http.Request) {
+	filename := filepath.Base(mux.Vars(r)["id"])
+
+	if err := d.CancelDownload(filename); err != nil {
+		writeDownloadError(w, r, err)
+		return
+	}
+
+	d.writeDownload(w, filename)
+}
+
+// handlePauseDownload met un téléchargement en pause
+func (d *Daemon) handlePauseDownload(w http.ResponseWriter, r *http.Request) {
+	filename := filepath.Base(mux.Vars(r)["id"])
+
+	if err := d.PauseDownload(filename); err != nil {
+		writeDownloadError(w, r, err)
+		return
+	}
+
+	d.writeDownload(w, filename)
+}
+
+// handleResumeDownload relance un téléchargement en pause ou en erreur
+func (d *Daemon) handleResumeDownload(w http.ResponseWriter, r *http.Request) {
+	filename := filepath.Base(mux.Vars(r)["id"])
+
+	if err := d.ResumeDownload(filename); err != nil {
+		writeDownloadError(w, r, err)
+		return
+	}
+
+	d.writeDownload(w, filename)
+}
+
+// handleDownloadPriority change la priorité d'un téléchargement
+func (d *Daemon) handleDownloadPriority(w http.ResponseWriter, r *http.Request) {
+	filename := filepath.Base(mux.Vars(r)["id"])
+
+	var req struct {
+		Priority int `json:"priority"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		shared.WriteError(w, r, shared.CodeInvalidRequest, shared.Details{"reason": err.Error()})
+		return
+	}
+
+	if err := d.SetDownloadPriority(filename, req.Priority); err != nil {
+		writeDownloadError(w, r, err)
+		return
+	}
+
+	d.writeDownload(w, filename)
+}
+
+// handleDownloadConcurrency change le nombre de téléchargements simultanés
+func (d *Daemon) handleDownloadConcurrency(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MaxConcurrent int `json:"max_concurrent"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		shared.WriteError(w, r, shared.CodeInvalidRequest, shared.Details{"reason": err.Error()})
+		return
+	}
+
+	if err := d.SetMaxConcurrentDownloads(req.MaxConcurrent); err != nil {
+		writeDownloadError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"max_concurrent": req.MaxConcurrent})
+}
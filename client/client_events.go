@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// ÉVÉNEMENTS TEMPS RÉEL (SERVER-SENT EVENTS)
+// ============================================
+
+// Types d'événements poussés sur /events (pipbingo/shared)
+const (
+	EventDownload        = shared.EventDownload
+	EventDownloadRemoved = shared.EventDownloadRemoved
+	EventSeeding         = shared.EventSeeding
+	EventStats           = shared.EventStats
+)
+
+// Event est un événement envoyé aux clients SSE; ils le reçoivent sous la forme
+// d'un shared.Event, dont Data reste à décoder selon Type
+type Event struct {
+	ID       uint64      `json:"id"`
+	Type     string      `json:"type"`
+	Filename string      `json:"filename,omitempty"` // vide pour les événements globaux
+	Data     interface{} `json:"data"`
+}
+
+// eventSubscriber est un client connecté à /events
+type eventSubscriber struct {
+	video  string          // ne recevoir que les événements de ce fichier (vide = tous)
+	types  map[string]bool // ne recevoir que ces types d'événements (vide = tous)
+	events chan Event
+}
+
+// matches indique si un événement intéresse l'abonné; les événements globaux passent le filtre par vidéo
+func (s *eventSubscriber) matches(ev Event) bool {
+	if len(s.types) > 0 && !s.types[ev.Type] {
+		return false
+	}
+	return s.video == "" || ev.Filename == "" || ev.Filename == s.video
+}
+
+// EventHub diffuse les événements aux abonnés et garde le dernier état de
+// chaque fichier, rejoué à la connexion pour qu'un client n'ait pas à interroger /status
+type EventHub struct {
+	nextID      uint64
+	latest      map[string]Event // dernier événement par type et fichier
+	subscribers map[*eventSubscriber]bool
+	closed      bool // Arrêt du daemon: plus d'abonnés acceptés
+	lock        sync.Mutex
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{
+		latest:      make(map[string]Event),
+		subscribers: make(map[*eventSubscriber]bool),
+	}
+}
+
+// Publish numérote et diffuse un événement. Un abonné trop lent est déconnecté
+// plutôt que de bloquer le daemon: à la reconnexion, le rejeu lui rend l'état courant.
+func (h *EventHub) Publish(eventType, filename string, data interface{}) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.nextID++
+	ev := Event{ID: h.nextID, Type: eventType, Filename: filename, Data: data}
+
+	if eventType == EventDownloadRemoved {
+		delete(h.latest, EventDownload+"/"+filename)
+	} else {
+		h.latest[eventType+"/"+filename] = ev
+	}
+
+	for sub := range h.subscribers {
+		if !sub.matches(ev) {
+			continue
+		}
+		select {
+		case sub.events <- ev:
+		default:
+			logHTTP.Warn("client SSE trop lent, déconnecté")
+			delete(h.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// PublishIfChanged publie un événement seulement si ses données ont changé depuis le dernier
+func (h *EventHub) PublishIfChanged(eventType, filename string, data interface{}) {
+	h.lock.Lock()
+	last, ok := h.latest[eventType+"/"+filename]
+	h.lock.Unlock()
+
+	if ok && last.Data == data {
+		return
+	}
+	h.Publish(eventType, filename, data)
+}
+
+// Subscribe inscrit un abonné et renvoie l'état courant à lui rejouer, sans trou entre les deux
+func (h *EventHub) Subscribe(video string, types []string) (*eventSubscriber, []Event) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	sub := &eventSubscriber{video: video, types: make(map[string]bool), events: make(chan Event, 64)}
+	for _, eventType := range types {
+		sub.types[eventType] = true
+	}
+	if h.closed {
+		close(sub.events)
+		return sub, nil
+	}
+	h.subscribers[sub] = true
+
+	var replay []Event
+	for _, ev := range h.latest {
+		if sub.matches(ev) {
+			replay = append(replay, ev)
+		}
+	}
+	sort.Slice(replay, func(i, j int) bool { return replay[i].ID < replay[j].ID })
+	return sub, replay
+}
+
+// Unsubscribe désinscrit un abonné (sans effet s'il a déjà été déconnecté)
+func (h *EventHub) Unsubscribe(sub *eventSubscriber) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.subscribers[sub] {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// Close déconnecte tous les abonnés, pour que leurs requêtes HTTP se terminent à l'arrêt
+func (h *EventHub) Close() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.closed = true
+	for sub := range h.subscribers {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// ============================================
+// PUBLICATION PAR LE DAEMON
+// ============================================
+
+// publishDownload publie l'état d'un téléchargement (appelé sous downloadsLock)
+func (d *Daemon) publishDownload(ds *DownloadStatus) {
+	ds.lastEvent = time.Now()
+	if ds.Status == StateCancelled {
+		d.events.Publish(EventDownloadRemoved, ds.Filename, map[string]string{"filename": ds.Filename})
+	} else {
+		d.events.Publish(EventDownload, ds.Filename, *ds)
+	}
+	d.markStatsDirty()
+}
+
+// publishSeeding publie l'état de seeding d'un fichier
+func (d *Daemon) publishSeeding(filename string) {
+	d.seedersLock.Lock()
+	view := seedingFileView{SeedStats: SeedStats{Filename: filename}}
+	if stats, exists := d.seedStats[filename]; exists {
+		view = d.seedingFileViewLocked(filename, stats, time.Now())
+	}
+	d.seedersLock.Unlock()
+
+	d.events.Publish(EventSeeding, filename, view)
+	d.markStatsDirty()
+}
+
+// markStatsDirty demande le recalcul des statistiques globales, sans bloquer l'appelant
+func (d *Daemon) markStatsDirty() {
+	select {
+	case d.statsDirty <- struct{}{}:
+	default:
+	}
+}
+
+// watchStats publie les statistiques globales quand elles changent: à la demande
+// (téléchargements, seeding, connexions de peers) et à chaque rechoke
+func (d *Daemon) watchStats() {
+	d.p2pHost.Network().Notify(&network.NotifyBundle{
+		ConnectedF:    func(network.Network, network.Conn) { d.markStatsDirty() },
+		DisconnectedF: func(network.Network, network.Conn) { d.markStatsDirty() },
+	})
+
+	ticker := time.NewTicker(ChokeInterval)
+	defer ticker.Stop()
+
+	for {
+		d.events.PublishIfChanged(EventStats, "", d.statsSnapshot())
+		select {
+		case <-d.statsDirty:
+		case <-ticker.C:
+		}
+	}
+}
+
+// ============================================
+// API HTTP DES ÉVÉNEMENTS
+// ============================================
+
+// handleEvents pousse les événements en Server-Sent Events.
+// ?video=<fichier> ne garde que les événements de ce fichier (plus les statistiques globales),
+// ?types=download,stats que ces types d'événements; l'état courant est rejoué à la connexion.
+func (d *Daemon) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		shared.WriteError(w, r, shared.CodeStreamingUnsupported, nil)
+		return
+	}
+
+	query := r.URL.Query()
+	video := query.Get("video")
+	if video != "" {
+		video = filepath.Base(video)
+	}
+	var types []string
+	if list := query.Get("types"); list != "" {
+		types = strings.Split(list, ",")
+	}
+
+	sub, replay := d.events.Subscribe(video, types)
+	defer d.events.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // pas de mise en tampon par un proxy
+	w.WriteHeader(http.StatusOK)
+
+	for _, ev := range replay {
+		if err := writeEvent(w, ev); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(EventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-sub.events:
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, ev); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			// Commentaire SSE: garde la connexion ouverte à travers les proxys
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent écrit un événement au format SSE
+func writeEvent(w http.ResponseWriter, ev Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+	return err
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// SANTÉ
+// ============================================
+
+// États d'un composant, du meilleur au pire (pipbingo/shared)
+const (
+	HealthOK       = shared.HealthOK
+	HealthDegraded = shared.HealthDegraded // fonctionne, mais avec une limite (hors ligne, disque presque plein...)
+	HealthFail     = shared.HealthFail
+)
+
+// Résultat d'un composant et réponse de /health/live et /health/ready
+type (
+	ComponentHealth = shared.ComponentHealth
+	HealthReport    = shared.HealthReport
+)
+
+// handleLive répond tant que le processus sert des requêtes HTTP
+func (d *Daemon) handleLive(w http.ResponseWriter, r *http.Request) {
+	shared.WriteHealth(w, HealthReport{Status: HealthOK, CheckedAt: time.Now()})
+}
+
+// handleReady vérifie que le daemon peut télécharger, seeder et servir le cache
+func (d *Daemon) handleReady(w http.ResponseWriter, r *http.Request) {
+	report := shared.RunHealthChecks(r.Context(), map[string]shared.HealthCheck{
+		"storage": d.checkCacheWritable,
+		"disk":    d.checkFreeDisk,
+		"p2p":     d.checkP2PHost,
+		"server":  d.checkServerPeer,
+		"catalog": d.checkCatalogStore,
+	})
+	if report.Status != HealthOK {
+		requestLogger(r).Info("daemon pas prêt", "status", report.Status)
+	}
+	shared.WriteHealth(w, report)
+}
+
+// checkCacheWritable crée puis supprime un fichier témoin à côté des téléchargements partiels
+func (d *Daemon) checkCacheWritable(ctx context.Context) (string, string, error) {
+	probe, err := os.CreateTemp(cfg.PartialDir(), ".health-*")
+	if err != nil {
+		return "", "", fmt.Errorf("cache non inscriptible: %w", err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return HealthOK, cfg.CacheDir, nil
+}
+
+// checkFreeDisk compare l'espace libre du cache à disk_reserve
+func (d *Daemon) checkFreeDisk(ctx context.Context) (string, string, error) {
+	free, err := shared.DiskFree(cfg.CacheDir)
+	if err != nil {
+		return "", "", fmt.Errorf("espace disque inconnu: %w", err)
+	}
+	message := fmt.Sprintf("%d Mo libres, %d Mo de réserve", free>>20, cfg.DiskReserve>>20)
+	if free < cfg.DiskReserve {
+		return HealthDegraded, message, nil
+	}
+	return HealthOK, message, nil
+}
+
+// checkP2PHost vérifie que le nœud libp2p écoute et accepte des streams
+func (d *Daemon) checkP2PHost(ctx context.Context) (string, string, error) {
+	if d.p2pHost == nil {
+		return "", "", errors.New("nœud P2P non démarré")
+	}
+	if d.isShuttingDown() {
+		return "", "", errors.New("arrêt en cours")
+	}
+	addrs := d.p2pHost.Addrs()
+	if len(addrs) == 0 {
+		return "", "", errors.New("aucune adresse d'écoute")
+	}
+	return HealthOK, fmt.Sprintf("%d adresses, %d peers", len(addrs), len(d.p2pHost.Network().Peers())), nil
+}
+
+// checkServerPeer vérifie la connexion au serveur, en la rétablissant si besoin.
+// Sans serveur le daemon reste utile (cache, seeding entre peers): état dégradé.
+func (d *Daemon) checkServerPeer(ctx context.Context) (string, string, error) {
+	if d.serverPeerID == "" {
+		return HealthDegraded, "peer ID du serveur inconnu (server.p2p_addr sans /p2p/)", nil
+	}
+	if d.p2pHost.Network().Connectedness(d.serverPeerID) == network.Connected {
+		return HealthOK, d.serverPeerID.ShortString(), nil
+	}
+
+	addrInfo := peer.AddrInfo{ID: d.serverPeerID, Addrs: d.p2pHost.Peerstore().Addrs(d.serverPeerID)}
+	if err := d.p2pHost.Connect(ctx, addrInfo); err != nil {
+		return HealthDegraded, fmt.Sprintf("serveur injoignable: %v", err), nil
+	}
+	return HealthOK, d.serverPeerID.ShortString(), nil
+}
+
+// checkCatalogStore vérifie que la copie locale du catalogue peut être persistée
+func (d *Daemon) checkCatalogStore(ctx context.Context) (string, string, error) {
+	d.catalogLock.Lock()
+	videos, seq, online := len(d.catalog.Videos), d.catalog.Seq, d.catalogOnline
+	d.catalogLock.Unlock()
+
+	probe, err := os.CreateTemp(cfg.StateDir(), ".health-*")
+	if err != nil {
+		return "", "", fmt.Errorf("catalogue local non persistable: %w", err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	message := fmt.Sprintf("%d vidéos, changement n°%d", videos, seq)
+	if !online {
+		return HealthDegraded, message + ", hors ligne", nil
+	}
+	return HealthOK, message, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// JOURNALISATION
+// ============================================
+
+// LogConfig règle le format et les niveaux des logs (pipbingo/shared)
+type LogConfig = shared.LogConfig
+
+// Un logger par sous-système, pour régler la verbosité de chacun séparément
+var (
+	logMain     *slog.Logger // démarrage, arrêt, connexions au serveur
+	logHTTP     *slog.Logger // requêtes de l'API locale
+	logP2P      *slog.Logger // streams libp2p entrants, choker, limites de débit
+	logDownload *slog.Logger // file d'attente et téléchargements
+	logSeeding  *slog.Logger // politique de seeding
+	logCache    *slog.Logger // cache, éviction, vérification d'intégrité
+	logCatalog  *slog.Logger // miroir local du catalogue
+	logSwarm    *slog.Logger // annonces au tracker, bitfields
+)
+
+// logSubsystems liste les sous-systèmes acceptés dans log.levels
+var logSubsystems = []string{"main", "http", "p2p", "download", "seeding", "cache", "catalog", "swarm"}
+
+func init() {
+	setupLogging(defaultConfig().Log)
+}
+
+// setupLogging crée les loggers des sous-systèmes (configuration déjà validée).
+// Le package log standard est redirigé vers logMain.
+func setupLogging(conf LogConfig) {
+	logger := shared.SubsystemLoggers(conf)
+	logMain = logger("main")
+	logHTTP = logger("http")
+	logP2P = logger("p2p")
+	logDownload = logger("download")
+	logSeeding = logger("seeding")
+	logCache = logger("cache")
+	logCatalog = logger("catalog")
+	logSwarm = logger("swarm")
+	slog.SetDefault(logMain)
+}
+
+// fatal journalise une erreur bloquante et arrête le processus
+func fatal(msg string, args ...any) {
+	logMain.Error(msg, args...)
+	os.Exit(1)
+}
+
+// ============================================
+// CORRÉLATION DES REQUÊTES HTTP
+// ============================================
+
+// requestLogger renvoie le logger de la requête, porteur de son request_id
+func requestLogger(r *http.Request) *slog.Logger {
+	return shared.RequestLogger(r, logHTTP)
+}
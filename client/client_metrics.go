@@ -0,0 +1,127 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+
+	p2pmetrics "github.com/libp2p/go-libp2p/core/metrics"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// MÉTRIQUES PROMETHEUS
+// ============================================
+
+// bandwidthCounter compte les octets échangés par le nœud libp2p, par protocole et par peer
+var bandwidthCounter = p2pmetrics.NewBandwidthCounter()
+
+// daemonMetrics regroupe les métriques exposées sur /metrics
+type daemonMetrics struct {
+	registry  *prometheus.Registry
+	p2p       *shared.P2PMetrics
+	downloads *prometheus.CounterVec
+}
+
+var metrics = newDaemonMetrics()
+
+func newDaemonMetrics() *daemonMetrics {
+	m := &daemonMetrics{
+		registry: prometheus.NewRegistry(),
+		p2p:      shared.NewP2PMetrics(shared.ActionRequestFile, shared.ActionGetManifest, shared.ActionBitfield, shared.ActionHave),
+		downloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pipbingo_downloads_total",
+			Help: "Téléchargements terminés, par état final (completed, error, paused, cancelled).",
+		}, []string{"result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.downloads,
+		shared.NewBandwidthCollector(bandwidthCounter),
+	)
+	m.registry.MustRegister(m.p2p.Collectors()...)
+	return m
+}
+
+// registerDaemonMetrics ajoute les métriques calculées à la lecture depuis l'état du daemon
+func (d *Daemon) registerDaemonMetrics() {
+	metrics.registry.MustRegister(daemonCollector{d})
+}
+
+// handleMetrics expose les métriques au format texte Prometheus
+func handleMetrics() http.Handler {
+	return promhttp.HandlerFor(metrics.registry, promhttp.HandlerOpts{})
+}
+
+// ============================================
+// REQUÊTES P2P
+// ============================================
+
+// streamLogger renvoie le logger d'un stream P2P entrant
+func streamLogger(stream network.Stream) *slog.Logger {
+	return shared.StreamLogger(stream, logP2P)
+}
+
+// ============================================
+// ÉTAT DU DAEMON
+// ============================================
+
+var (
+	connectedPeersDesc = prometheus.NewDesc("pipbingo_connected_peers",
+		"Peers connectés au nœud libp2p.", nil, nil)
+	unchokedPeersDesc = prometheus.NewDesc("pipbingo_unchoked_peers",
+		"Peers actuellement servis par le choker.", nil, nil)
+	queueDepthDesc = prometheus.NewDesc("pipbingo_download_queue_depth",
+		"Téléchargements en attente d'une place.", nil, nil)
+	activeDownloadsDesc = prometheus.NewDesc("pipbingo_active_downloads",
+		"Téléchargements en cours.", nil, nil)
+	seedingFilesDesc = prometheus.NewDesc("pipbingo_seeding_files",
+		"Fichiers actuellement seedés.", nil, nil)
+	cacheBytesDesc = prometheus.NewDesc("pipbingo_cache_bytes",
+		"Espace occupé par les vidéos complètes du cache.", nil, nil)
+	cacheMaxBytesDesc = prometheus.NewDesc("pipbingo_cache_max_bytes",
+		"Taille max du cache (0 = illimité).", nil, nil)
+)
+
+// daemonCollector lit l'état du daemon à chaque scrape
+type daemonCollector struct {
+	d *Daemon
+}
+
+func (daemonCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- connectedPeersDesc
+	ch <- unchokedPeersDesc
+	ch <- queueDepthDesc
+	ch <- activeDownloadsDesc
+	ch <- seedingFilesDesc
+	ch <- cacheBytesDesc
+	ch <- cacheMaxBytesDesc
+}
+
+func (c daemonCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.d.statsSnapshot()
+	ch <- prometheus.MustNewConstMetric(connectedPeersDesc, prometheus.GaugeValue, float64(stats.ConnectedPeers))
+	ch <- prometheus.MustNewConstMetric(unchokedPeersDesc, prometheus.GaugeValue, float64(stats.UnchokedPeers))
+	ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(stats.QueuedFiles))
+	ch <- prometheus.MustNewConstMetric(activeDownloadsDesc, prometheus.GaugeValue, float64(stats.DownloadingFiles))
+	ch <- prometheus.MustNewConstMetric(seedingFilesDesc, prometheus.GaugeValue, float64(stats.SeedingFiles))
+
+	c.d.cacheLock.Lock()
+	var used int64
+	if files, err := c.d.listCacheLocked(); err == nil {
+		for _, file := range files {
+			used += file.size
+		}
+	}
+	maxSize := c.d.maxCacheSize
+	c.d.cacheLock.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(cacheBytesDesc, prometheus.GaugeValue, float64(used))
+	ch <- prometheus.MustNewConstMetric(cacheMaxBytesDesc, prometheus.GaugeValue, float64(maxSize))
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// SPÉCIFICATION OPENAPI
+// ============================================
+
+// apiRoutes documente chaque route de l'API locale. Une route ajoutée dans newRouter
+// sans entrée ici, ou une entrée sans route, fait échouer TestOpenAPIRoutes.
+var apiRoutes = []shared.APIRoute{
+	// Téléchargements
+	{Method: "POST", Path: "/download", Tag: "téléchargements", Summary: "Mettre un fichier en file de téléchargement",
+		Request: shared.DownloadRequest{}, Response: map[string]string{}, Errors: []int{http.StatusBadRequest, http.StatusInternalServerError}},
+	{Method: "GET", Path: "/status", Tag: "téléchargements", Summary: "Téléchargements indexés par nom de fichier",
+		Response: map[string]shared.DownloadStatus{}},
+	{Method: "GET", Path: "/downloads", Tag: "téléchargements", Summary: "Téléchargements triés par priorité",
+		Response: shared.DownloadList{}},
+	{Method: "PUT", Path: "/downloads/concurrency", Tag: "téléchargements", Summary: "Changer le nombre de téléchargements simultanés",
+		Request: struct {
+			MaxConcurrent int `json:"max_concurrent"`
+		}{}, Response: map[string]int{}, Errors: []int{http.StatusBadRequest}},
+	{Method: "GET", Path: "/downloads/{id}", Tag: "téléchargements", Summary: "Lire un téléchargement (id = nom du fichier)",
+		Response: shared.DownloadStatus{}, Errors: []int{http.StatusNotFound}},
+	{Method: "DELETE", Path: "/downloads/{id}", Tag: "téléchargements", Summary: "Annuler un téléchargement et retirer son entrée",
+		Status: http.StatusNoContent, Errors: []int{http.StatusNotFound, http.StatusConflict}},
+	{Method: "POST", Path: "/downloads/{id}/pause", Tag: "téléchargements", Summary: "Mettre en pause",
+		Response: shared.DownloadStatus{}, Errors: []int{http.StatusNotFound, http.StatusConflict}},
+	{Method: "POST", Path: "/downloads/{id}/resume", Tag: "téléchargements", Summary: "Reprendre un téléchargement en pause ou en erreur",
+		Response: shared.DownloadStatus{}, Errors: []int{http.StatusNotFound, http.StatusConflict}},
+	{Method: "POST", Path: "/downloads/{id}/priority", Tag: "téléchargements", Summary: "Changer la priorité (la plus haute part en premier)",
+		Request: struct {
+			Priority int `json:"priority"`
+		}{}, Response: shared.DownloadStatus{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+
+	// Lecture
+	{Method: "GET", Path: "/stream/{filename}", Tag: "lecture", Summary: "Lire une vidéo du cache ou en cours de téléchargement (Range accepté)",
+		ContentType: "video/*", Errors: []int{http.StatusNotFound}},
+	{Method: "POST", Path: "/playback/{filename}", Tag: "lecture", Summary: "Signaler la position de lecture (chunks prioritaires)",
+		Request: struct {
+			Offset   *int64  `json:"offset,omitempty"`
+			Position float64 `json:"position,omitempty"`
+			Duration float64 `json:"duration,omitempty"`
+		}{}, Response: struct {
+			Filename string `json:"filename"`
+			Offset   int64  `json:"offset"`
+		}{}, Errors: []int{http.StatusBadRequest, http.StatusConflict}},
+
+	// Catalogue
+	{Method: "GET", Path: "/catalog", Tag: "catalogue", Summary: "Catalogue du serveur, limité au cache hors ligne",
+		Response: shared.CatalogView{}},
+	{Method: "GET", Path: "/catalog/thumbnails/{name}", Tag: "catalogue", Summary: "Miniature conservée localement",
+		ContentType: "image/*", Errors: []int{http.StatusNotFound}},
+
+	// Cache
+	{Method: "GET", Path: "/cache", Tag: "cache", Summary: "Occupation du cache et état de chaque fichier",
+		Response: shared.CacheView{}},
+	{Method: "PUT", Path: "/cache", Tag: "cache", Summary: "Changer la taille max du cache (0 = illimitée)",
+		Request: struct {
+			MaxSize int64 `json:"max_size"`
+		}{}, Response: shared.CacheView{}, Errors: []int{http.StatusBadRequest, http.StatusConflict}},
+	{Method: "POST", Path: "/cache/{filename}/pin", Tag: "cache", Summary: "Épingler un fichier (jamais évincé)",
+		Response: shared.CacheView{}, Errors: []int{http.StatusNotFound}},
+	{Method: "DELETE", Path: "/cache/{filename}/pin", Tag: "cache", Summary: "Libérer un fichier épinglé",
+		Response: shared.CacheView{}, Errors: []int{http.StatusNotFound}},
+	{Method: "GET", Path: "/scrub", Tag: "cache", Summary: "Dernière vérification d'intégrité du cache",
+		Response: ScrubReport{}},
+	{Method: "POST", Path: "/scrub", Tag: "cache", Summary: "Lancer une vérification d'intégrité (corps facultatif)",
+		Request: struct {
+			Delete bool `json:"delete,omitempty"`
+		}{}, Response: ScrubReport{}, Status: http.StatusAccepted, Errors: []int{http.StatusBadRequest, http.StatusConflict}},
+
+	// P2P et seeding
+	{Method: "GET", Path: "/stats", Tag: "p2p", Summary: "Statistiques P2P globales",
+		Response: shared.StatsSnapshot{}},
+	{Method: "GET", Path: "/peers", Tag: "p2p", Summary: "État de choke des peers connus",
+		Response: shared.PeersView{}},
+	{Method: "GET", Path: "/limits", Tag: "p2p", Summary: "Limites de débit et plages horaires",
+		Response: limitsView{}},
+	{Method: "PUT", Path: "/limits", Tag: "p2p", Summary: "Changer les limites de débit",
+		Request: struct {
+			Limits   Limits         `json:"limits"`
+			Schedule []ScheduleRule `json:"schedule"`
+		}{}, Response: limitsView{}, Errors: []int{http.StatusBadRequest}},
+	{Method: "GET", Path: "/seeding", Tag: "p2p", Summary: "Politique de seeding et état de chaque fichier",
+		Response: seedingOverview{}},
+	{Method: "PUT", Path: "/seeding/policy", Tag: "p2p", Summary: "Changer la politique de seeding par défaut et les limites globales",
+		Request: SeedingLimits{}, Response: seedingOverview{}, Errors: []int{http.StatusBadRequest}},
+	{Method: "PUT", Path: "/seeding/{filename}/policy", Tag: "p2p", Summary: "Politique propre à un fichier (null = politique par défaut)",
+		Request: struct {
+			Policy *SeedingPolicy `json:"policy"`
+		}{}, Response: seedingOverview{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{Method: "POST", Path: "/seeding/{filename}/start", Tag: "p2p", Summary: "Seeder un fichier du cache",
+		Response: seedingOverview{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+	{Method: "POST", Path: "/seeding/{filename}/stop", Tag: "p2p", Summary: "Arrêter de seeder un fichier",
+		Response: seedingOverview{}, Errors: []int{http.StatusNotFound}},
+
+	// Exploitation
+	{Method: "GET", Path: "/events", Tag: "exploitation", Summary: "Flux SSE des événements (état courant rejoué à la connexion)",
+		Query: []shared.APIParam{
+			{Name: "video", Description: "ne garder que les événements de ce fichier (plus les statistiques globales)"},
+			{Name: "types", Description: "types d'événements séparés par des virgules (download, download_removed, seeding, stats)"},
+		},
+		ContentType: "text/event-stream"},
+	{Method: "GET", Path: "/config", Tag: "exploitation", Summary: "Configuration effective (secrets masqués, surcharges de l'API comprises)",
+		Response: configView{}},
+	{Method: "GET", Path: "/metrics", Tag: "exploitation", Summary: "Métriques Prometheus",
+		ContentType: "text/plain"},
+	{Method: "GET", Path: "/health", Tag: "exploitation", Summary: "Répond OK (conservé pour compatibilité)",
+		ContentType: "text/plain"},
+	{Method: "GET", Path: "/health/live", Tag: "exploitation", Summary: "Le processus répond",
+		Response: HealthReport{}},
+	{Method: "GET", Path: "/health/ready", Tag: "exploitation", Summary: "Stockage, disque, nœud P2P, serveur et catalogue opérationnels",
+		Response: HealthReport{}, Errors: []int{http.StatusServiceUnavailable}},
+	{Method: "GET", Path: "/openapi.json", Tag: "exploitation", Summary: "Cette spécification",
+		ContentType: "application/json"},
+}
+
+// handleOpenAPI sert la spécification OpenAPI 3 de l'API locale
+func handleOpenAPI() http.HandlerFunc {
+	spec, err := json.MarshalIndent(shared.OpenAPISpec("pip bin Go - daemon P2P",
+		"API locale du daemon: téléchargements, lecture, cache, seeding et événements.", apiRoutes), "", "  ")
+	if err != nil {
+		fatal("spécification OpenAPI invalide", "err", err)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	}
+}
+
+// pathVariable repère les variables {id} d'un chemin documenté
+var pathVariable = regexp.MustCompile(`\{[^}]+\}`)
+
+// checkOpenAPIRoutes vérifie que chaque route du routeur figure dans apiRoutes, et
+// que chaque route documentée est servie par le routeur
+func checkOpenAPIRoutes(router *mux.Router) error {
+	var missing []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{""} // PathPrefix: toutes les méthodes
+		}
+		for _, method := range methods {
+			if !shared.Documented(apiRoutes, method, path) {
+				missing = append(missing, strings.TrimSpace(method+" "+path))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var unserved []string
+	for _, route := range apiRoutes {
+		req, err := http.NewRequest(route.Method, pathVariable.ReplaceAllString(route.Path, "x"), nil)
+		if err != nil {
+			return err
+		}
+		var match mux.RouteMatch
+		if !router.Match(req, &match) || match.MatchErr != nil {
+			unserved = append(unserved, route.Method+" "+route.Path)
+		}
+	}
+
+	var errs []error
+	if len(missing) > 0 {
+		errs = append(errs, fmt.Errorf("routes absentes de la spécification OpenAPI: %s", strings.Join(missing, ", ")))
+	}
+	if len(unserved) > 0 {
+		errs = append(errs, fmt.Errorf("routes documentées absentes du routeur: %s", strings.Join(unserved, ", ")))
+	}
+	return errors.Join(errs...)
+}
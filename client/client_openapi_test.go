@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestOpenAPIRoutes échoue si le routeur et client_openapi.go divergent
+func TestOpenAPIRoutes(t *testing.T) {
+	if err := checkOpenAPIRoutes(newRouter(NewDaemon())); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestCheckOpenAPIRoutesReportsMissing vérifie que la route oubliée est nommée
+func TestCheckOpenAPIRoutesReportsMissing(t *testing.T) {
+	router := newRouter(NewDaemon())
+	router.HandleFunc("/undocumented", func(http.ResponseWriter, *http.Request) {}).Methods("GET")
+
+	err := checkOpenAPIRoutes(router)
+	if err == nil || !strings.Contains(err.Error(), "GET /undocumented") {
+		t.Fatalf("route non documentée non signalée: %v", err)
+	}
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ============================================
+// BITFIELD
+// ============================================
+
+// Bitfield indique quels chunks d'un fichier sont présents et vérifiés
+type Bitfield struct {
+	bits []byte
+	n    int
+}
+
+// NewBitfield crée un bitfield vide pour n chunks
+func NewBitfield(n int) *Bitfield {
+	return &Bitfield{
+		bits: make([]byte, (n+7)/8),
+		n:    n,
+	}
+}
+
+// BitfieldFromBytes reconstruit un bitfield persisté ou reçu d'un peer
+func BitfieldFromBytes(data []byte, n int) (*Bitfield, error) {
+	if len(data) != (n+7)/8 {
+		return nil, fmt.Errorf("bitfield de %d octets invalide pour %d chunks", len(data), n)
+	}
+	bf := NewBitfield(n)
+	copy(bf.bits, data)
+	return bf, nil
+}
+
+// Set marque le chunk i comme présent
+func (bf *Bitfield) Set(i int) {
+	if i >= 0 && i < bf.n {
+		bf.bits[i/8] |= 1 << (7 - uint(i%8))
+	}
+}
+
+// Clear marque le chunk i comme absent
+func (bf *Bitfield) Clear(i int) {
+	if i >= 0 && i < bf.n {
+		bf.bits[i/8] &^= 1 << (7 - uint(i%8))
+	}
+}
+
+// Has indique si le chunk i est présent
+func (bf *Bitfield) Has(i int) bool {
+	if i < 0 || i >= bf.n {
+		return false
+	}
+	return bf.bits[i/8]&(1<<(7-uint(i%8))) != 0
+}
+
+// Len renvoie le nombre de chunks couverts
+func (bf *Bitfield) Len() int {
+	return bf.n
+}
+
+// Count renvoie le nombre de chunks présents
+func (bf *Bitfield) Count() int {
+	count := 0
+	for i := 0; i < bf.n; i++ {
+		if bf.Has(i) {
+			count++
+		}
+	}
+	return count
+}
+
+// Complete indique si tous les chunks sont présents
+func (bf *Bitfield) Complete() bool {
+	return bf.Count() == bf.n
+}
+
+// Bytes renvoie une copie de la représentation binaire
+func (bf *Bitfield) Bytes() []byte {
+	out := make([]byte, len(bf.bits))
+	copy(out, bf.bits)
+	return out
+}
+
+// ============================================
+// MANIFESTS
+// ============================================
+
+// manifestPath renvoie l'emplacement du manifest persisté d'un fichier
+func manifestPath(filename string) string {
+	return filepath.Join(cfg.ManifestDir(), filename+".json")
+}
+
+// loadManifest relit un manifest persisté
+func loadManifest(filename string) (*Manifest, error) {
+	data, err := os.ReadFile(manifestPath(filename))
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// saveManifest persiste un manifest à côté du cache
+func saveManifest(manifest *Manifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(manifestPath(manifest.Filename), data)
+}
+
+// verifyFile relit un fichier complet et renvoie les chunks invalides
+func verifyFile(path string, manifest *Manifest) ([]int, error) {
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var bad []int
+	buf := make([]byte, manifest.ChunkSize)
+	for i := 0; i < manifest.TotalChunks(); i++ {
+		chunk := buf[:manifest.ChunkLength(i)]
+		if _, err := io.ReadFull(file, chunk); err != nil {
+			// Fichier tronqué: tous les chunks restants sont invalides
+			for ; i < manifest.TotalChunks(); i++ {
+				bad = append(bad, i)
+			}
+			break
+		}
+		if manifest.VerifyChunk(i, chunk) != nil {
+			bad = append(bad, i)
+		}
+	}
+
+	return bad, nil
+}
+
+// ============================================
+// TÉLÉCHARGEMENTS PARTIELS
+// ============================================
+
+// partialDownload est un téléchargement en cours, persisté dans PartialDir:
+// <fichier>.part contient les données, <fichier>.bitfield les chunks vérifiés.
+type partialDownload struct {
+	filename string
+	manifest *Manifest
+	file     *os.File
+	have     *Bitfield
+	picker   *PiecePicker
+	updated  chan struct{} // fermé à chaque nouveau chunk vérifié
+	readOnly bool          // .part d'un téléchargement en pause, ouvert pour le streaming
+	lock     sync.Mutex
+}
+
+func partPath(filename string) string {
+	return filepath.Join(cfg.PartialDir(), filename+".part")
+}
+
+func bitfieldPath(filename string) string {
+	return filepath.Join(cfg.PartialDir(), filename+".bitfield")
+}
+
+// openPartialDownload ouvre le fichier .part sans le tronquer et recharge son bitfield.
+// Si le manifest a changé depuis la dernière session, on repart de zéro.
+func openPartialDownload(filename string, manifest *Manifest) (*partialDownload, error) {
+	if err := manifest.Validate(); err != nil {
+		return nil, fmt.Errorf("manifest invalide pour %s: %w", filename, err)
+	}
+
+	have := NewBitfield(manifest.TotalChunks())
+
+	if previous, err := loadManifest(filename); err == nil && previous.Same(manifest) {
+		if data, err := os.ReadFile(bitfieldPath(filename)); err == nil {
+			if bf, err := BitfieldFromBytes(data, manifest.TotalChunks()); err == nil {
+				have = bf
+			} else {
+				logDownload.Warn("bitfield corrompu, reprise depuis le début", "file", filename, "err", err)
+			}
+		}
+	} else {
+		// Contenu différent (ou première tentative): ignorer l'ancien .part
+		os.Remove(partPath(filename))
+		os.Remove(bitfieldPath(filename))
+		if err := saveManifest(manifest); err != nil {
+			return nil, fmt.Errorf("impossible de persister le manifest: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(partPath(filename), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &partialDownload{
+		filename: filename,
+		manifest: manifest,
+		file:     file,
+		have:     have,
+		updated:  make(chan struct{}),
+	}, nil
+}
+
+// openPartialSnapshot ouvre en lecture seule le .part d'un téléchargement en pause,
+// avec son bitfield persisté, pour streamer les chunks déjà vérifiés
+func openPartialSnapshot(filename string) (*partialDownload, error) {
+	manifest, err := loadManifest(filename)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(bitfieldPath(filename))
+	if err != nil {
+		return nil, err
+	}
+	have, err := BitfieldFromBytes(data, manifest.TotalChunks())
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(partPath(filename))
+	if err != nil {
+		return nil, err
+	}
+
+	return &partialDownload{
+		filename: filename,
+		manifest: manifest,
+		file:     file,
+		have:     have,
+		updated:  make(chan struct{}),
+		readOnly: true,
+	}, nil
+}
+
+// has indique si le chunk i est déjà vérifié
+func (p *partialDownload) has(i int) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.have.Has(i)
+}
+
+// bytesHave renvoie le nombre d'octets vérifiés
+func (p *partialDownload) bytesHave() int64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var total int64
+	for i := 0; i < p.have.Len(); i++ {
+		if p.have.Has(i) {
+			total += p.manifest.ChunkLength(i)
+		}
+	}
+	return total
+}
+
+// writeChunk vérifie un chunk, l'écrit à sa place et persiste le bitfield
+func (p *partialDownload) writeChunk(i int, data []byte) error {
+	if err := p.manifest.VerifyChunk(i, data); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	offset := int64(i) * int64(p.manifest.ChunkSize)
+	if _, err := p.file.WriteAt(data, offset); err != nil {
+		return fmt.Errorf("erreur écriture chunk %d: %w", i, err)
+	}
+
+	// Les données doivent être sur disque avant que le bitfield ne les annonce
+	if err := p.file.Sync(); err != nil {
+		return fmt.Errorf("erreur sync chunk %d: %w", i, err)
+	}
+
+	p.have.Set(i)
+	p.notifyUpdated()
+	return writeFileAtomic(bitfieldPath(p.filename), p.have.Bytes())
+}
+
+// finalize revérifie le fichier complet puis le déplace atomiquement dans CacheDir
+func (p *partialDownload) finalize() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if !p.have.Complete() {
+		return fmt.Errorf("téléchargement incomplet: %d/%d chunks", p.have.Count(), p.have.Len())
+	}
+
+	if err := p.file.Truncate(p.manifest.Size); err != nil {
+		return err
+	}
+
+	// Relire le fichier: un .part repris a pu être abîmé entre deux sessions
+	bad, err := verifyFile(partPath(p.filename), p.manifest)
+	if err != nil {
+		return err
+	}
+	if len(bad) > 0 {
+		for _, i := range bad {
+			p.have.Clear(i)
+		}
+		writeFileAtomic(bitfieldPath(p.filename), p.have.Bytes())
+		return fmt.Errorf("%d chunks corrompus sur disque, ils seront retéléchargés", len(bad))
+	}
+
+	if err := p.file.Close(); err != nil {
+		return err
+	}
+
+	// Les lecteurs ne basculent sur le cache qu'une fois le fichier réellement déplacé
+	if err := os.Rename(partPath(p.filename), filepath.Join(cfg.CacheDir, p.filename)); err != nil {
+		if file, reopenErr := os.OpenFile(partPath(p.filename), os.O_RDWR, 0644); reopenErr == nil {
+			p.file = file
+		} else {
+			p.file = nil
+			p.notifyUpdated()
+		}
+		return fmt.Errorf("impossible de déplacer %s dans le cache: %w", p.filename, err)
+	}
+	p.file = nil
+	p.notifyUpdated()
+	os.Remove(bitfieldPath(p.filename))
+
+	return nil
+}
+
+// close libère le fichier .part (les données restent sur disque pour une reprise)
+func (p *partialDownload) close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.file != nil {
+		p.file.Close()
+		p.file = nil
+		p.notifyUpdated()
+	}
+}
+
+// resumeIncompleteDownloads relance les téléchargements trouvés dans PartialDir
+func (d *Daemon) resumeIncompleteDownloads() {
+	files, err := os.ReadDir(cfg.PartialDir())
+	if err != nil {
+		logDownload.Warn("dossier des téléchargements partiels illisible", "dir", cfg.PartialDir(), "err", err)
+		return
+	}
+
+	resumed := 0
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".part") {
+			continue
+		}
+
+		filename := strings.TrimSuffix(file.Name(), ".part")
+
+		// Déjà restauré avec sa priorité et son état (pause...) par restoreDownloads
+		d.downloadsLock.RLock()
+		_, known := d.downloads[filename]
+		d.downloadsLock.RUnlock()
+		if known {
+			continue
+		}
+
+		if err := d.DownloadAndSeed(filename, 0); err != nil {
+			logDownload.Error("reprise impossible", "file", filename, "err", err)
+			continue
+		}
+		resumed++
+	}
+
+	if resumed > 0 {
+		logDownload.Info("téléchargements interrompus repris", "count", resumed)
+	}
+}
+
+// removePartialFiles supprime les données d'un téléchargement annulé
+func removePartialFiles(filename string) {
+	os.Remove(partPath(filename))
+	os.Remove(bitfieldPath(filename))
+	os.Remove(manifestPath(filename))
+}
+
+// writeFileAtomic écrit un fichier via un fichier temporaire puis un renommage
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
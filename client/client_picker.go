@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"path/filepath"
+	"sync"
+
+	"github.com/gorilla/mux"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// SÉLECTION DES CHUNKS (PIECE PICKER)
+// ============================================
+
+// pendingChunk est une requête de chunk en vol
+type pendingChunk struct {
+	urgent bool
+	cancel context.CancelFunc
+}
+
+// PiecePicker décide quel chunk demander ensuite pour un téléchargement.
+// Les chunks situés dans la fenêtre de lecture [position, position+window)
+// sont demandés en priorité et dans l'ordre; le reste de la bande passante
+// sert aux chunks les plus rares du swarm.
+type PiecePicker struct {
+	total        int
+	window       int
+	position     int
+	have         func(int) bool // chunk déjà vérifié localement
+	availability func(int) int  // nombre de peers possédant le chunk
+	inFlight     map[int]*pendingChunk
+	changed      chan struct{}
+	lock         sync.Mutex
+}
+
+// NewPiecePicker crée un picker pour total chunks
+func NewPiecePicker(total, window int, have func(int) bool, availability func(int) int) *PiecePicker {
+	return &PiecePicker{
+		total:        total,
+		window:       window,
+		have:         have,
+		availability: availability,
+		inFlight:     make(map[int]*pendingChunk),
+		changed:      make(chan struct{}),
+	}
+}
+
+// notify réveille les workers en attente (appelé sous lock)
+func (pp *PiecePicker) notify() {
+	close(pp.changed)
+	pp.changed = make(chan struct{})
+}
+
+// inWindow indique si un chunk est dans la fenêtre de lecture (appelé sous lock)
+func (pp *PiecePicker) inWindow(i int) bool {
+	return i >= pp.position && i < pp.position+pp.window
+}
+
+// pick choisit un chunk libre, ou -1 s'il n'y en a aucun (appelé sous lock)
+func (pp *PiecePicker) pick() (int, bool) {
+	// 1. Fenêtre de lecture: séquentiel, haute priorité
+	for i := pp.position; i < pp.position+pp.window && i < pp.total; i++ {
+		if _, busy := pp.inFlight[i]; !busy && !pp.have(i) {
+			return i, true
+		}
+	}
+
+	// 2. Rarest-first sur le reste (égalités départagées au hasard)
+	best, bestAvail, ties := -1, 0, 0
+	for i := 0; i < pp.total; i++ {
+		if _, busy := pp.inFlight[i]; busy || pp.have(i) {
+			continue
+		}
+		avail := pp.availability(i)
+		switch {
+		case best == -1 || avail < bestAvail:
+			best, bestAvail, ties = i, avail, 1
+		case avail == bestAvail:
+			ties++
+			if rand.Intn(ties) == 0 {
+				best = i
+			}
+		}
+	}
+
+	return best, false
+}
+
+// Next bloque jusqu'à ce qu'un chunk soit à demander. Le contexte renvoyé est
+// annulé si un seek rend la requête inutile. ok vaut false quand il ne reste
+// plus rien à demander ou que ctx est annulé.
+func (pp *PiecePicker) Next(ctx context.Context) (index int, urgent bool, reqCtx context.Context, ok bool) {
+	for {
+		pp.lock.Lock()
+		index, urgent := pp.pick()
+		if index >= 0 {
+			reqCtx, cancel := context.WithCancel(ctx)
+			pp.inFlight[index] = &pendingChunk{urgent: urgent, cancel: cancel}
+			pp.lock.Unlock()
+			return index, urgent, reqCtx, true
+		}
+
+		// Plus rien de libre: terminé si aucune requête n'est en vol
+		if len(pp.inFlight) == 0 {
+			pp.lock.Unlock()
+			return -1, false, nil, false
+		}
+		changed := pp.changed
+		pp.lock.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return -1, false, nil, false
+		}
+	}
+}
+
+// Done libère un chunk en vol (réussi ou non) et réveille les autres workers
+func (pp *PiecePicker) Done(index int) {
+	pp.lock.Lock()
+	defer pp.lock.Unlock()
+
+	if pending, ok := pp.inFlight[index]; ok {
+		pending.cancel()
+		delete(pp.inFlight, index)
+	}
+	pp.notify()
+}
+
+// SetPosition déplace la fenêtre de lecture. Un saut hors de la fenêtre courante
+// (seek) annule les requêtes prioritaires qui ne servent plus la lecture.
+func (pp *PiecePicker) SetPosition(chunk int) {
+	if pp.total == 0 {
+		return
+	}
+	if chunk < 0 {
+		chunk = 0
+	}
+	if chunk >= pp.total {
+		chunk = pp.total - 1
+	}
+
+	pp.lock.Lock()
+	defer pp.lock.Unlock()
+
+	if chunk == pp.position {
+		return
+	}
+
+	seek := !pp.inWindow(chunk)
+	pp.position = chunk
+
+	if seek {
+		cancelled := 0
+		for i, pending := range pp.inFlight {
+			if pending.urgent && !pp.inWindow(i) {
+				pending.cancel()
+				cancelled++
+			}
+		}
+		logDownload.Debug("seek", "chunk", chunk, "total_chunks", pp.total, "cancelled", cancelled)
+	}
+
+	pp.notify()
+}
+
+// Position renvoie le chunk de lecture courant
+func (pp *PiecePicker) Position() int {
+	pp.lock.Lock()
+	defer pp.lock.Unlock()
+	return pp.position
+}
+
+// ============================================
+// POSITION DE LECTURE
+// ============================================
+
+// setPlaybackOffset mémorise la position de lecture (en octets) d'un fichier
+// et déplace la fenêtre du picker si le téléchargement est en cours
+func (d *Daemon) setPlaybackOffset(filename string, offset int64) {
+	d.playbackLock.Lock()
+	d.playback[filename] = offset
+	d.playbackLock.Unlock()
+
+	if partial := d.getPartial(filename); partial != nil && partial.picker != nil {
+		partial.picker.SetPosition(int(offset / int64(partial.manifest.ChunkSize)))
+	}
+}
+
+// playbackChunk renvoie le chunk de lecture connu pour un fichier
+func (d *Daemon) playbackChunk(filename string, manifest *Manifest) int {
+	d.playbackLock.Lock()
+	defer d.playbackLock.Unlock()
+	return int(d.playback[filename] / int64(manifest.ChunkSize))
+}
+
+// handlePlaybackRequest reçoit la position de lecture du player.
+// Accepte soit un offset en octets, soit une position et une durée en secondes.
+func (d *Daemon) handlePlaybackRequest(w http.ResponseWriter, r *http.Request) {
+	filename := filepath.Base(mux.Vars(r)["filename"])
+
+	var req struct {
+		Offset   *int64  `json:"offset"`
+		Position float64 `json:"position"`
+		Duration float64 `json:"duration"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		shared.WriteError(w, r, shared.CodeInvalidRequest, shared.Details{"reason": err.Error()})
+		return
+	}
+
+	var offset int64
+	switch {
+	case req.Offset != nil:
+		offset = *req.Offset
+	case req.Duration > 0:
+		manifest := d.localManifest(filename)
+		if manifest == nil {
+			shared.WriteError(w, r, shared.CodeUnknownFileSize, shared.Details{"filename": filename})
+			return
+		}
+		offset = int64(req.Position / req.Duration * float64(manifest.Size))
+	default:
+		shared.WriteError(w, r, shared.CodeMissingPosition, nil)
+		return
+	}
+
+	d.setPlaybackOffset(filename, offset)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"filename": filename,
+		"offset":   offset,
+	})
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// LIMITATION DE BANDE PASSANTE
+// ============================================
+
+// TokenBucket limite un débit en octets par seconde (0 = illimité)
+type TokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+	lock   sync.Mutex
+}
+
+// NewTokenBucket crée un seau au débit donné, plein au départ
+func NewTokenBucket(rate int64) *TokenBucket {
+	return &TokenBucket{
+		rate:   float64(rate),
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+// SetRate change le débit sans perdre les jetons accumulés
+func (tb *TokenBucket) SetRate(rate int64) {
+	tb.lock.Lock()
+	defer tb.lock.Unlock()
+
+	tb.refill()
+	tb.rate = float64(rate)
+	if tb.tokens > tb.rate {
+		tb.tokens = tb.rate
+	}
+}
+
+// refill ajoute les jetons gagnés depuis le dernier passage (appelé sous lock).
+// Le seau contient au plus une seconde de débit.
+func (tb *TokenBucket) refill() {
+	now := time.Now()
+	tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+	if tb.tokens > tb.rate {
+		tb.tokens = tb.rate
+	}
+	tb.last = now
+}
+
+// WaitN consomme n octets, en attendant le temps nécessaire si le seau est vide
+func (tb *TokenBucket) WaitN(ctx context.Context, n int) error {
+	tb.lock.Lock()
+	if tb.rate <= 0 {
+		tb.lock.Unlock()
+		return nil
+	}
+
+	// Réserver les jetons quitte à s'endetter: l'attente rembourse la dette
+	tb.refill()
+	tb.tokens -= float64(n)
+	var wait time.Duration
+	if tb.tokens < 0 {
+		wait = time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+	}
+	tb.lock.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Limits regroupe les débits max en octets/s (0 = illimité)
+type Limits struct {
+	UploadRate       int64 `yaml:"upload_rate" json:"upload_rate"`
+	DownloadRate     int64 `yaml:"download_rate" json:"download_rate"`
+	PeerUploadRate   int64 `yaml:"peer_upload_rate" json:"peer_upload_rate"`
+	PeerDownloadRate int64 `yaml:"peer_download_rate" json:"peer_download_rate"`
+}
+
+// ScheduleRule applique d'autres limites sur une plage horaire ("22:00"-"07:00" traverse minuit)
+type ScheduleRule struct {
+	Start  string `yaml:"start" json:"start"`
+	End    string `yaml:"end" json:"end"`
+	Limits Limits `yaml:"limits" json:"limits"`
+}
+
+// parseClock convertit "HH:MM" en minutes depuis minuit
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("heure invalide %q (format HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// matches indique si la règle s'applique à l'instant donné
+func (rule ScheduleRule) matches(now time.Time) bool {
+	start, err1 := parseClock(rule.Start)
+	end, err2 := parseClock(rule.End)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// BandwidthManager applique les limites globales et par peer aux streams P2P
+type BandwidthManager struct {
+	base      Limits
+	schedule  []ScheduleRule
+	effective Limits
+	active    int // index de la règle horaire active, -1 sinon
+	upload    *TokenBucket
+	download  *TokenBucket
+	peerUp    map[peer.ID]*TokenBucket
+	peerDown  map[peer.ID]*TokenBucket
+	ctx       context.Context // annulé par Close: plus aucune attente de jetons
+	cancel    context.CancelFunc
+	lock      sync.Mutex
+}
+
+func NewBandwidthManager(base Limits, schedule []ScheduleRule) *BandwidthManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	bm := &BandwidthManager{
+		base:     base,
+		schedule: schedule,
+		active:   -1,
+		upload:   NewTokenBucket(0),
+		download: NewTokenBucket(0),
+		peerUp:   make(map[peer.ID]*TokenBucket),
+		peerDown: make(map[peer.ID]*TokenBucket),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	bm.apply(time.Now())
+	return bm
+}
+
+// apply calcule les limites effectives à l'instant donné et met à jour les seaux
+func (bm *BandwidthManager) apply(now time.Time) {
+	bm.lock.Lock()
+	defer bm.lock.Unlock()
+
+	effective, active := bm.base, -1
+	for i, rule := range bm.schedule {
+		if rule.matches(now) {
+			effective, active = rule.Limits, i
+			break
+		}
+	}
+
+	if active != bm.active {
+		if active >= 0 {
+			logP2P.Info("plage horaire active", "start", bm.schedule[active].Start, "end", bm.schedule[active].End)
+		} else if bm.active >= 0 {
+			logP2P.Info("retour aux limites par défaut")
+		}
+	}
+	bm.effective, bm.active = effective, active
+
+	bm.upload.SetRate(effective.UploadRate)
+	bm.download.SetRate(effective.DownloadRate)
+	for _, tb := range bm.peerUp {
+		tb.SetRate(effective.PeerUploadRate)
+	}
+	for _, tb := range bm.peerDown {
+		tb.SetRate(effective.PeerDownloadRate)
+	}
+}
+
+// validateLimits vérifie des limites et un planning venant de la configuration ou de PUT /limits
+func validateLimits(base Limits, schedule []ScheduleRule) error {
+	for _, limits := range append([]Limits{base}, rulesLimits(schedule)...) {
+		if limits.UploadRate < 0 || limits.DownloadRate < 0 ||
+			limits.PeerUploadRate < 0 || limits.PeerDownloadRate < 0 {
+			return fmt.Errorf("les débits doivent être positifs ou nuls")
+		}
+	}
+	for _, rule := range schedule {
+		if _, err := parseClock(rule.Start); err != nil {
+			return err
+		}
+		if _, err := parseClock(rule.End); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Update remplace les limites par défaut et le planning, puis les applique aussitôt
+func (bm *BandwidthManager) Update(base Limits, schedule []ScheduleRule) error {
+	if err := validateLimits(base, schedule); err != nil {
+		return err
+	}
+
+	bm.lock.Lock()
+	bm.base = base
+	bm.schedule = schedule
+	bm.active = -1
+	bm.lock.Unlock()
+
+	bm.apply(time.Now())
+	return nil
+}
+
+func rulesLimits(schedule []ScheduleRule) []Limits {
+	limits := make([]Limits, len(schedule))
+	for i, rule := range schedule {
+		limits[i] = rule.Limits
+	}
+	return limits
+}
+
+// run réévalue le planning chaque minute
+func (bm *BandwidthManager) run() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		bm.apply(now)
+	}
+}
+
+// peerBuckets renvoie les seaux d'un peer, créés à la demande
+func (bm *BandwidthManager) peerBuckets(id peer.ID) (up, down *TokenBucket) {
+	bm.lock.Lock()
+	defer bm.lock.Unlock()
+
+	if up = bm.peerUp[id]; up == nil {
+		up = NewTokenBucket(bm.effective.PeerUploadRate)
+		bm.peerUp[id] = up
+	}
+	if down = bm.peerDown[id]; down == nil {
+		down = NewTokenBucket(bm.effective.PeerDownloadRate)
+		bm.peerDown[id] = down
+	}
+	return up, down
+}
+
+// watchPeers oublie les seaux d'un peer quand sa dernière connexion se ferme
+func (bm *BandwidthManager) watchPeers(n network.Network) {
+	n.Notify(&network.NotifyBundle{
+		DisconnectedF: func(n network.Network, conn network.Conn) {
+			id := conn.RemotePeer()
+			if n.Connectedness(id) == network.Connected {
+				return
+			}
+			bm.lock.Lock()
+			delete(bm.peerUp, id)
+			delete(bm.peerDown, id)
+			bm.lock.Unlock()
+		},
+	})
+}
+
+// Close interrompt toutes les attentes de jetons (arrêt du daemon)
+func (bm *BandwidthManager) Close() {
+	bm.cancel()
+}
+
+// wrap limite les lectures (download) et écritures (upload) d'un stream
+func (bm *BandwidthManager) wrap(stream network.Stream) network.Stream {
+	up, down := bm.peerBuckets(stream.Conn().RemotePeer())
+	ctx, cancel := context.WithCancel(bm.ctx)
+	return &limitedStream{
+		Stream:   stream,
+		upload:   []*TokenBucket{bm.upload, up},
+		download: []*TokenBucket{bm.download, down},
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// limitedStreamSlice découpe les transferts pour lisser le débit
+const limitedStreamSlice = 32 * 1024
+
+// limitedStream est un stream libp2p dont le débit passe par des token buckets.
+// Les attentes de jetons respectent les échéances du stream et s'arrêtent à sa
+// fermeture (Close, Reset: annulation par l'appelant) ou à l'arrêt du daemon.
+type limitedStream struct {
+	network.Stream
+	upload        []*TokenBucket
+	download      []*TokenBucket
+	ctx           context.Context
+	cancel        context.CancelFunc
+	readDeadline  time.Time
+	writeDeadline time.Time
+	lock          sync.Mutex
+}
+
+func (ls *limitedStream) SetDeadline(t time.Time) error {
+	ls.lock.Lock()
+	ls.readDeadline, ls.writeDeadline = t, t
+	ls.lock.Unlock()
+	return ls.Stream.SetDeadline(t)
+}
+
+func (ls *limitedStream) SetReadDeadline(t time.Time) error {
+	ls.lock.Lock()
+	ls.readDeadline = t
+	ls.lock.Unlock()
+	return ls.Stream.SetReadDeadline(t)
+}
+
+func (ls *limitedStream) SetWriteDeadline(t time.Time) error {
+	ls.lock.Lock()
+	ls.writeDeadline = t
+	ls.lock.Unlock()
+	return ls.Stream.SetWriteDeadline(t)
+}
+
+func (ls *limitedStream) Close() error {
+	ls.cancel()
+	return ls.Stream.Close()
+}
+
+func (ls *limitedStream) Reset() error {
+	ls.cancel()
+	return ls.Stream.Reset()
+}
+
+// wait consomme n octets dans chaque seau, jusqu'à l'échéance au plus tard.
+// Une échéance dépassée donne os.ErrDeadlineExceeded, comme le stream lui-même.
+func (ls *limitedStream) wait(buckets []*TokenBucket, n int, write bool) error {
+	ls.lock.Lock()
+	deadline := ls.readDeadline
+	if write {
+		deadline = ls.writeDeadline
+	}
+	ls.lock.Unlock()
+
+	ctx := ls.ctx
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	for _, tb := range buckets {
+		if err := tb.WaitN(ctx, n); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return os.ErrDeadlineExceeded
+			}
+			return fmt.Errorf("stream fermé pendant la limitation de débit: %w", err)
+		}
+	}
+	return nil
+}
+
+func (ls *limitedStream) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		end := written + limitedStreamSlice
+		if end > len(p) {
+			end = len(p)
+		}
+		if err := ls.wait(ls.upload, end-written, true); err != nil {
+			return written, err
+		}
+		n, err := ls.Stream.Write(p[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (ls *limitedStream) Read(p []byte) (int, error) {
+	if len(p) > limitedStreamSlice {
+		p = p[:limitedStreamSlice]
+	}
+	n, err := ls.Stream.Read(p)
+	if n > 0 && err == nil {
+		err = ls.wait(ls.download, n, false)
+	}
+	return n, err
+}
+
+// ============================================
+// API HTTP DES LIMITES
+// ============================================
+
+// limitsView est la représentation JSON des limites
+type limitsView struct {
+	Limits     Limits         `json:"limits"`
+	Schedule   []ScheduleRule `json:"schedule"`
+	Effective  Limits         `json:"effective"`
+	ActiveRule *ScheduleRule  `json:"active_rule,omitempty"`
+}
+
+func (bm *BandwidthManager) view() limitsView {
+	bm.lock.Lock()
+	defer bm.lock.Unlock()
+
+	v := limitsView{
+		Limits:    bm.base,
+		Schedule:  append([]ScheduleRule{}, bm.schedule...),
+		Effective: bm.effective,
+	}
+	if bm.active >= 0 {
+		rule := bm.schedule[bm.active]
+		v.ActiveRule = &rule
+	}
+	return v
+}
+
+// handleGetLimits renvoie les limites configurées et effectives
+func (d *Daemon) handleGetLimits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d.bandwidth.view())
+}
+
+// handleSetLimits modifie les limites à chaud
+func (d *Daemon) handleSetLimits(w http.ResponseWriter, r *http.Request) {
+	current := d.bandwidth.view()
+	req := struct {
+		Limits   Limits         `json:"limits"`
+		Schedule []ScheduleRule `json:"schedule"`
+	}{current.Limits, current.Schedule}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		shared.WriteError(w, r, shared.CodeInvalidRequest, shared.Details{"reason": err.Error()})
+		return
+	}
+
+	if err := d.bandwidth.Update(req.Limits, req.Schedule); err != nil {
+		shared.WriteError(w, r, shared.CodeInvalidLimits, shared.Details{"reason": err.Error()})
+		return
+	}
+
+	requestLogger(r).Info("limites de débit mises à jour", "upload_rate", req.Limits.UploadRate,
+		"download_rate", req.Limits.DownloadRate, "schedules", len(req.Schedule))
+	d.handleGetLimits(w, r)
+}
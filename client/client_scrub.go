@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// VÉRIFICATION D'INTÉGRITÉ DU CACHE
+// ============================================
+
+// Résultats possibles de la vérification d'un fichier
+const (
+	ScrubVerified   = "verified"   // toutes les empreintes correspondent
+	ScrubCorrupt    = "corrupt"    // taille ou empreintes incorrectes
+	ScrubUnknown    = "unknown"    // fichier inconnu du serveur (fichier égaré)
+	ScrubUnverified = "unverified" // manifest introuvable pour l'instant (serveur injoignable)
+)
+
+// ScrubResult est le résultat de la vérification d'un fichier
+type ScrubResult struct {
+	Filename  string `json:"filename"`
+	Status    string `json:"status"`
+	BadChunks int    `json:"bad_chunks,omitempty"`
+	Action    string `json:"action"` // seeded, not_seeded, quarantined, deleted, kept
+	Error     string `json:"error,omitempty"`
+}
+
+// ScrubReport suit la progression de la dernière vérification
+type ScrubReport struct {
+	Running    bool          `json:"running"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+	Total      int           `json:"total"`
+	Checked    int           `json:"checked"`
+	Verified   int           `json:"verified"`
+	Corrupt    int           `json:"corrupt"`
+	Results    []ScrubResult `json:"results"`
+}
+
+// startScrub lance une vérification en arrière-plan, sauf si une autre est en cours
+func (d *Daemon) startScrub(deleteCorrupt bool) bool {
+	d.scrubLock.Lock()
+	defer d.scrubLock.Unlock()
+
+	if d.scrub != nil && d.scrub.Running {
+		return false
+	}
+	d.scrub = &ScrubReport{Running: true, StartedAt: time.Now()}
+
+	go d.scrubCache(deleteCorrupt)
+	return true
+}
+
+// scheduleScrubs relance la vérification du cache à intervalle régulier
+func (d *Daemon) scheduleScrubs() {
+	if cfg.ScrubInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(cfg.ScrubInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		d.startScrub(!cfg.QuarantineCorrupt)
+	}
+}
+
+// scrubCache revérifie chaque fichier du cache contre son manifest.
+// Seuls les fichiers vérifiés sont seedés; les autres sont mis en quarantaine ou supprimés.
+func (d *Daemon) scrubCache(deleteCorrupt bool) {
+	entries, err := os.ReadDir(cfg.CacheDir)
+	if err != nil {
+		logCache.Error("cache illisible", "err", err)
+		d.finishScrub()
+		return
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files = append(files, entry.Name())
+		}
+	}
+
+	d.scrubLock.Lock()
+	d.scrub.Total = len(files)
+	d.scrubLock.Unlock()
+	logCache.Info("vérification du cache", "files", len(files))
+
+	for _, filename := range files {
+		result := d.scrubFile(filename, deleteCorrupt)
+
+		d.scrubLock.Lock()
+		d.scrub.Checked++
+		switch result.Status {
+		case ScrubVerified:
+			d.scrub.Verified++
+		case ScrubCorrupt, ScrubUnknown:
+			d.scrub.Corrupt++
+		}
+		d.scrub.Results = append(d.scrub.Results, result)
+		d.scrubLock.Unlock()
+	}
+
+	d.finishScrub()
+}
+
+// finishScrub marque la vérification comme terminée
+func (d *Daemon) finishScrub() {
+	d.scrubLock.Lock()
+	defer d.scrubLock.Unlock()
+
+	now := time.Now()
+	d.scrub.Running = false
+	d.scrub.FinishedAt = &now
+	logCache.Info("vérification terminée", "verified", d.scrub.Verified, "corrupt", d.scrub.Corrupt, "total", d.scrub.Total)
+}
+
+// scrubFile vérifie un fichier et applique le résultat
+func (d *Daemon) scrubFile(filename string, deleteCorrupt bool) ScrubResult {
+	result := ScrubResult{Filename: filename}
+	path := filepath.Join(cfg.CacheDir, filename)
+
+	manifest, status, err := d.scrubManifest(filename)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Status = status
+
+	if status == ScrubVerified {
+		info, err := os.Stat(path)
+		switch {
+		case err != nil:
+			result.Status, result.Error = ScrubUnverified, err.Error()
+		case info.Size() != manifest.Size:
+			result.Status = ScrubCorrupt
+			result.Error = fmt.Sprintf("taille %d au lieu de %d", info.Size(), manifest.Size)
+		default:
+			bad, err := verifyFile(path, manifest)
+			if err != nil {
+				result.Status, result.Error = ScrubUnverified, err.Error()
+			} else if len(bad) > 0 {
+				result.Status = ScrubCorrupt
+				result.BadChunks = len(bad)
+			}
+		}
+	}
+
+	switch result.Status {
+	case ScrubVerified:
+		if d.seedCachedFile(filename) {
+			result.Action = "seeded"
+		} else {
+			result.Action = "not_seeded"
+		}
+	case ScrubCorrupt, ScrubUnknown:
+		result.Action = d.discardCachedFile(filename, deleteCorrupt)
+		logCache.Warn("fichier invalide", "file", filename, "status", result.Status, "action", result.Action)
+	default:
+		// Sans manifest, impossible de trancher: ne pas seeder, réessayer plus tard
+		d.StopSeeding(filename, StopReasonUnverified)
+		result.Action = "kept"
+	}
+
+	return result
+}
+
+// scrubManifest renvoie le manifest local d'un fichier, ou celui du serveur à défaut
+func (d *Daemon) scrubManifest(filename string) (*Manifest, string, error) {
+	if manifest, err := loadManifest(filename); err == nil && manifest.Filename == filename {
+		return manifest, ScrubVerified, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	manifest, err := d.fetchManifest(ctx, d.serverPeerID, filename)
+	if errors.Is(err, shared.ErrNotFound) {
+		return nil, ScrubUnknown, err
+	}
+	if err != nil {
+		return nil, ScrubUnverified, err
+	}
+
+	if err := saveManifest(manifest); err != nil {
+		logCache.Warn("manifest non sauvegardé", "file", filename, "err", err)
+	}
+	return manifest, ScrubVerified, nil
+}
+
+// discardCachedFile met un fichier invalide en quarantaine ou le supprime
+func (d *Daemon) discardCachedFile(filename string, deleteCorrupt bool) string {
+	path := filepath.Join(cfg.CacheDir, filename)
+	action := "quarantined"
+
+	var err error
+	if deleteCorrupt {
+		action = "deleted"
+		err = os.Remove(path)
+	} else {
+		quarantined := filepath.Join(cfg.QuarantineDir(), fmt.Sprintf("%s.%d", filename, time.Now().Unix()))
+		err = os.Rename(path, quarantined)
+	}
+	if err != nil {
+		logCache.Error("fichier invalide non retiré", "file", filename, "err", err)
+		d.StopSeeding(filename, StopReasonUnverified)
+		return "kept"
+	}
+
+	os.Remove(manifestPath(filename))
+	d.cacheLock.Lock()
+	d.forgetCachedLocked(filename)
+	d.saveCacheStateLocked()
+	d.cacheLock.Unlock()
+	return action
+}
+
+// ============================================
+// API HTTP DE LA VÉRIFICATION
+// ============================================
+
+// handleGetScrub renvoie la progression et les résultats de la dernière vérification
+func (d *Daemon) handleGetScrub(w http.ResponseWriter, r *http.Request) {
+	d.writeScrub(w, http.StatusOK)
+}
+
+// writeScrub renvoie une copie du rapport de vérification courant
+func (d *Daemon) writeScrub(w http.ResponseWriter, code int) {
+	d.scrubLock.Lock()
+	var report ScrubReport
+	if d.scrub != nil {
+		report = *d.scrub
+		report.Results = append([]ScrubResult{}, d.scrub.Results...)
+	}
+	d.scrubLock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
+
+// handleStartScrub lance une vérification du cache ({"delete": true} supprime au lieu de mettre en quarantaine)
+func (d *Daemon) handleStartScrub(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Delete bool `json:"delete"`
+	}
+	req.Delete = !cfg.QuarantineCorrupt
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			shared.WriteError(w, r, shared.CodeInvalidRequest, shared.Details{"reason": err.Error()})
+			return
+		}
+	}
+
+	if !d.startScrub(req.Delete) {
+		shared.WriteError(w, r, shared.CodeScrubRunning, nil)
+		return
+	}
+
+	d.writeScrub(w, http.StatusAccepted)
+}
@@ -0,0 +1,557 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// POLITIQUE DE SEEDING
+// ============================================
+
+// Raisons d'arrêt du seeding
+const (
+	StopReasonRatio      = "ratio"
+	StopReasonSeedTime   = "seed_time"
+	StopReasonMaxFiles   = "max_files"
+	StopReasonManual     = "manual"
+	StopReasonUnverified = "unverified" // intégrité non vérifiable pour l'instant
+)
+
+var (
+	errNotSeedable          = errors.New("fichier absent du cache")
+	errSeedingPolicyReached = errors.New("politique de seeding déjà atteinte")
+	errPeerBusy             = shared.ErrBusy // le peer n'a plus de slot d'upload libre
+)
+
+// SeedingPolicy fixe quand arrêter de seeder un fichier (0 = sans limite)
+type SeedingPolicy struct {
+	MaxRatio    float64 `json:"max_ratio"`     // octets envoyés / octets téléchargés
+	MaxSeedTime int64   `json:"max_seed_time"` // secondes de seeding cumulées
+}
+
+// SeedingLimits regroupe la politique par défaut et les limites globales
+type SeedingLimits struct {
+	Default         SeedingPolicy `json:"default"`
+	MaxSeedingFiles int           `json:"max_seeding_files"` // fichiers seedés en même temps (0 = sans limite)
+	MaxUploadSlots  int           `json:"max_upload_slots"`  // chunks envoyés en même temps (0 = sans limite)
+}
+
+// SeedStats suit l'activité de seeding d'un fichier
+type SeedStats struct {
+	Filename   string         `json:"filename"`
+	Uploaded   int64          `json:"uploaded"`
+	Downloaded int64          `json:"downloaded"`
+	SeedTime   int64          `json:"seed_time"` // secondes cumulées, hors session en cours
+	Stopped    bool           `json:"stopped"`
+	StopReason string         `json:"stop_reason,omitempty"`
+	Policy     *SeedingPolicy `json:"policy,omitempty"` // surcharge propre au fichier
+	since      time.Time      // début de la session de seeding en cours
+}
+
+// seedingState est le contenu persisté dans StateDir/seeding.json. Les limites n'y
+// figurent que si elles ont été changées par PUT /seeding/policy.
+type seedingState struct {
+	Limits *override[SeedingLimits] `json:"limits_override,omitempty"`
+	Files  map[string]*SeedStats    `json:"files"`
+}
+
+// seedingLimits renvoie les limites de seeding de la configuration
+func (c *Config) seedingLimits() SeedingLimits {
+	return SeedingLimits{
+		Default:         SeedingPolicy{MaxRatio: c.MaxSeedRatio, MaxSeedTime: c.MaxSeedTime},
+		MaxSeedingFiles: c.MaxSeedingFiles,
+		MaxUploadSlots:  c.MaxUploadSlots,
+	}
+}
+
+// seedingStatePath renvoie le chemin du fichier d'état du seeding
+func seedingStatePath() string {
+	return filepath.Join(cfg.StateDir(), "seeding.json")
+}
+
+// elapsed renvoie le temps de seeding cumulé, session en cours comprise
+func (s *SeedStats) elapsed(now time.Time) time.Duration {
+	total := time.Duration(s.SeedTime) * time.Second
+	if !s.since.IsZero() {
+		total += now.Sub(s.since)
+	}
+	return total
+}
+
+// ratio renvoie le rapport octets envoyés / octets téléchargés
+func (s *SeedStats) ratio() float64 {
+	if s.Downloaded <= 0 {
+		return 0
+	}
+	return float64(s.Uploaded) / float64(s.Downloaded)
+}
+
+// seedingPolicyFor renvoie la politique applicable à un fichier (appelé sous seedersLock)
+func (d *Daemon) seedingPolicyFor(stats *SeedStats) SeedingPolicy {
+	if stats.Policy != nil {
+		return *stats.Policy
+	}
+	return d.seedingLimits.Default
+}
+
+// policyReached renvoie la raison d'arrêt si la politique est atteinte (appelé sous seedersLock)
+func (d *Daemon) policyReached(stats *SeedStats, now time.Time) string {
+	policy := d.seedingPolicyFor(stats)
+	if policy.MaxRatio > 0 && stats.ratio() >= policy.MaxRatio {
+		return StopReasonRatio
+	}
+	if policy.MaxSeedTime > 0 && stats.elapsed(now) >= time.Duration(policy.MaxSeedTime)*time.Second {
+		return StopReasonSeedTime
+	}
+	return ""
+}
+
+// seedStatsLocked renvoie les statistiques d'un fichier, créées à la demande (appelé sous seedersLock)
+func (d *Daemon) seedStatsLocked(filename string) *SeedStats {
+	stats, exists := d.seedStats[filename]
+	if !exists {
+		stats = &SeedStats{Filename: filename}
+		d.seedStats[filename] = stats
+	}
+	return stats
+}
+
+// stopSeedingLocked arrête de seeder un fichier sans le retirer du cache (appelé sous seedersLock)
+func (d *Daemon) stopSeedingLocked(filename, reason string) {
+	stats := d.seedStatsLocked(filename)
+	if !stats.since.IsZero() {
+		stats.SeedTime = int64(stats.elapsed(time.Now()) / time.Second)
+		stats.since = time.Time{}
+	}
+	stats.Stopped = true
+	stats.StopReason = reason
+	delete(d.activeSeeders, filename)
+}
+
+// oldestSeederLocked renvoie le fichier seedé depuis le plus longtemps (appelé sous seedersLock)
+func (d *Daemon) oldestSeederLocked() string {
+	oldest := ""
+	var since time.Time
+	for filename := range d.activeSeeders {
+		stats := d.seedStatsLocked(filename)
+		if oldest == "" || stats.since.Before(since) {
+			oldest, since = filename, stats.since
+		}
+	}
+	return oldest
+}
+
+// startSeeding commence à seeder un fichier complet du cache.
+// Si le nombre max de fichiers seedés est atteint, le plus ancien laisse sa place.
+func (d *Daemon) startSeeding(filename string) error {
+	info, err := os.Stat(filepath.Join(cfg.CacheDir, filename))
+	if err != nil || info.IsDir() {
+		return errNotSeedable
+	}
+
+	now := time.Now()
+	d.seedersLock.Lock()
+	if d.activeSeeders[filename] {
+		d.seedersLock.Unlock()
+		return nil
+	}
+
+	stats := d.seedStatsLocked(filename)
+	if stats.Downloaded == 0 {
+		stats.Downloaded = info.Size()
+	}
+	if reason := d.policyReached(stats, now); reason != "" {
+		stats.Stopped = true
+		stats.StopReason = reason
+		d.seedersLock.Unlock()
+		d.setSeedingState(filename, false)
+		d.publishSeeding(filename)
+		return errSeedingPolicyReached
+	}
+
+	evicted := ""
+	maxFiles := d.seedingLimits.MaxSeedingFiles
+	if maxFiles > 0 && len(d.activeSeeders) >= maxFiles {
+		evicted = d.oldestSeederLocked()
+		d.stopSeedingLocked(evicted, StopReasonMaxFiles)
+	}
+
+	d.activeSeeders[filename] = true
+	stats.Stopped = false
+	stats.StopReason = ""
+	stats.since = now
+	d.seedersLock.Unlock()
+
+	if evicted != "" {
+		logSeeding.Info("seeding arrêté: limite de fichiers seedés", "file", evicted, "max_seeding_files", maxFiles)
+		d.setSeedingState(evicted, false)
+		d.publishSeeding(evicted)
+	}
+	d.setSeedingState(filename, true)
+	d.publishSeeding(filename)
+	d.saveSeedingState()
+	logSeeding.Info("début du seeding", "file", filename)
+
+	// Se faire connaître des futurs téléchargeurs
+	go d.announceSeeding(filename)
+	return nil
+}
+
+// StopSeeding arrête de seeder un fichier, qui reste disponible en lecture
+func (d *Daemon) StopSeeding(filename, reason string) {
+	d.seedersLock.Lock()
+	if !d.activeSeeders[filename] {
+		d.seedersLock.Unlock()
+		return
+	}
+	d.stopSeedingLocked(filename, reason)
+	d.seedersLock.Unlock()
+
+	d.setSeedingState(filename, false)
+	d.publishSeeding(filename)
+	d.saveSeedingState()
+	logSeeding.Info("seeding arrêté", "file", filename, "reason", reason)
+}
+
+// setSeedingState aligne l'entrée de téléchargement sur l'état du seeding
+func (d *Daemon) setSeedingState(filename string, seeding bool) {
+	d.downloadsLock.Lock()
+	defer d.downloadsLock.Unlock()
+
+	ds, exists := d.downloads[filename]
+	if !exists {
+		return
+	}
+	switch {
+	case seeding && ds.Status == StateCompleted:
+		d.transition(ds, StateSeeding)
+	case !seeding && ds.Status == StateSeeding:
+		d.transition(ds, StateCompleted)
+	}
+}
+
+// seedCachedFile seede un fichier vérifié du cache, sauf si la politique l'a arrêté.
+// Un fichier écarté faute de place ou faute de manifest retente sa chance à chaque vérification du cache.
+func (d *Daemon) seedCachedFile(filename string) bool {
+	d.seedersLock.Lock()
+	if d.activeSeeders[filename] {
+		d.seedersLock.Unlock()
+		return true
+	}
+	stats := d.seedStatsLocked(filename)
+	stopped := stats.Stopped && stats.StopReason != StopReasonMaxFiles && stats.StopReason != StopReasonUnverified
+	full := d.seedingLimits.MaxSeedingFiles > 0 && len(d.activeSeeders) >= d.seedingLimits.MaxSeedingFiles
+	if full && !stopped {
+		stats.Stopped = true
+		stats.StopReason = StopReasonMaxFiles
+	}
+	d.seedersLock.Unlock()
+
+	if stopped || full {
+		return false
+	}
+	return d.startSeeding(filename) == nil
+}
+
+// enforceSeedingPolicies arrête régulièrement les fichiers ayant atteint leur politique
+func (d *Daemon) enforceSeedingPolicies() {
+	ticker := time.NewTicker(SeedingCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		d.checkSeedingPolicies()
+	}
+}
+
+// checkSeedingPolicies applique la politique à tous les fichiers seedés
+func (d *Daemon) checkSeedingPolicies() {
+	now := time.Now()
+	stopped := make(map[string]string)
+
+	d.seedersLock.Lock()
+	for filename := range d.activeSeeders {
+		if reason := d.policyReached(d.seedStatsLocked(filename), now); reason != "" {
+			stopped[filename] = reason
+		}
+	}
+	for max := d.seedingLimits.MaxSeedingFiles; max > 0 && len(d.activeSeeders)-len(stopped) > max; {
+		oldest := ""
+		for filename := range d.activeSeeders {
+			if _, done := stopped[filename]; done {
+				continue
+			}
+			if oldest == "" || d.seedStats[filename].since.Before(d.seedStats[oldest].since) {
+				oldest = filename
+			}
+		}
+		stopped[oldest] = StopReasonMaxFiles
+	}
+	for filename, reason := range stopped {
+		d.stopSeedingLocked(filename, reason)
+	}
+	d.seedersLock.Unlock()
+
+	for filename, reason := range stopped {
+		logSeeding.Info("seeding arrêté", "file", filename, "reason", reason)
+		d.setSeedingState(filename, false)
+		d.publishSeeding(filename)
+	}
+
+	// Sauvegarder aussi les compteurs d'upload et le temps de seeding
+	d.saveSeedingState()
+}
+
+// ============================================
+// SLOTS D'UPLOAD
+// ============================================
+
+// acquireUploadSlot réserve un slot d'upload, ou renvoie false si tous sont occupés
+func (d *Daemon) acquireUploadSlot() bool {
+	d.seedersLock.Lock()
+	defer d.seedersLock.Unlock()
+
+	if max := d.seedingLimits.MaxUploadSlots; max > 0 && d.uploadsActive >= max {
+		return false
+	}
+	d.uploadsActive++
+	return true
+}
+
+// releaseUploadSlot libère un slot d'upload
+func (d *Daemon) releaseUploadSlot() {
+	d.seedersLock.Lock()
+	d.uploadsActive--
+	d.seedersLock.Unlock()
+}
+
+// recordUpload comptabilise les octets envoyés pour un fichier et à un peer
+func (d *Daemon) recordUpload(filename string, to peer.ID, bytes int) {
+	d.seedersLock.Lock()
+	d.seedStatsLocked(filename).Uploaded += int64(bytes)
+	d.seedersLock.Unlock()
+
+	d.choker.RecordUpload(to, bytes)
+}
+
+// ============================================
+// PERSISTANCE
+// ============================================
+
+// loadSeedingState recharge les statistiques de seeding et les limites fixées par l'API
+func (d *Daemon) loadSeedingState() {
+	data, err := os.ReadFile(seedingStatePath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logSeeding.Warn("état du seeding illisible", "err", err)
+		}
+		return
+	}
+
+	var state seedingState
+	if err := json.Unmarshal(data, &state); err != nil {
+		logSeeding.Warn("état du seeding corrompu, ignoré", "err", err)
+		return
+	}
+
+	d.seedersLock.Lock()
+	d.seedingLimits, d.seedingOverride = state.Limits.resolve(cfg.seedingLimits())
+	if state.Limits != nil && d.seedingOverride == nil {
+		logSeeding.Info("limites de seeding modifiées depuis PUT /seeding/policy, la configuration s'applique")
+	}
+	for filename, stats := range state.Files {
+		stats.Filename = filename
+		d.seedStats[filename] = stats
+	}
+	d.seedersLock.Unlock()
+}
+
+// saveSeedingState persiste les statistiques et la surcharge des limites de seeding
+func (d *Daemon) saveSeedingState() {
+	now := time.Now()
+
+	d.seedersLock.Lock()
+	state := seedingState{
+		Limits: d.seedingOverride,
+		Files:  make(map[string]*SeedStats, len(d.seedStats)),
+	}
+	for filename, stats := range d.seedStats {
+		snapshot := *stats
+		snapshot.SeedTime = int64(stats.elapsed(now) / time.Second)
+		state.Files[filename] = &snapshot
+	}
+	d.seedersLock.Unlock()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := writeFileAtomic(seedingStatePath(), data); err != nil {
+		logSeeding.Error("état du seeding non sauvegardé", "err", err)
+	}
+}
+
+// ============================================
+// API HTTP DU SEEDING
+// ============================================
+
+// seedingFileView est l'état d'un fichier tel que renvoyé par l'API
+type seedingFileView struct {
+	SeedStats
+	Seeding         bool          `json:"seeding"`
+	Ratio           float64       `json:"ratio"`
+	SeedTime        int64         `json:"seed_time"`
+	EffectivePolicy SeedingPolicy `json:"effective_policy"`
+}
+
+// seedingFileViewLocked renvoie l'état d'un fichier (appelé sous seedersLock)
+func (d *Daemon) seedingFileViewLocked(filename string, stats *SeedStats, now time.Time) seedingFileView {
+	return seedingFileView{
+		SeedStats:       *stats,
+		Seeding:         d.activeSeeders[filename],
+		Ratio:           stats.ratio(),
+		SeedTime:        int64(stats.elapsed(now) / time.Second),
+		EffectivePolicy: d.seedingPolicyFor(stats),
+	}
+}
+
+// seedingOverview est la réponse de GET /seeding
+type seedingOverview struct {
+	Limits        SeedingLimits     `json:"limits"`
+	ActiveUploads int               `json:"active_uploads"`
+	Files         []seedingFileView `json:"files"`
+}
+
+// seedingView renvoie les limites et l'état de chaque fichier connu
+func (d *Daemon) seedingView() seedingOverview {
+	now := time.Now()
+
+	d.seedersLock.Lock()
+	files := make([]seedingFileView, 0, len(d.seedStats))
+	for filename, stats := range d.seedStats {
+		files = append(files, d.seedingFileViewLocked(filename, stats, now))
+	}
+	limits := d.seedingLimits
+	uploads := d.uploadsActive
+	d.seedersLock.Unlock()
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Filename < files[j].Filename })
+
+	return seedingOverview{
+		Limits:        limits,
+		ActiveUploads: uploads,
+		Files:         files,
+	}
+}
+
+// writeSeedingError traduit une erreur de seeding en code d'erreur
+func writeSeedingError(w http.ResponseWriter, r *http.Request, err error) {
+	details := shared.Details{"reason": err.Error()}
+	switch {
+	case errors.Is(err, errNotSeedable):
+		shared.WriteError(w, r, shared.CodeNotInCache, details)
+	case errors.Is(err, errSeedingPolicyReached):
+		shared.WriteError(w, r, shared.CodeSeedingPolicyReached, details)
+	default:
+		shared.WriteError(w, r, shared.CodeInvalidRequest, details)
+	}
+}
+
+// handleGetSeeding renvoie la politique et l'état du seeding
+func (d *Daemon) handleGetSeeding(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d.seedingView())
+}
+
+// handleSetSeedingLimits modifie la politique par défaut et les limites globales
+func (d *Daemon) handleSetSeedingLimits(w http.ResponseWriter, r *http.Request) {
+	d.seedersLock.Lock()
+	limits := d.seedingLimits
+	d.seedersLock.Unlock()
+
+	if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
+		shared.WriteError(w, r, shared.CodeInvalidRequest, shared.Details{"reason": err.Error()})
+		return
+	}
+	if limits.Default.MaxRatio < 0 || limits.Default.MaxSeedTime < 0 ||
+		limits.MaxSeedingFiles < 0 || limits.MaxUploadSlots < 0 {
+		shared.WriteError(w, r, shared.CodeInvalidLimits, shared.Details{"reason": "les limites doivent être positives ou nulles"})
+		return
+	}
+
+	d.seedersLock.Lock()
+	d.seedingLimits = limits
+	d.seedingOverride = newOverride(limits, cfg.seedingLimits())
+	d.seedersLock.Unlock()
+
+	requestLogger(r).Info("politique de seeding mise à jour", "max_ratio", limits.Default.MaxRatio,
+		"max_seed_time", limits.Default.MaxSeedTime, "max_seeding_files", limits.MaxSeedingFiles,
+		"max_upload_slots", limits.MaxUploadSlots)
+	d.checkSeedingPolicies()
+	d.handleGetSeeding(w, r)
+}
+
+// handleSetFilePolicy définit ou retire (policy: null) la politique propre à un fichier
+func (d *Daemon) handleSetFilePolicy(w http.ResponseWriter, r *http.Request) {
+	filename := filepath.Base(mux.Vars(r)["filename"])
+
+	var req struct {
+		Policy *SeedingPolicy `json:"policy"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		shared.WriteError(w, r, shared.CodeInvalidRequest, shared.Details{"reason": err.Error()})
+		return
+	}
+	if req.Policy != nil && (req.Policy.MaxRatio < 0 || req.Policy.MaxSeedTime < 0) {
+		shared.WriteError(w, r, shared.CodeInvalidLimits, shared.Details{"reason": "les limites doivent être positives ou nulles"})
+		return
+	}
+	if _, err := os.Stat(filepath.Join(cfg.CacheDir, filename)); err != nil {
+		writeSeedingError(w, r, errNotSeedable)
+		return
+	}
+
+	d.seedersLock.Lock()
+	d.seedStatsLocked(filename).Policy = req.Policy
+	d.seedersLock.Unlock()
+
+	d.checkSeedingPolicies()
+	d.publishSeeding(filename)
+	d.handleGetSeeding(w, r)
+}
+
+// handleStartSeeding relance le seeding d'un fichier du cache
+func (d *Daemon) handleStartSeeding(w http.ResponseWriter, r *http.Request) {
+	filename := filepath.Base(mux.Vars(r)["filename"])
+
+	if err := d.startSeeding(filename); err != nil {
+		writeSeedingError(w, r, fmt.Errorf("%s: %w", filename, err))
+		return
+	}
+
+	d.handleGetSeeding(w, r)
+}
+
+// handleStopSeeding arrête le seeding d'un fichier, qui reste en cache
+func (d *Daemon) handleStopSeeding(w http.ResponseWriter, r *http.Request) {
+	filename := filepath.Base(mux.Vars(r)["filename"])
+
+	if !d.isSeeding(filename) {
+		shared.WriteError(w, r, shared.CodeNotSeeding, shared.Details{"filename": filename})
+		return
+	}
+	d.StopSeeding(filename, StopReasonManual)
+
+	d.handleGetSeeding(w, r)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/protocol"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// ARRÊT PROPRE
+// ============================================
+
+// savedDownload est un téléchargement non terminé, persisté à l'arrêt
+type savedDownload struct {
+	Filename string `json:"filename"`
+	Status   string `json:"status"` // queued, paused ou error
+	Priority int    `json:"priority"`
+	Error    string `json:"error,omitempty"`
+}
+
+func downloadsStatePath() string {
+	return filepath.Join(cfg.StateDir(), "downloads.json")
+}
+
+// beginTransfer enregistre un travail en cours (téléchargement, stream P2P entrant)
+// que l'arrêt doit attendre; renvoie false une fois l'arrêt commencé
+func (d *Daemon) beginTransfer() bool {
+	d.transfersLock.Lock()
+	defer d.transfersLock.Unlock()
+
+	if d.shuttingDown {
+		return false
+	}
+	d.transfers.Add(1)
+	return true
+}
+
+// endTransfer signale la fin d'un travail enregistré par beginTransfer
+func (d *Daemon) endTransfer() {
+	d.transfers.Done()
+}
+
+// isShuttingDown indique si l'arrêt a commencé
+func (d *Daemon) isShuttingDown() bool {
+	d.transfersLock.Lock()
+	defer d.transfersLock.Unlock()
+	return d.shuttingDown
+}
+
+// stopAccepting refuse les nouveaux streams P2P, déconnecte les clients de /events,
+// persiste la file de téléchargements puis arrête les téléchargements en cours.
+// Chaque worker termine le chunk qu'il écrit: le bitfield reste cohérent avec le .part.
+func (d *Daemon) stopAccepting() {
+	d.transfersLock.Lock()
+	d.shuttingDown = true
+	d.transfersLock.Unlock()
+
+	d.p2pHost.RemoveStreamHandler(protocol.ID(P2PProtocolID))
+	d.events.Close()
+
+	d.downloadsLock.Lock()
+	defer d.downloadsLock.Unlock()
+
+	d.saveDownloadsStateLocked()
+	for _, ds := range d.downloads {
+		if ds.Status == StateDownloading && ds.cancel != nil {
+			ds.stopAs = StatePaused
+			ds.cancel()
+		}
+	}
+}
+
+// Shutdown attend les transferts en cours jusqu'à l'échéance de ctx, persiste
+// l'état du daemon puis ferme le nœud P2P. Appelé après stopAccepting.
+func (d *Daemon) Shutdown(ctx context.Context) error {
+	drained := make(chan struct{})
+	go func() {
+		d.transfers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		logMain.Info("transferts en cours terminés")
+	case <-ctx.Done():
+		logMain.Warn("délai d'arrêt dépassé, transferts restants interrompus")
+	}
+	// Plus aucun stream ne doit rester bloqué dans une attente de jetons
+	d.bandwidth.Close()
+
+	d.saveSeedingState()
+	d.cacheLock.Lock()
+	d.saveCacheStateLocked()
+	d.cacheLock.Unlock()
+	d.catalogLock.Lock()
+	d.saveCatalogMirrorLocked()
+	d.catalogLock.Unlock()
+	logMain.Info("état du daemon sauvegardé")
+
+	return d.p2pHost.Close()
+}
+
+// saveDownloadsStateLocked persiste les téléchargements non terminés (appelé sous downloadsLock).
+// Un téléchargement en cours est enregistré en file: il reprendra au prochain démarrage.
+func (d *Daemon) saveDownloadsStateLocked() {
+	saved := make([]savedDownload, 0, len(d.downloads))
+	for _, ds := range d.downloads {
+		status := ds.Status
+		switch status {
+		case StateDownloading:
+			status = StateQueued
+		case StateQueued, StatePaused, StateError:
+		default:
+			continue
+		}
+		saved = append(saved, savedDownload{Filename: ds.Filename, Status: status, Priority: ds.Priority, Error: ds.Error})
+	}
+
+	// Ordre de la file conservé au redémarrage
+	sort.SliceStable(saved, func(i, j int) bool {
+		return d.downloads[saved[i].Filename].queuedAt.Before(d.downloads[saved[j].Filename].queuedAt)
+	})
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := writeFileAtomic(downloadsStatePath(), data); err != nil {
+		logDownload.Error("file de téléchargements non sauvegardée", "err", err)
+	}
+}
+
+// restoreDownloads recharge la file de téléchargements persistée au dernier arrêt.
+// Le fichier est supprimé une fois lu: après un crash, seuls les .part sont repris.
+func (d *Daemon) restoreDownloads() {
+	data, err := os.ReadFile(downloadsStatePath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logDownload.Warn("file de téléchargements illisible", "err", err)
+		}
+		return
+	}
+	os.Remove(downloadsStatePath())
+
+	var saved []savedDownload
+	if err := json.Unmarshal(data, &saved); err != nil {
+		logDownload.Warn("file de téléchargements corrompue, ignorée", "err", err)
+		return
+	}
+
+	d.downloadsLock.Lock()
+	now := time.Now()
+	restored := 0
+	for i, entry := range saved {
+		filename := filepath.Base(entry.Filename)
+		if _, err := os.Stat(filepath.Join(cfg.CacheDir, filename)); err == nil {
+			continue // Terminé entre-temps
+		}
+		if _, exists := d.downloads[filename]; exists {
+			continue
+		}
+
+		ds := &DownloadStatus{
+			DownloadStatus: shared.DownloadStatus{
+				Filename:  filename,
+				Status:    entry.Status,
+				Priority:  entry.Priority,
+				Error:     entry.Error,
+				StartedAt: now,
+			},
+			queuedAt: now.Add(time.Duration(i)), // Ordre de la file conservé
+		}
+		switch ds.Status {
+		case StateQueued, StatePaused, StateError:
+		default:
+			ds.Status = StateQueued
+		}
+		d.downloads[filename] = ds
+		d.publishDownload(ds)
+		restored++
+	}
+	d.downloadsLock.Unlock()
+
+	if restored > 0 {
+		logDownload.Info("téléchargements restaurés", "count", restored)
+		d.schedule()
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ============================================
+// STREAMING PENDANT LE TÉLÉCHARGEMENT
+// ============================================
+
+// errStreamTimeout est renvoyée quand un chunk n'arrive pas à temps pour la lecture
+var errStreamTimeout = errors.New("chunk non disponible à temps")
+
+// streamReader expose un téléchargement en cours comme un io.ReadSeeker de la
+// taille finale du fichier. Chaque lecture bloque jusqu'à ce que le chunk
+// concerné soit vérifié, et signale au piece picker la zone demandée.
+type streamReader struct {
+	d       *Daemon
+	ctx     context.Context
+	partial *partialDownload
+	size    int64
+	offset  int64
+	seeked  bool
+	cached  *os.File // fichier complet, une fois le téléchargement terminé
+}
+
+// Seek implémente io.Seeker sur la taille finale du fichier
+func (sr *streamReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += sr.offset
+	case io.SeekEnd:
+		offset += sr.size
+	default:
+		return 0, fmt.Errorf("whence %d invalide", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("offset négatif")
+	}
+
+	sr.offset = offset
+	sr.seeked = true
+	return offset, nil
+}
+
+// Read lit à partir de l'offset courant en attendant les chunks manquants
+func (sr *streamReader) Read(p []byte) (int, error) {
+	if sr.offset >= sr.size {
+		return 0, io.EOF
+	}
+
+	// Téléchargement terminé entre-temps: lire directement le fichier du cache
+	if sr.cached != nil {
+		n, err := sr.cached.ReadAt(p, sr.offset)
+		sr.offset += int64(n)
+		if err == io.EOF && n > 0 {
+			err = nil
+		}
+		return n, err
+	}
+
+	// Premier accès après un seek: déplacer la fenêtre de lecture
+	if sr.seeked {
+		sr.d.setPlaybackOffset(sr.partial.filename, sr.offset)
+		sr.seeked = false
+	}
+
+	chunkSize := int64(sr.partial.manifest.ChunkSize)
+	chunk := int(sr.offset / chunkSize)
+	if err := sr.waitChunk(chunk); err != nil {
+		return 0, err
+	}
+
+	data, err := sr.partial.readChunk(chunk)
+	if errors.Is(err, os.ErrClosed) {
+		if err := sr.reopen(); err != nil {
+			return 0, err
+		}
+		return sr.Read(p)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	n := copy(p, data[sr.offset-int64(chunk)*chunkSize:])
+	sr.offset += int64(n)
+	return n, nil
+}
+
+// waitChunk bloque jusqu'à ce qu'un chunk soit vérifié, avec un délai max. Un
+// téléchargement arrêté n'est une erreur que si le chunk demandé manque.
+func (sr *streamReader) waitChunk(chunk int) error {
+	timeout := time.NewTimer(StreamChunkTimeout)
+	defer timeout.Stop()
+
+	hinted := false
+	for {
+		updated, closed := sr.partial.waitState()
+		if sr.partial.has(chunk) {
+			return nil
+		}
+		if closed {
+			return fmt.Errorf("téléchargement de %s interrompu", sr.partial.filename)
+		}
+
+		// La lecture a rattrapé le téléchargement: prioriser cette zone
+		if !hinted {
+			sr.d.setPlaybackOffset(sr.partial.filename, int64(chunk)*int64(sr.partial.manifest.ChunkSize))
+			hinted = true
+		}
+
+		select {
+		case <-updated:
+		case <-timeout.C:
+			return errStreamTimeout
+		case <-sr.ctx.Done():
+			return sr.ctx.Err()
+		}
+	}
+}
+
+// reopen bascule sur le fichier complet s'il a été déplacé dans le cache, sinon sur
+// le .part en lecture seule: le téléchargement a été mis en pause
+func (sr *streamReader) reopen() error {
+	file, err := os.Open(filepath.Join(cfg.CacheDir, sr.partial.filename))
+	if err == nil {
+		sr.cached = file
+		return nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	snapshot, err := openPartialSnapshot(sr.partial.filename)
+	if err != nil {
+		return fmt.Errorf("téléchargement de %s interrompu: %w", sr.partial.filename, err)
+	}
+	sr.partial = snapshot
+	return nil
+}
+
+// Close libère le fichier du cache ou le .part en lecture seule éventuellement ouverts
+func (sr *streamReader) Close() error {
+	if sr.partial.readOnly {
+		sr.partial.close()
+	}
+	if sr.cached != nil {
+		return sr.cached.Close()
+	}
+	return nil
+}
+
+// servePartial sert un téléchargement en cours avec support des requêtes Range
+func (d *Daemon) servePartial(w http.ResponseWriter, r *http.Request, partial *partialDownload) {
+	reader := &streamReader{
+		d:       d,
+		ctx:     r.Context(),
+		partial: partial,
+		size:    partial.manifest.Size,
+	}
+	defer reader.Close()
+
+	// Éviter que ServeContent ne lise le début du fichier pour deviner le type
+	contentType := mime.TypeByExtension(filepath.Ext(partial.filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+
+	requestLogger(r).Debug("streaming pendant le téléchargement", "file", partial.filename, "range", r.Header.Get("Range"))
+	http.ServeContent(w, r, partial.filename, time.Time{}, reader)
+}
+
+// waitForPartial attend qu'un téléchargement en file d'attente démarre
+func (d *Daemon) waitForPartial(ctx context.Context, filename string) *partialDownload {
+	deadline := time.NewTimer(StreamChunkTimeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if partial := d.getPartial(filename); partial != nil {
+			return partial
+		}
+
+		d.downloadsLock.RLock()
+		status, exists := d.downloads[filename]
+		pending := exists && (status.Status == StateQueued || status.Status == StateDownloading)
+		d.downloadsLock.RUnlock()
+		if !pending {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// waitState renvoie un canal fermé au prochain chunk vérifié et l'état de fermeture.
+// Un .part en lecture seule ne recevra plus de chunk: il compte comme fermé.
+func (p *partialDownload) waitState() (<-chan struct{}, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.updated, p.file == nil || p.readOnly
+}
+
+// notifyUpdated réveille les lecteurs en attente (appelé sous lock)
+func (p *partialDownload) notifyUpdated() {
+	close(p.updated)
+	p.updated = make(chan struct{})
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// SWARM (DISPONIBILITÉ DES CHUNKS CHEZ LES PEERS)
+// ============================================
+
+// PeerInfo décrit un peer renvoyé par le tracker du serveur (pipbingo/shared)
+type PeerInfo = shared.PeerInfo
+
+// Swarm retient, pour chaque fichier, le bitfield connu de chaque peer
+type Swarm struct {
+	files    map[string]map[peer.ID]*Bitfield
+	chokedBy map[peer.ID]time.Time // peers qui nous ont répondu "choked", et quand
+	lock     sync.RWMutex
+}
+
+func NewSwarm() *Swarm {
+	return &Swarm{
+		files:    make(map[string]map[peer.ID]*Bitfield),
+		chokedBy: make(map[peer.ID]time.Time),
+	}
+}
+
+// setChokedBy note qu'un peer refuse de nous servir jusqu'à son prochain rechoke
+func (sw *Swarm) setChokedBy(id peer.ID) {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	sw.chokedBy[id] = time.Now()
+}
+
+// setBitfield remplace le bitfield connu d'un peer
+func (sw *Swarm) setBitfield(filename string, id peer.ID, bf *Bitfield) {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+
+	peers, ok := sw.files[filename]
+	if !ok {
+		peers = make(map[peer.ID]*Bitfield)
+		sw.files[filename] = peers
+	}
+	peers[id] = bf
+}
+
+// setHave marque un chunk comme disponible chez un peer
+func (sw *Swarm) setHave(filename string, id peer.ID, chunkIndex int) bool {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+
+	bf, ok := sw.files[filename][id]
+	if !ok {
+		return false
+	}
+	bf.Set(chunkIndex)
+	return true
+}
+
+// removePeer oublie un peer pour un fichier (injoignable ou incohérent)
+func (sw *Swarm) removePeer(filename string, id peer.ID) {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	delete(sw.files[filename], id)
+}
+
+// peers renvoie les membres connus du swarm d'un fichier
+func (sw *Swarm) peers(filename string) []peer.ID {
+	sw.lock.RLock()
+	defer sw.lock.RUnlock()
+
+	ids := make([]peer.ID, 0, len(sw.files[filename]))
+	for id := range sw.files[filename] {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// peersWithChunk renvoie les peers qui possèdent un chunk vérifié
+// et ne nous ont pas chokés depuis moins d'une période de rechoke
+func (sw *Swarm) peersWithChunk(filename string, chunkIndex int) []peer.ID {
+	sw.lock.RLock()
+	defer sw.lock.RUnlock()
+
+	var ids []peer.ID
+	for id, bf := range sw.files[filename] {
+		if at, choked := sw.chokedBy[id]; choked && time.Since(at) < ChokeInterval {
+			continue
+		}
+		if bf.Has(chunkIndex) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// availability renvoie le nombre de peers possédant un chunk
+func (sw *Swarm) availability(filename string, chunkIndex int) int {
+	sw.lock.RLock()
+	defer sw.lock.RUnlock()
+
+	count := 0
+	for _, bf := range sw.files[filename] {
+		if bf.Has(chunkIndex) {
+			count++
+		}
+	}
+	return count
+}
+
+// ============================================
+// ÉCHANGES AVEC LES PEERS
+// ============================================
+
+// joinSwarm s'annonce auprès du tracker puis échange les bitfields avec les autres peers
+func (d *Daemon) joinSwarm(ctx context.Context, filename string, have *Bitfield) {
+	peers, err := d.p2p.Announce(ctx, d.serverPeerID, filename)
+	if err != nil {
+		logSwarm.Warn("annonce impossible", "file", filename, "err", err)
+		return
+	}
+
+	for _, info := range peers {
+		id, err := peer.Decode(info.ID)
+		if err != nil || id == d.p2pHost.ID() {
+			continue
+		}
+
+		addrInfo := peer.AddrInfo{ID: id}
+		for _, addr := range info.Addrs {
+			if ma, err := multiaddr.NewMultiaddr(addr); err == nil {
+				addrInfo.Addrs = append(addrInfo.Addrs, ma)
+			}
+		}
+
+		go d.exchangeBitfield(ctx, filename, addrInfo, have)
+	}
+}
+
+// exchangeBitfield envoie notre bitfield à un peer et enregistre le sien
+func (d *Daemon) exchangeBitfield(ctx context.Context, filename string, addrInfo peer.AddrInfo, have *Bitfield) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	if err := d.p2pHost.Connect(ctx, addrInfo); err != nil {
+		return
+	}
+
+	response, err := d.p2p.Do(ctx, addrInfo.ID, P2PRequest{
+		Action:   shared.ActionBitfield,
+		Filename: filename,
+		Bitfield: have.Bytes(),
+	})
+	if err != nil {
+		return
+	}
+
+	bf, err := BitfieldFromBytes(response.Bitfield, have.Len())
+	if err != nil {
+		logSwarm.Warn("bitfield invalide reçu", "file", filename, "peer", addrInfo.ID.ShortString(), "err", err)
+		return
+	}
+
+	d.swarm.setBitfield(filename, addrInfo.ID, bf)
+	logSwarm.Debug("bitfield échangé", "file", filename, "peer", addrInfo.ID.ShortString(),
+		"chunks_have", bf.Count(), "total_chunks", bf.Len())
+}
+
+// broadcastHave prévient les membres du swarm qu'un nouveau chunk est disponible
+func (d *Daemon) broadcastHave(filename string, chunkIndex int) {
+	for _, id := range d.swarm.peers(filename) {
+		go func(id peer.ID) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			if _, err := d.p2p.Do(ctx, id, P2PRequest{
+				Action:     shared.ActionHave,
+				Filename:   filename,
+				ChunkIndex: chunkIndex,
+			}); err != nil {
+				d.swarm.removePeer(filename, id)
+			}
+		}(id)
+	}
+}
+
+// announceSeeding inscrit un fichier complet auprès du tracker
+func (d *Daemon) announceSeeding(filename string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if _, err := d.p2p.Announce(ctx, d.serverPeerID, filename); err != nil {
+		logSwarm.Warn("annonce impossible", "file", filename, "err", err)
+	}
+}
+
+// pickSource choisit un peer possédant le chunk, ou le serveur à défaut
+func (d *Daemon) pickSource(filename string, chunkIndex int) peer.ID {
+	candidates := d.swarm.peersWithChunk(filename, chunkIndex)
+	if len(candidates) == 0 {
+		return d.serverPeerID
+	}
+	return candidates[rand.Intn(len(candidates))]
+}
+
+// ============================================
+// ÉTAT LOCAL DES CHUNKS
+// ============================================
+
+// registerPartial rend un téléchargement en cours visible des autres peers
+func (d *Daemon) registerPartial(partial *partialDownload) {
+	d.partialsLock.Lock()
+	d.partials[partial.filename] = partial
+	d.partialsLock.Unlock()
+}
+
+// unregisterPartial retire un téléchargement terminé ou abandonné
+func (d *Daemon) unregisterPartial(filename string) {
+	d.partialsLock.Lock()
+	delete(d.partials, filename)
+	d.partialsLock.Unlock()
+}
+
+// getPartial renvoie le téléchargement en cours d'un fichier
+func (d *Daemon) getPartial(filename string) *partialDownload {
+	d.partialsLock.RLock()
+	defer d.partialsLock.RUnlock()
+	return d.partials[filename]
+}
+
+// isSeeding indique si un fichier complet est seedé
+func (d *Daemon) isSeeding(filename string) bool {
+	d.seedersLock.RLock()
+	defer d.seedersLock.RUnlock()
+	return d.activeSeeders[filename]
+}
+
+// localManifest renvoie le manifest d'un fichier complet ou en cours
+func (d *Daemon) localManifest(filename string) *Manifest {
+	if partial := d.getPartial(filename); partial != nil {
+		return partial.manifest
+	}
+	if !d.isSeeding(filename) {
+		return nil
+	}
+	manifest, err := loadManifest(filename)
+	if err != nil {
+		return nil
+	}
+	return manifest
+}
+
+// localBitfield renvoie les chunks vérifiés dont on dispose pour un fichier
+func (d *Daemon) localBitfield(filename string, manifest *Manifest) *Bitfield {
+	if partial := d.getPartial(filename); partial != nil {
+		return partial.bitfield()
+	}
+
+	bf := NewBitfield(manifest.TotalChunks())
+	for i := 0; i < bf.Len(); i++ {
+		bf.Set(i)
+	}
+	return bf
+}
+
+// ============================================
+// HANDLERS P2P DU SWARM
+// ============================================
+
+// handleChunkRequest sert un chunk vérifié, que le fichier soit complet ou non
+func (d *Daemon) handleChunkRequest(stream network.Stream, req P2PRequest) {
+	filename := filepath.Base(req.Filename)
+
+	seeding := d.isSeeding(filename)
+	partial := d.getPartial(filename)
+	if !seeding && (partial == nil || !partial.has(req.ChunkIndex)) {
+		d.sendP2PError(stream, shared.CodeFileNotAvailable, shared.Details{"filename": filename})
+		return
+	}
+
+	// Seuls les peers débloqués par le choker sont servis
+	from := stream.Conn().RemotePeer()
+	if !d.choker.Allow(from) {
+		shared.SetP2PResult(stream, shared.StatusChoked)
+		json.NewEncoder(stream).Encode(P2PResponse{Status: shared.StatusChoked, ChunkIndex: req.ChunkIndex})
+		return
+	}
+
+	// Limiter le nombre d'envois simultanés
+	if !d.acquireUploadSlot() {
+		d.sendP2PError(stream, shared.CodeNoUploadSlot, nil)
+		return
+	}
+	defer d.releaseUploadSlot()
+
+	if seeding {
+		d.sendFileChunk(stream, req)
+		return
+	}
+
+	data, err := partial.readChunk(req.ChunkIndex)
+	if err != nil {
+		d.sendP2PError(stream, shared.CodeReadError, nil)
+		return
+	}
+
+	response := P2PResponse{
+		Status:      shared.StatusSuccess,
+		ChunkData:   data,
+		ChunkIndex:  req.ChunkIndex,
+		TotalChunks: partial.manifest.TotalChunks(),
+	}
+
+	if err := json.NewEncoder(stream).Encode(response); err != nil {
+		shared.SetP2PResult(stream, "send_error")
+		return
+	}
+	d.recordUpload(filename, from, len(data))
+	streamLogger(stream).Debug("chunk partiel envoyé", "chunk", req.ChunkIndex,
+		"total_chunks", partial.manifest.TotalChunks(), "bytes", len(data))
+}
+
+// handleManifestRequest renvoie le manifest d'un fichier qu'on partage
+func (d *Daemon) handleManifestRequest(stream network.Stream, req P2PRequest) {
+	filename := filepath.Base(req.Filename)
+	manifest := d.localManifest(filename)
+	if manifest == nil {
+		d.sendP2PError(stream, shared.CodeFileNotAvailable, shared.Details{"filename": filename})
+		return
+	}
+
+	json.NewEncoder(stream).Encode(P2PResponse{
+		Status:      shared.StatusSuccess,
+		TotalChunks: manifest.TotalChunks(),
+		Manifest:    manifest,
+	})
+}
+
+// handleBitfieldRequest enregistre le bitfield d'un peer et répond avec le nôtre
+func (d *Daemon) handleBitfieldRequest(stream network.Stream, req P2PRequest) {
+	filename := filepath.Base(req.Filename)
+	from := stream.Conn().RemotePeer()
+
+	manifest := d.localManifest(filename)
+	if manifest == nil {
+		d.sendP2PError(stream, shared.CodeFileNotAvailable, shared.Details{"filename": filename})
+		return
+	}
+
+	theirs, err := BitfieldFromBytes(req.Bitfield, manifest.TotalChunks())
+	if err != nil {
+		d.sendP2PError(stream, shared.CodeInvalidBitfield, shared.Details{"reason": err.Error()})
+		return
+	}
+	d.swarm.setBitfield(filename, from, theirs)
+
+	ours := d.localBitfield(filename, manifest)
+	json.NewEncoder(stream).Encode(P2PResponse{
+		Status:      shared.StatusSuccess,
+		TotalChunks: manifest.TotalChunks(),
+		Bitfield:    ours.Bytes(),
+	})
+
+	streamLogger(stream).Debug("bitfield reçu", "chunks_have", theirs.Count(), "total_chunks", theirs.Len())
+}
+
+// handleHaveRequest met à jour la disponibilité d'un chunk chez un peer
+func (d *Daemon) handleHaveRequest(stream network.Stream, req P2PRequest) {
+	filename := filepath.Base(req.Filename)
+
+	if !d.swarm.setHave(filename, stream.Conn().RemotePeer(), req.ChunkIndex) {
+		// Pas encore d'échange de bitfield avec ce peer
+		d.sendP2PError(stream, shared.CodeUnknownPeer, nil)
+		return
+	}
+
+	json.NewEncoder(stream).Encode(P2PResponse{
+		Status:     shared.StatusSuccess,
+		ChunkIndex: req.ChunkIndex,
+	})
+}
+
+// readChunk lit un chunk vérifié depuis le fichier .part
+func (p *partialDownload) readChunk(i int) ([]byte, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.file == nil {
+		return nil, os.ErrClosed
+	}
+
+	data := make([]byte, p.manifest.ChunkLength(i))
+	if _, err := p.file.ReadAt(data, int64(i)*int64(p.manifest.ChunkSize)); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// bitfield renvoie une copie du bitfield courant
+func (p *partialDownload) bitfield() *Bitfield {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	bf, _ := BitfieldFromBytes(p.have.Bytes(), p.have.Len())
+	return bf
+}
@@ -15,6 +15,8 @@ import { useP2PStatus, useVideoDownload } from '../hooks/useP2PStatus';
 
 const VideoPlayer = ({ video }) => {
   const videoRef = useRef(null);
+  const filenameRef = useRef(video.filename);
+  filenameRef.current = video.filename;
   const [playing, setPlaying] = useState(false);
   const [progress, setProgress] = useState(0);
   const [volume, setVolume] = useState(1);
@@ -49,8 +51,9 @@ const VideoPlayer = ({ video }) => {
   }, [video.filename]);
 
   // Surveiller la progression du téléchargement
+  // (le daemon sait streamer pendant le téléchargement: inutile d'attendre la fin)
   useEffect(() => {
-    if (p2pStatus?.status === 'completed' || p2pStatus?.status === 'seeding') {
+    if (['downloading', 'completed', 'seeding'].includes(p2pStatus?.status)) {
       setVideoReady(true);
       setLoading(false);
     }
@@ -82,6 +85,31 @@ const VideoPlayer = ({ video }) => {
     return () => video.removeEventListener('timeupdate', updateProgress);
   }, [videoReady]);
 
+  // Signaler la position de lecture au daemon (toutes les 2s, et tout de suite après un seek)
+  useEffect(() => {
+    const video = videoRef.current;
+    if (!video) return;
+
+    let lastReport = 0;
+    const report = (force) => {
+      const now = Date.now();
+      if (!force && now - lastReport < 2000) return;
+      if (!video.duration) return;
+      lastReport = now;
+      daemon.reportPlayback(filenameRef.current, video.currentTime, video.duration).catch(() => {});
+    };
+
+    const onTimeUpdate = () => report(false);
+    const onSeeking = () => report(true);
+
+    video.addEventListener('timeupdate', onTimeUpdate);
+    video.addEventListener('seeking', onSeeking);
+    return () => {
+      video.removeEventListener('timeupdate', onTimeUpdate);
+      video.removeEventListener('seeking', onSeeking);
+    };
+  }, [videoReady]);
+
   // Gestion du volume
   const handleVolumeChange = (e) => {
     const newVolume = parseFloat(e.target.value);
@@ -2,7 +2,7 @@ import { useState, useEffect } from 'react';
 import { daemon } from '../services/api';
 
 /**
- * Hook pour surveiller le statut P2P en temps réel
+ * Hook pour surveiller le statut P2P en temps réel (événements poussés par le daemon)
  */
 export const useP2PStatus = (filename, enabled = true) => {
   const [status, setStatus] = useState(null);
@@ -15,45 +15,44 @@ export const useP2PStatus = (filename, enabled = true) => {
       return;
     }
 
-    let interval;
-
-    const fetchStatus = async () => {
-      try {
-        const allStatuses = await daemon.getDownloadStatus();
-        const fileStatus = allStatuses[filename];
-        
-        if (fileStatus) {
-          setStatus(fileStatus);
-          setError(null);
-        } else {
-          setStatus(null);
-        }
-        
-        setLoading(false);
-      } catch (err) {
-        setError(err.message);
-        setLoading(false);
-      }
-    };
+    const source = daemon.subscribeEvents({
+      video: filename,
+      types: ['download', 'download_removed'],
+    });
 
-    // Fetch initial
-    fetchStatus();
+    // L'état courant est rejoué à la connexion, puis chaque changement est poussé
+    source.addEventListener('download', (event) => {
+      const { data } = JSON.parse(event.data);
+      setStatus(data);
+      setError(null);
+      setLoading(false);
+    });
 
-    // Poll toutes les 2 secondes
-    interval = setInterval(fetchStatus, 2000);
+    source.addEventListener('download_removed', () => {
+      setStatus(null);
+    });
 
-    return () => {
-      if (interval) clearInterval(interval);
+    source.onopen = () => {
+      setError(null);
+      setLoading(false);
     };
+
+    // EventSource se reconnecte tout seul; le rejeu remet l'état à jour
+    source.onerror = () => {
+      setError('Daemon P2P injoignable');
+      setLoading(false);
+    };
+
+    return () => source.close();
   }, [filename, enabled]);
 
   return { status, loading, error };
 };
 
 /**
- * Hook pour les statistiques P2P globales
+ * Hook pour les statistiques P2P globales (événements poussés par le daemon)
  */
-export const useP2PStats = (refreshInterval = 5000) => {
+export const useP2PStats = () => {
   const [stats, setStats] = useState({
     peer_id: '',
     connected_peers: 0,
@@ -65,32 +64,26 @@ export const useP2PStats = (refreshInterval = 5000) => {
   const [error, setError] = useState(null);
 
   useEffect(() => {
-    let interval;
-
-    const fetchStats = async () => {
-      try {
-        const data = await daemon.getP2PStats();
-        setStats(data);
-        setError(null);
-        setLoading(false);
-      } catch (err) {
-        setError(err.message);
-        setLoading(false);
-      }
-    };
+    const source = daemon.subscribeEvents({ types: ['stats'] });
 
-    // Fetch initial
-    fetchStats();
+    source.addEventListener('stats', (event) => {
+      const { data } = JSON.parse(event.data);
+      setStats(data);
+      setError(null);
+      setLoading(false);
+    });
 
-    // Poll régulier
-    if (refreshInterval > 0) {
-      interval = setInterval(fetchStats, refreshInterval);
-    }
+    source.onopen = () => {
+      setError(null);
+    };
 
-    return () => {
-      if (interval) clearInterval(interval);
+    source.onerror = () => {
+      setError('Daemon P2P injoignable');
+      setLoading(false);
     };
-  }, [refreshInterval]);
+
+    return () => source.close();
+  }, []);
 
   return { stats, loading, error };
 };
@@ -2,26 +2,65 @@ import { useState, useEffect } from 'react';
 import { motion } from 'framer-motion';
 import HeroHeader from '../components/HeroHeader';
 import VideoGrid from '../components/VideoGrid';
-import { api } from '../services/api';
+import { api, daemon } from '../services/api';
 
 const Home = () => {
   const [videos, setVideos] = useState([]);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState(null);
+  const [offline, setOffline] = useState(false);
 
   useEffect(() => {
+    // S'abonner avant de charger la liste: aucun changement perdu entre les deux
+    const source = api.subscribeCatalogChanges();
+
+    const upsertVideo = (event) => {
+      const change = JSON.parse(event.data);
+      setVideos((prev) =>
+        prev.some((video) => video.id === change.video_id)
+          ? prev.map((video) => (video.id === change.video_id ? change.video : video))
+          : [change.video, ...prev]
+      );
+    };
+
+    source.addEventListener('created', upsertVideo);
+    source.addEventListener('updated', upsertVideo);
+    source.addEventListener('processed', upsertVideo);
+    source.addEventListener('deleted', (event) => {
+      const change = JSON.parse(event.data);
+      setVideos((prev) => prev.filter((video) => video.id !== change.video_id));
+    });
+    // Historique insuffisant côté serveur: recharger le catalogue complet
+    source.addEventListener('reset', () => loadVideos(false));
+
     loadVideos();
+
+    return () => source.close();
   }, []);
 
-  const loadVideos = async () => {
+  const loadVideos = async (showLoading = true) => {
     try {
-      setLoading(true);
+      if (showLoading) setLoading(true);
       const data = await api.getVideos();
       setVideos(data);
+      setOffline(false);
       setError(null);
     } catch (err) {
-      setError('Impossible de charger les vidéos');
-      console.error(err);
+      // Serveur injoignable: proposer les vidéos du cache via le catalogue du daemon
+      try {
+        const catalog = await daemon.getCatalog();
+        setVideos(
+          catalog.videos.map((video) => ({
+            ...video,
+            thumbnail: video.local_thumbnail ? `/daemon${video.local_thumbnail}` : video.thumbnail,
+          }))
+        );
+        setOffline(catalog.videos.some((video) => video.offline));
+        setError(null);
+      } catch (daemonErr) {
+        setError('Impossible de charger les vidéos');
+        console.error(err, daemonErr);
+      }
     } finally {
       setLoading(false);
     }
@@ -40,6 +79,15 @@ const Home = () => {
 
       {/* Grille de vidéos */}
       <div className="relative z-10 -mt-20">
+        {offline && (
+          <div className="max-w-7xl mx-auto px-6 pt-6">
+            <div className="bg-yellow-500/10 border border-yellow-500/50 rounded-lg p-4 text-center">
+              <p className="text-yellow-400 font-medium">
+                📴 Hors ligne: seules les vidéos déjà téléchargées sont disponibles
+              </p>
+            </div>
+          </div>
+        )}
         {error ? (
           <div className="max-w-7xl mx-auto px-6 py-12">
             <motion.div
@@ -47,6 +47,11 @@ export const api = {
     return response.data;
   },
 
+  // Flux des changements du catalogue (Server-Sent Events); reprend via Last-Event-ID à la reconnexion
+  subscribeCatalogChanges: () => {
+    return new EventSource('/api/changes/stream');
+  },
+
   // Infos du peer serveur
   getPeerInfo: async () => {
     const response = await backendAPI.get('/peer-info');
@@ -77,17 +82,38 @@ export const daemon = {
     return response.data;
   },
 
+  // Catalogue local du daemon (vidéos du cache marquées offline si le serveur est injoignable)
+  getCatalog: async () => {
+    const response = await daemonAPI.get('/catalog');
+    return response.data;
+  },
+
   // Récupérer les statistiques P2P
   getP2PStats: async () => {
     const response = await daemonAPI.get('/stats');
     return response.data;
   },
 
+  // Signaler la position de lecture (priorise les chunks autour de la tête de lecture)
+  reportPlayback: async (filename, position, duration) => {
+    const response = await daemonAPI.post(`/playback/${filename}`, { position, duration });
+    return response.data;
+  },
+
   // URL de streaming depuis le cache local
   getStreamURL: (filename) => {
     return `/daemon/stream/${filename}`;
   },
 
+  // Flux d'événements temps réel (Server-Sent Events), filtré par vidéo et/ou par types
+  subscribeEvents: ({ video, types } = {}) => {
+    const params = new URLSearchParams();
+    if (video) params.set('video', video);
+    if (types && types.length > 0) params.set('types', types.join(','));
+    const query = params.toString();
+    return new EventSource(`/daemon/events${query ? `?${query}` : ''}`);
+  },
+
   // Health check
   checkHealth: async () => {
     const response = await daemonAPI.get('/health');
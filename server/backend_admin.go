@@ -0,0 +1,790 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// ============================================
+// ADMINISTRATION (pipbingo-server admin)
+// ============================================
+
+// Codes de sortie de pipbingo-server admin
+const (
+	adminExitOK        = 0
+	adminExitError     = 1
+	adminExitUsage     = 2
+	adminExitAnomalies = 3 // verify: fichier absent, tronqué ou altéré
+)
+
+// Contenu d'une sauvegarde (tar.gz): le catalogue d'abord, puis les fichiers vidéo avec -videos
+const (
+	backupCatalogName = "catalog.json"
+	backupVideoDir    = "videos/"
+)
+
+// videoExtensions sont les fichiers pris par admin import (l'upload HTTP, lui, vérifie le type MIME)
+var videoExtensions = map[string]bool{
+	".mp4": true, ".m4v": true, ".mov": true, ".webm": true, ".mkv": true, ".avi": true, ".ogv": true,
+}
+
+// errAnomalies signale que verify a trouvé des fichiers absents, tronqués ou altérés
+var errAnomalies = errors.New("anomalies détectées")
+
+// usageError signale une ligne de commande invalide
+type usageError struct{ msg string }
+
+func (e *usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// adminCommand est une sous-commande de pipbingo-server admin
+type adminCommand struct {
+	name    string
+	args    string // arguments attendus, pour l'aide
+	summary string
+	offline bool // modifie le catalogue: refusée tant que le serveur tourne
+	run     func(a *admin, fs *flag.FlagSet, args []string) error
+}
+
+var adminCommands []adminCommand
+
+func init() {
+	adminCommands = []adminCommand{
+		{"import", "[-creator NOM] <dossier>", "Importer les vidéos d'un dossier, avec leurs métadonnées (film.mp4 + film.json)", true, (*admin).cmdImport},
+		{"reindex", "", "Reconstruire le catalogue depuis le stockage et recalculer les empreintes", true, (*admin).cmdReindex},
+		{"verify", "", "Relire chaque fichier et comparer son empreinte SHA-256 à celle du catalogue", false, (*admin).cmdVerify},
+		{"rm", "<id|fichier>", "Supprimer une vidéo du catalogue et du stockage", true, (*admin).cmdRemove},
+		{"export", "[-videos] <archive.tar.gz>", "Sauvegarder le catalogue (-videos: avec les fichiers)", false, (*admin).cmdExport},
+		{"restore", "[-force] <archive.tar.gz>", "Restaurer une sauvegarde (-force: remplacer un catalogue non vide)", true, (*admin).cmdRestore},
+	}
+}
+
+// admin travaille directement sur le stockage et le catalogue persisté, sans nœud P2P ni API HTTP
+type admin struct {
+	ctx    context.Context
+	cmd    *adminCommand
+	server *Server
+	out    io.Writer
+}
+
+// runAdmin exécute pipbingo-server admin et renvoie le code de sortie
+func runAdmin(args []string) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := adminMain(ctx, args, os.Stdout, os.Stderr)
+	var usage *usageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return adminExitOK
+	case errors.As(err, &usage):
+		fmt.Fprintf(os.Stderr, "pipbingo-server admin: %v\n", err)
+		return adminExitUsage
+	case errors.Is(err, errAnomalies):
+		fmt.Fprintf(os.Stderr, "pipbingo-server admin: %v\n", err)
+		return adminExitAnomalies
+	}
+	fmt.Fprintf(os.Stderr, "pipbingo-server admin: %v\n", err)
+	return adminExitError
+}
+
+func adminMain(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		printAdminUsage(stderr)
+		return usagef("commande manquante")
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "-help" {
+		printAdminUsage(stderr)
+		return nil
+	}
+
+	var cmd *adminCommand
+	for i := range adminCommands {
+		if adminCommands[i].name == args[0] {
+			cmd = &adminCommands[i]
+		}
+	}
+	if cmd == nil {
+		return usagef("commande inconnue %q (pipbingo-server admin help)", args[0])
+	}
+
+	fs := flag.NewFlagSet("pipbingo-server admin "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: pipbingo-server [options] admin %s %s\n\n%s\n", cmd.name, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+	}
+
+	a := &admin{ctx: ctx, cmd: cmd, out: stdout}
+	return cmd.run(a, fs, args[1:])
+}
+
+// parse analyse les options de la commande puis ouvre le stockage et le catalogue
+func (a *admin) parse(fs *flag.FlagSet, args []string, minArgs, maxArgs int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, &usageError{msg: err.Error()}
+	}
+	if fs.NArg() < minArgs || fs.NArg() > maxArgs {
+		fs.Usage()
+		return nil, usagef("nombre d'arguments invalide")
+	}
+
+	if cfg.Storage.Backend == StorageMemory {
+		return nil, fmt.Errorf("stockage %q: rien n'est conservé hors du serveur, rien à administrer", StorageMemory)
+	}
+	// Le serveur garde le catalogue en mémoire et l'écrirait par-dessus nos changements
+	if a.cmd.offline && serverRunning() {
+		return nil, fmt.Errorf("un serveur répond sur %s: l'arrêter avant admin %s", cfg.HTTPAddr, a.cmd.name)
+	}
+
+	a.server = NewServer()
+	if err := a.server.openStorage(); err != nil {
+		return nil, err
+	}
+	a.server.catalogLock.Lock()
+	a.server.readCatalogLocked()
+	a.server.catalogLock.Unlock()
+	return fs.Args(), nil
+}
+
+// serverRunning indique si un serveur répond déjà sur cfg.HTTPAddr
+func serverRunning() bool {
+	host, port, err := net.SplitHostPort(cfg.HTTPAddr)
+	if err != nil {
+		return false
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+
+	client := http.Client{Timeout: time.Second}
+	resp, err := client.Get("http://" + net.JoinHostPort(host, port) + "/health/live")
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
+
+func printAdminUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: pipbingo-server [options] admin <commande> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Maintenance du catalogue et du stockage désignés par la configuration (pipbingo-server -h).")
+	fmt.Fprintln(w, "Les commandes qui modifient le catalogue exigent que le serveur soit arrêté.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commandes:")
+	for _, cmd := range adminCommands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Codes de sortie: 0 succès, 1 erreur, 2 usage, 3 verify: fichiers absents, tronqués ou altérés")
+}
+
+// sortedVideosLocked renvoie les vidéos par ID, soit par date d'ajout (appelé sous catalogLock)
+func (s *Server) sortedVideosLocked() []*Video {
+	videos := make([]*Video, 0, len(s.catalog))
+	for _, video := range s.catalog {
+		videos = append(videos, video)
+	}
+	sort.Slice(videos, func(i, j int) bool { return videos[i].ID < videos[j].ID })
+	return videos
+}
+
+// hashReader renvoie l'empreinte SHA-256 et la taille de ce qui est lu
+func hashReader(r io.Reader) (string, int64, error) {
+	hash := sha256.New()
+	n, err := io.Copy(hash, r)
+	if err != nil {
+		return "", n, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), n, nil
+}
+
+// ============================================
+// IMPORT D'UN DOSSIER
+// ============================================
+
+// videoMetadata est le fichier posé à côté d'une vidéo importée: film.mp4 → film.json
+type videoMetadata struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Creator     string `json:"creator"`
+	Duration    int    `json:"duration"` // en secondes
+}
+
+// readMetadata lit les métadonnées d'une vidéo; sans fichier, le titre est le nom de la vidéo.
+// Les clés inconnues sont refusées pour repérer les fautes de frappe.
+func readMetadata(videoPath, defaultCreator string) (videoMetadata, error) {
+	base := strings.TrimSuffix(videoPath, filepath.Ext(videoPath))
+	meta := videoMetadata{Title: filepath.Base(base), Creator: defaultCreator}
+
+	data, err := os.ReadFile(base + ".json")
+	if os.IsNotExist(err) {
+		return meta, nil
+	}
+	if err != nil {
+		return meta, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&meta); err != nil {
+		return meta, fmt.Errorf("%s.json: %w", filepath.Base(base), err)
+	}
+	if meta.Title == "" {
+		meta.Title = filepath.Base(base)
+	}
+	if meta.Creator == "" {
+		meta.Creator = defaultCreator
+	}
+	return meta, nil
+}
+
+func (a *admin) cmdImport(fs *flag.FlagSet, args []string) error {
+	creator := fs.String("creator", "Anonymous", "créateur des vidéos dont les métadonnées n'en donnent pas")
+	args, err := a.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(args[0])
+	if err != nil {
+		return err
+	}
+
+	// Un fichier déjà importé (même empreinte) n'est pas dupliqué: l'import peut être relancé
+	a.server.catalogLock.RLock()
+	known := make(map[string]*Video, len(a.server.checksums))
+	for _, video := range a.server.catalog {
+		if sum, ok := a.server.checksums[video.Filename]; ok {
+			known[sum] = video
+		}
+	}
+	a.server.catalogLock.RUnlock()
+
+	imported, skipped, failed := 0, 0, 0
+	for _, entry := range entries {
+		if entry.IsDir() || !videoExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			continue
+		}
+		if err := a.ctx.Err(); err != nil {
+			return err
+		}
+
+		video, existing, err := a.importFile(filepath.Join(args[0], entry.Name()), *creator, known)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Fprintf(a.out, "! %s: %v\n", entry.Name(), err)
+		case existing:
+			skipped++
+			fmt.Fprintf(a.out, "= %s: déjà importée (%s)\n", entry.Name(), video.ID)
+		default:
+			imported++
+			fmt.Fprintf(a.out, "+ %s → %s %q\n", entry.Name(), video.ID, video.Title)
+		}
+	}
+
+	fmt.Fprintf(a.out, "%d vidéo(s) importée(s), %d déjà présente(s), %d en échec\n", imported, skipped, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d fichier(s) non importé(s)", failed)
+	}
+	return nil
+}
+
+// importFile copie une vidéo dans le stockage, l'ajoute au catalogue puis calcule son manifest.
+// existing est vrai si une vidéo de même empreinte est déjà au catalogue (renvoyée).
+func (a *admin) importFile(videoPath, creator string, known map[string]*Video) (video *Video, existing bool, err error) {
+	meta, err := readMetadata(videoPath, creator)
+	if err != nil {
+		return nil, false, err
+	}
+
+	file, err := os.Open(videoPath)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+
+	sum, size, err := hashReader(file)
+	if err != nil {
+		return nil, false, err
+	}
+	if video, ok := known[sum]; ok {
+		return video, true, nil
+	}
+	if err := checkFreeSpace(a.server.store, size); err != nil {
+		return nil, false, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, false, err
+	}
+
+	filename := "video_" + generateID() + strings.ToLower(filepath.Ext(videoPath))
+	if _, err := a.server.store.Put(a.ctx, filename, file, size); err != nil {
+		return nil, false, fmt.Errorf("copie dans le stockage: %w", err)
+	}
+
+	video = &Video{
+		ID:          generateID(),
+		Title:       meta.Title,
+		Description: meta.Description,
+		Filename:    filename,
+		Duration:    meta.Duration,
+		Size:        size,
+		Creator:     meta.Creator,
+		UploadedAt:  time.Now(),
+		Thumbnail:   "/thumbnails/default.jpg",
+		Status:      VideoProcessing,
+	}
+	a.server.addVideo(video)
+
+	// Sans manifest, la vidéo reste en processing: le serveur réessaiera au démarrage
+	if err := a.server.processVideo(video.ID, filename); err != nil {
+		return nil, false, fmt.Errorf("importée en %s mais pas traitée: %w", video.ID, err)
+	}
+	a.server.catalogLock.RLock()
+	stored := a.server.checksums[filename]
+	a.server.catalogLock.RUnlock()
+	if stored != sum {
+		return nil, false, fmt.Errorf("copie de %s altérée dans le stockage (admin verify)", filename)
+	}
+
+	known[sum] = video
+	return video, false, nil
+}
+
+// ============================================
+// REINDEX ET VERIFY
+// ============================================
+
+// cmdReindex réconcilie le catalogue avec le stockage comme au démarrage, puis relit chaque
+// fichier pour recalculer son manifest et sa taille. Les fichiers ne changent jamais après
+// l'upload: une empreinte de référence existante est gardée, et un écart signalé.
+func (a *admin) cmdReindex(fs *flag.FlagSet, args []string) error {
+	if _, err := a.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	s := a.server
+	s.catalogLock.Lock()
+	defer s.catalogLock.Unlock()
+
+	added, removed, err := s.reconcileLocked(a.ctx)
+	if err != nil {
+		return fmt.Errorf("impossible de lister le stockage: %w", err)
+	}
+	for _, video := range removed {
+		fmt.Fprintf(a.out, "- %s %s: absente du stockage, retirée\n", video.ID, video.Filename)
+	}
+	for _, video := range added {
+		fmt.Fprintf(a.out, "+ %s %s: ajoutée depuis le stockage\n", video.ID, video.Filename)
+	}
+
+	// Les empreintes de fichiers disparus ne sont pas reprises
+	checksums := make(map[string]string, len(s.catalog))
+	failed := 0
+	for _, video := range s.sortedVideosLocked() {
+		if a.ctx.Err() != nil {
+			break
+		}
+		entry, err := s.manifestEntry(video.Filename)
+		if err != nil {
+			failed++
+			fmt.Fprintf(a.out, "! %s %s: %v\n", video.ID, video.Filename, err)
+			continue
+		}
+		if previous, ok := s.checksums[video.Filename]; ok && previous != entry.sha256 {
+			failed++
+			checksums[video.Filename] = previous
+			fmt.Fprintf(a.out, "! %s %s: contenu altéré, empreinte de référence conservée (admin verify)\n", video.ID, video.Filename)
+			continue
+		}
+		checksums[video.Filename] = entry.sha256
+
+		if video.Size != entry.manifest.Size {
+			fmt.Fprintf(a.out, "~ %s %s: taille %d → %d\n", video.ID, video.Filename, video.Size, entry.manifest.Size)
+			video.Size = entry.manifest.Size
+			s.recordChangeLocked(ChangeUpdated, video.ID, video)
+		}
+		if video.Status != VideoReady {
+			video.Status = VideoReady
+			s.recordChangeLocked(ChangeProcessed, video.ID, video)
+		}
+	}
+	if err := a.ctx.Err(); err != nil {
+		// Interrompu: garder les empreintes déjà connues des fichiers non relus
+		for name, sum := range s.checksums {
+			if _, ok := checksums[name]; !ok {
+				checksums[name] = sum
+			}
+		}
+		s.checksums = checksums
+		s.saveCatalogLocked()
+		return err
+	}
+	s.checksums = checksums
+	if err := s.saveCatalogLocked(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.out, "%d vidéo(s) indexée(s): %d ajoutée(s) depuis le stockage, %d retirée(s), %d en échec\n",
+		len(s.catalog)-failed, len(added), len(removed), failed)
+	if failed > 0 {
+		return fmt.Errorf("%d fichier(s) illisible(s) ou altéré(s)", failed)
+	}
+	return nil
+}
+
+func (a *admin) cmdVerify(fs *flag.FlagSet, args []string) error {
+	if _, err := a.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	s := a.server
+	s.catalogLock.RLock()
+	videos := s.sortedVideosLocked()
+	checksums := make(map[string]string, len(s.checksums))
+	for name, sum := range s.checksums {
+		checksums[name] = sum
+	}
+	s.catalogLock.RUnlock()
+
+	blobs, err := s.store.List(a.ctx, "video_")
+	if err != nil {
+		return fmt.Errorf("impossible de lister le stockage: %w", err)
+	}
+	stored := make(map[string]BlobInfo, len(blobs))
+	for _, blob := range blobs {
+		stored[blob.Name] = blob
+	}
+
+	table := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tFICHIER\tTAILLE\tRÉSULTAT")
+	anomalies, unchecked := 0, 0
+	for _, video := range videos {
+		if err := a.ctx.Err(); err != nil {
+			table.Flush()
+			return err
+		}
+		result, anomaly := a.verifyVideo(video, stored, checksums[video.Filename])
+		if anomaly {
+			anomalies++
+		} else if result != "ok" {
+			unchecked++
+		}
+		fmt.Fprintf(table, "%s\t%s\t%d\t%s\n", video.ID, video.Filename, video.Size, result)
+		delete(stored, video.Filename)
+	}
+
+	// Fichiers du stockage absents du catalogue: le prochain démarrage (ou reindex) les ajoutera
+	orphans := make([]BlobInfo, 0, len(stored))
+	for _, blob := range stored {
+		orphans = append(orphans, blob)
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].Name < orphans[j].Name })
+	for _, blob := range orphans {
+		fmt.Fprintf(table, "-\t%s\t%d\thors catalogue\n", blob.Name, blob.Size)
+	}
+	table.Flush()
+
+	fmt.Fprintf(a.out, "\n%d vidéo(s) vérifiée(s), %d anomalie(s), %d sans empreinte de référence (admin reindex), %d hors catalogue\n",
+		len(videos)-unchecked, anomalies, unchecked, len(orphans))
+	if anomalies > 0 {
+		return fmt.Errorf("%d fichier(s) absent(s), tronqué(s) ou altéré(s): %w", anomalies, errAnomalies)
+	}
+	return nil
+}
+
+// verifyVideo relit le fichier d'une vidéo et le compare au catalogue.
+// anomaly est vrai si le fichier est absent, d'une autre taille, illisible ou altéré.
+func (a *admin) verifyVideo(video *Video, stored map[string]BlobInfo, expected string) (result string, anomaly bool) {
+	blob, ok := stored[video.Filename]
+	if !ok {
+		return "absent du stockage", true
+	}
+	if blob.Size != video.Size {
+		return fmt.Sprintf("taille %d au lieu de %d", blob.Size, video.Size), true
+	}
+	if expected == "" {
+		return "sans empreinte", false
+	}
+
+	file, err := a.server.store.GetRange(a.ctx, video.Filename, 0, -1)
+	if err != nil {
+		return "illisible: " + err.Error(), true
+	}
+	defer file.Close()
+	sum, _, err := hashReader(file)
+	if err != nil {
+		return "illisible: " + err.Error(), true
+	}
+	if sum != expected {
+		return "altéré (SHA-256 différent)", true
+	}
+	return "ok", false
+}
+
+// ============================================
+// SUPPRESSION
+// ============================================
+
+func (a *admin) cmdRemove(fs *flag.FlagSet, args []string) error {
+	args, err := a.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	var video *Video
+	a.server.catalogLock.RLock()
+	for _, v := range a.server.catalog {
+		if v.ID == args[0] || v.Filename == args[0] {
+			video = v
+		}
+	}
+	a.server.catalogLock.RUnlock()
+	if video == nil {
+		return fmt.Errorf("%s: %w", args[0], errVideoNotFound)
+	}
+
+	if err := a.server.deleteVideo(a.ctx, video.ID); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "vidéo supprimée: %s %q (%s)\n", video.ID, video.Title, video.Filename)
+	return nil
+}
+
+// ============================================
+// SAUVEGARDE ET RESTAURATION
+// ============================================
+
+func (a *admin) cmdExport(fs *flag.FlagSet, args []string) error {
+	withVideos := fs.Bool("videos", false, "inclure les fichiers vidéo (sauvegarde complète)")
+	args, err := a.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	a.server.catalogLock.RLock()
+	state := a.server.stateLocked()
+	state.Videos = a.server.sortedVideosLocked()
+	a.server.catalogLock.RUnlock()
+
+	// Écrite à côté puis renommée: une sauvegarde interrompue n'écrase pas la précédente
+	tmp := args[0] + ".tmp"
+	if err := a.writeBackup(tmp, state, *withVideos); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, args[0]); err != nil {
+		return err
+	}
+
+	content := "catalogue seul"
+	if *withVideos {
+		content = "avec les fichiers"
+	}
+	fmt.Fprintf(a.out, "sauvegarde écrite: %s (%d vidéo(s), %s, changement n°%d)\n", args[0], len(state.Videos), content, state.Seq)
+	return nil
+}
+
+// writeBackup écrit l'archive: catalog.json, puis videos/<fichier> pour chaque vidéo
+func (a *admin) writeBackup(name string, state catalogState, withVideos bool) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, backupCatalogName, int64(len(data)), time.Now(), bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	for _, video := range state.Videos {
+		if !withVideos {
+			break
+		}
+		if err := a.ctx.Err(); err != nil {
+			return err
+		}
+		info, err := a.server.store.Stat(a.ctx, video.Filename)
+		if err != nil {
+			return fmt.Errorf("%s: %w", video.Filename, err)
+		}
+		blob, err := a.server.store.GetRange(a.ctx, video.Filename, 0, -1)
+		if err != nil {
+			return fmt.Errorf("%s: %w", video.Filename, err)
+		}
+		// Un fichier altéré ne doit pas finir dans la sauvegarde comme s'il était sain
+		hash := sha256.New()
+		err = writeTarFile(tw, backupVideoDir+video.Filename, info.Size, info.ModTime, io.TeeReader(blob, hash))
+		blob.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", video.Filename, err)
+		}
+		if expected, ok := state.Checksums[video.Filename]; ok && hex.EncodeToString(hash.Sum(nil)) != expected {
+			return fmt.Errorf("%s: contenu altéré, sauvegarde abandonnée (admin verify)", video.Filename)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// writeTarFile ajoute un fichier de size octets à l'archive
+func writeTarFile(tw *tar.Writer, name string, size int64, modTime time.Time, r io.Reader) error {
+	header := &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: size, ModTime: modTime}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.Copy(tw, r)
+	return err
+}
+
+// cmdRestore remplace le catalogue par celui d'une sauvegarde et remet dans le stockage
+// les fichiers qu'elle contient et qui y manquent
+func (a *admin) cmdRestore(fs *flag.FlagSet, args []string) error {
+	force := fs.Bool("force", false, "remplacer le catalogue actuel s'il n'est pas vide")
+	args, err := a.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	s := a.server
+	s.catalogLock.Lock()
+	defer s.catalogLock.Unlock()
+	if len(s.catalog) > 0 && !*force {
+		return fmt.Errorf("le catalogue actuel contient %d vidéo(s) et serait remplacé: relancer avec -force", len(s.catalog))
+	}
+
+	state, restored, err := a.readBackup(args[0])
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+
+	// Les clients synchronisés après la sauvegarde ont un since plus récent qu'elle: repartir
+	// au-delà de tout numéro déjà distribué, sans historique, les force à recharger /list
+	seq := state.Seq
+	if s.seq > seq {
+		seq = s.seq
+	}
+	s.applyStateLocked(state)
+	s.seq = seq + 1
+	s.changes = nil
+
+	added, removed, err := s.reconcileLocked(a.ctx)
+	if err != nil {
+		return fmt.Errorf("impossible de lister le stockage: %w", err)
+	}
+	for _, video := range removed {
+		fmt.Fprintf(a.out, "- %s %s: fichier absent du stockage et de la sauvegarde, retirée\n", video.ID, video.Filename)
+	}
+	for _, video := range added {
+		fmt.Fprintf(a.out, "+ %s %s: présente dans le stockage, ajoutée\n", video.ID, video.Filename)
+	}
+	if err := s.saveCatalogLocked(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.out, "catalogue restauré: %d vidéo(s), %d fichier(s) remis dans le stockage\n", len(s.catalog), restored)
+	return nil
+}
+
+// readBackup lit le catalogue d'une sauvegarde et remet dans le stockage ses fichiers
+// absents ou de taille différente, en vérifiant leur empreinte
+func (a *admin) readBackup(name string) (state catalogState, restored int, err error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return state, 0, err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return state, 0, fmt.Errorf("archive invalide: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	header, err := tr.Next()
+	if err != nil || header.Name != backupCatalogName {
+		return state, 0, fmt.Errorf("archive invalide: %s attendu en premier", backupCatalogName)
+	}
+	if err := json.NewDecoder(tr).Decode(&state); err != nil {
+		return state, 0, fmt.Errorf("%s: %w", backupCatalogName, err)
+	}
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return state, restored, nil
+		}
+		if err != nil {
+			return state, restored, fmt.Errorf("archive invalide: %w", err)
+		}
+		filename := strings.TrimPrefix(header.Name, backupVideoDir)
+		if header.Typeflag != tar.TypeReg || filename == header.Name || filename != path.Base(filename) ||
+			!strings.HasPrefix(filename, "video_") {
+			continue
+		}
+
+		if info, err := a.server.store.Stat(a.ctx, filename); err == nil && info.Size == header.Size {
+			continue
+		}
+		if err := a.restoreFile(tr, filename, header.Size, state.Checksums[filename]); err != nil {
+			return state, restored, fmt.Errorf("%s: %w", filename, err)
+		}
+		restored++
+	}
+}
+
+// restoreFile copie un fichier de l'archive dans le stockage; altéré, il est supprimé
+func (a *admin) restoreFile(r io.Reader, filename string, size int64, expected string) error {
+	if err := checkFreeSpace(a.server.store, size); err != nil {
+		return err
+	}
+	hash := sha256.New()
+	if _, err := a.server.store.Put(a.ctx, filename, io.TeeReader(r, hash), size); err != nil {
+		return err
+	}
+	if expected != "" && hex.EncodeToString(hash.Sum(nil)) != expected {
+		a.server.store.Delete(a.ctx, filename)
+		return errors.New("empreinte SHA-256 différente de celle de la sauvegarde")
+	}
+	return nil
+}
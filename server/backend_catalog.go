@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// CATALOGUE PERSISTÉ ET FLUX DE CHANGEMENTS
+// ============================================
+
+// États des vidéos, types de changements et CatalogChange: voir pipbingo/shared
+const (
+	VideoProcessing = shared.VideoProcessing
+	VideoReady      = shared.VideoReady
+
+	ChangeCreated   = shared.ChangeCreated
+	ChangeUpdated   = shared.ChangeUpdated
+	ChangeDeleted   = shared.ChangeDeleted
+	ChangeProcessed = shared.ChangeProcessed
+)
+
+type CatalogChange = shared.CatalogChange
+
+// catalogState est le catalogue tel que persisté dans Config.CatalogFile
+type catalogState struct {
+	Seq       uint64            `json:"seq"`
+	Videos    []*Video          `json:"videos"`
+	Changes   []CatalogChange   `json:"changes"`
+	Checksums map[string]string `json:"checksums,omitempty"` // SHA-256 par nom de fichier
+}
+
+var errVideoNotFound = errors.New("vidéo introuvable")
+
+// loadCatalog recharge le catalogue persisté puis le réconcilie avec le stockage:
+// les blobs inconnus sont ajoutés, les vidéos dont le blob a disparu sont retirées
+func (s *Server) loadCatalog() {
+	s.catalogLock.Lock()
+	defer s.catalogLock.Unlock()
+
+	s.readCatalogLocked()
+	if _, _, err := s.reconcileLocked(context.Background()); err != nil {
+		logCatalog.Error("impossible de lister le stockage", "err", err)
+		return
+	}
+
+	// Reprendre le traitement des vidéos interrompues
+	for _, video := range s.catalog {
+		if video.Status != VideoReady {
+			s.startProcessing(video.ID, video.Filename)
+		}
+	}
+
+	s.saveCatalogLocked()
+	logCatalog.Info("catalogue chargé", "videos", len(s.catalog), "seq", s.seq)
+}
+
+// readCatalogLocked lit le catalogue persisté; illisible, il est ignoré et sera
+// reconstruit depuis le stockage (appelé sous catalogLock)
+func (s *Server) readCatalogLocked() {
+	data, err := os.ReadFile(cfg.CatalogFile())
+	if err != nil {
+		return
+	}
+	var state catalogState
+	if err := json.Unmarshal(data, &state); err != nil {
+		logCatalog.Warn("catalogue illisible, reconstruit depuis le stockage", "err", err)
+		return
+	}
+	s.applyStateLocked(state)
+}
+
+// applyStateLocked remplace le catalogue en mémoire (appelé sous catalogLock)
+func (s *Server) applyStateLocked(state catalogState) {
+	s.seq = state.Seq
+	s.changes = state.Changes
+	s.catalog = make(map[string]*Video, len(state.Videos))
+	for _, video := range state.Videos {
+		s.catalog[video.ID] = video
+	}
+	s.checksums = make(map[string]string, len(state.Checksums))
+	for name, sum := range state.Checksums {
+		s.checksums[name] = sum
+	}
+}
+
+// reconcileLocked aligne le catalogue sur le stockage: les vidéos dont le blob a disparu
+// sont retirées, les blobs video_* inconnus ajoutés en processing (appelé sous catalogLock)
+func (s *Server) reconcileLocked(ctx context.Context) (added, removed []*Video, err error) {
+	blobs, err := s.store.List(ctx, "video_")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stored := make(map[string]BlobInfo, len(blobs))
+	for _, blob := range blobs {
+		stored[blob.Name] = blob
+	}
+
+	known := make(map[string]bool, len(s.catalog))
+	for id, video := range s.catalog {
+		if _, ok := stored[video.Filename]; !ok {
+			logCatalog.Warn("vidéo absente du stockage, retirée du catalogue", "video_id", id, "file", video.Filename)
+			delete(s.catalog, id)
+			delete(s.checksums, video.Filename)
+			s.recordChangeLocked(ChangeDeleted, id, nil)
+			removed = append(removed, video)
+			continue
+		}
+		known[video.Filename] = true
+	}
+
+	for _, blob := range blobs {
+		if known[blob.Name] {
+			continue
+		}
+		video := &Video{
+			ID:         generateID(),
+			Title:      strings.TrimSuffix(blob.Name, filepath.Ext(blob.Name)),
+			Filename:   blob.Name,
+			Size:       blob.Size,
+			UploadedAt: blob.ModTime,
+			Creator:    "Anonymous",
+			Thumbnail:  "/thumbnails/default.jpg",
+			Status:     VideoProcessing,
+		}
+		s.catalog[video.ID] = video
+		s.recordChangeLocked(ChangeCreated, video.ID, video)
+		added = append(added, video)
+	}
+	return added, removed, nil
+}
+
+// stateLocked renvoie le catalogue tel qu'il est persisté (appelé sous catalogLock)
+func (s *Server) stateLocked() catalogState {
+	state := catalogState{Seq: s.seq, Videos: make([]*Video, 0, len(s.catalog)), Changes: s.changes, Checksums: s.checksums}
+	for _, video := range s.catalog {
+		state.Videos = append(state.Videos, video)
+	}
+	return state
+}
+
+// saveCatalogLocked persiste le catalogue et l'historique des changements (appelé sous catalogLock)
+func (s *Server) saveCatalogLocked() error {
+	err := writeCatalogFile(s.stateLocked())
+	if err != nil {
+		logCatalog.Error("catalogue non sauvegardé", "err", err)
+	}
+	return err
+}
+
+// writeCatalogFile écrit le catalogue dans Config.CatalogFile via un fichier temporaire
+func writeCatalogFile(state catalogState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := cfg.CatalogFile() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, cfg.CatalogFile())
+}
+
+// recordChangeLocked numérote un changement, l'ajoute à l'historique et le pousse
+// aux abonnés (appelé sous catalogLock). L'appelant persiste ensuite le catalogue.
+func (s *Server) recordChangeLocked(changeType, videoID string, video *Video) CatalogChange {
+	s.seq++
+	change := CatalogChange{Seq: s.seq, Type: changeType, VideoID: videoID, At: time.Now()}
+	if video != nil {
+		snapshot := *video
+		change.Video = &snapshot
+	}
+
+	s.changes = append(s.changes, change)
+	if len(s.changes) > cfg.ChangeLogSize {
+		s.changes = s.changes[len(s.changes)-cfg.ChangeLogSize:]
+	}
+
+	for watcher := range s.watchers {
+		select {
+		case watcher <- change:
+		default:
+			// Abonné trop lent: il se reconnectera avec Last-Event-ID et rattrapera l'historique
+			delete(s.watchers, watcher)
+			close(watcher)
+		}
+	}
+	return change
+}
+
+// changesSinceLocked renvoie les changements après since, ou false si l'historique
+// ne remonte plus jusque-là et qu'il faut recharger le catalogue complet (appelé sous catalogLock)
+func (s *Server) changesSinceLocked(since uint64) ([]CatalogChange, bool) {
+	if since > s.seq {
+		return nil, false
+	}
+	if since == s.seq {
+		return []CatalogChange{}, true
+	}
+	if len(s.changes) == 0 || s.changes[0].Seq > since+1 {
+		return nil, false
+	}
+
+	start := int(since + 1 - s.changes[0].Seq)
+	return append([]CatalogChange{}, s.changes[start:]...), true
+}
+
+// addVideo ajoute une vidéo uploadée au catalogue
+func (s *Server) addVideo(video *Video) {
+	s.catalogLock.Lock()
+	defer s.catalogLock.Unlock()
+
+	s.catalog[video.ID] = video
+	s.recordChangeLocked(ChangeCreated, video.ID, video)
+	s.saveCatalogLocked()
+}
+
+// processVideo prépare une vidéo uploadée (manifest, empreinte) puis la marque prête
+func (s *Server) processVideo(id, filename string) error {
+	entry, err := s.manifestEntry(filename)
+	if err != nil {
+		logCatalog.Error("traitement de la vidéo impossible", "video_id", id, "file", filename, "err", err)
+		return err
+	}
+
+	s.catalogLock.Lock()
+	defer s.catalogLock.Unlock()
+
+	video, ok := s.catalog[id]
+	if !ok || video.Status == VideoReady {
+		return nil
+	}
+	s.checksums[filename] = entry.sha256
+	video.Status = VideoReady
+	s.recordChangeLocked(ChangeProcessed, id, video)
+	s.saveCatalogLocked()
+	logCatalog.Info("vidéo prête", "video_id", id, "file", filename)
+	return nil
+}
+
+// deleteVideo retire une vidéo du catalogue et supprime son blob
+func (s *Server) deleteVideo(ctx context.Context, id string) error {
+	s.catalogLock.Lock()
+	defer s.catalogLock.Unlock()
+
+	video, ok := s.catalog[id]
+	if !ok {
+		return errVideoNotFound
+	}
+	if err := s.store.Delete(ctx, video.Filename); err != nil && !errors.Is(err, ErrBlobNotFound) {
+		return fmt.Errorf("suppression de %s: %w", video.Filename, err)
+	}
+
+	s.manifestsLock.Lock()
+	delete(s.manifests, video.Filename)
+	s.manifestsLock.Unlock()
+
+	delete(s.catalog, id)
+	delete(s.checksums, video.Filename)
+	s.recordChangeLocked(ChangeDeleted, id, nil)
+	s.saveCatalogLocked()
+	logCatalog.Info("vidéo supprimée", "video_id", id, "title", video.Title, "file", video.Filename)
+	return nil
+}
+
+// ============================================
+// API HTTP DU CATALOGUE
+// ============================================
+
+// handleGetVideo renvoie une vidéo du catalogue
+func (s *Server) handleGetVideo(w http.ResponseWriter, r *http.Request) {
+	s.catalogLock.RLock()
+	defer s.catalogLock.RUnlock()
+
+	video, ok := s.catalog[mux.Vars(r)["id"]]
+	if !ok {
+		shared.WriteError(w, r, shared.CodeVideoNotFound, shared.Details{"id": mux.Vars(r)["id"]})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(video)
+}
+
+// parseSince lit le numéro de changement de départ (?since=N ou en-tête Last-Event-ID).
+// Sans l'un ni l'autre, renvoie current: le client ne veut que les changements à venir.
+func parseSince(r *http.Request, current uint64) (uint64, error) {
+	value := r.URL.Query().Get("since")
+	if value == "" {
+		value = r.Header.Get("Last-Event-ID")
+	}
+	if value == "" {
+		return current, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// handleChanges renvoie les changements du catalogue après ?since=N.
+// Si l'historique ne remonte plus jusque-là, répond 410: le client recharge /list
+// et reprend depuis le numéro renvoyé.
+func (s *Server) handleChanges(w http.ResponseWriter, r *http.Request) {
+	since, err := parseSince(r, 0)
+	if err != nil {
+		shared.WriteError(w, r, shared.CodeInvalidSince, nil)
+		return
+	}
+
+	s.catalogLock.RLock()
+	changes, ok := s.changesSinceLocked(since)
+	seq := s.seq
+	s.catalogLock.RUnlock()
+
+	if !ok {
+		shared.WriteError(w, r, shared.CodeSinceTooOld, shared.Details{"seq": seq})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shared.ChangesPage{Seq: seq, Changes: changes})
+}
+
+// handleChangesStream pousse les changements du catalogue en Server-Sent Events,
+// en commençant par ceux manqués depuis ?since=N (ou Last-Event-ID à la reconnexion).
+// Un événement "reset" signale que l'historique ne suffit pas et qu'il faut recharger /list.
+func (s *Server) handleChangesStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		shared.WriteError(w, r, shared.CodeStreamingUnsupported, nil)
+		return
+	}
+
+	// S'abonner et lire l'historique sous le même verrou: aucun changement perdu entre les deux
+	if s.isShuttingDown() {
+		shared.WriteError(w, r, shared.CodeShuttingDown, nil)
+		return
+	}
+
+	watcher := make(chan CatalogChange, 64)
+	s.catalogLock.Lock()
+	since, err := parseSince(r, s.seq)
+	if err != nil {
+		s.catalogLock.Unlock()
+		shared.WriteError(w, r, shared.CodeInvalidSince, nil)
+		return
+	}
+	backlog, ok := s.changesSinceLocked(since)
+	seq := s.seq
+	s.watchers[watcher] = true
+	s.catalogLock.Unlock()
+
+	defer func() {
+		s.catalogLock.Lock()
+		if s.watchers[watcher] {
+			delete(s.watchers, watcher)
+			close(watcher)
+		}
+		s.catalogLock.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if !ok {
+		fmt.Fprintf(w, "id: %d\nevent: reset\ndata: {\"seq\":%d}\n\n", seq, seq)
+	}
+	for _, change := range backlog {
+		if err := writeChange(w, change); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(EventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case change, ok := <-watcher:
+			if !ok {
+				return
+			}
+			if err := writeChange(w, change); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeChange écrit un changement au format SSE; l'id permet la reprise via Last-Event-ID
+func writeChange(w http.ResponseWriter, change CatalogChange) error {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", change.Seq, change.Type, data)
+	return err
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// CONFIGURATION
+// ============================================
+
+// DefaultConfigFile est lu s'il existe et qu'aucun fichier n'est indiqué par -config ou PIPBINGO_CONFIG
+const DefaultConfigFile = "pipbingo-server.yaml"
+
+// Config regroupe les réglages du serveur. Ils sont appliqués par couches:
+// valeurs par défaut, fichier YAML, variables d'environnement PIPBINGO_*, puis flags.
+type Config struct {
+	HTTPAddr        string        `yaml:"http_addr" json:"http_addr"`               // Adresse de l'API HTTP
+	P2PPort         int           `yaml:"p2p_port" json:"p2p_port"`                 // Port TCP du nœud libp2p
+	UploadDir       string        `yaml:"upload_dir" json:"upload_dir"`             // Dossier du stockage local
+	ThumbnailDir    string        `yaml:"thumbnail_dir" json:"thumbnail_dir"`       // Dossier des miniatures
+	DataDir         string        `yaml:"data_dir" json:"data_dir"`                 // Catalogue persisté
+	MaxFileSize     int64         `yaml:"max_file_size" json:"max_file_size"`       // Taille max d'un upload en octets
+	ChunkSize       int           `yaml:"chunk_size" json:"chunk_size"`             // Taille des chunks P2P en octets
+	MinFreeSpace    int64         `yaml:"min_free_space" json:"min_free_space"`     // Espace disque gardé libre après un upload
+	ChangeLogSize   int           `yaml:"change_log_size" json:"change_log_size"`   // Changements du catalogue gardés pour /changes
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" json:"shutdown_timeout"` // Délai laissé aux transferts en cours à l'arrêt
+	Storage         StorageConfig `yaml:"storage" json:"storage"`
+	Log             LogConfig     `yaml:"log" json:"log"`
+}
+
+// StorageConfig choisit le backend de stockage des vidéos
+type StorageConfig struct {
+	Backend string   `yaml:"backend" json:"backend"` // local, s3 ou memory
+	S3      S3Config `yaml:"s3" json:"s3"`
+}
+
+// S3Config décrit un bucket compatible S3
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint" json:"endpoint"`
+	Bucket    string `yaml:"bucket" json:"bucket"`
+	Region    string `yaml:"region" json:"region"`
+	AccessKey string `yaml:"access_key" json:"access_key"`
+	SecretKey string `yaml:"secret_key" json:"secret_key"`
+}
+
+// cfg est la configuration effective, chargée au démarrage par loadConfig
+var cfg = defaultConfig()
+
+func defaultConfig() *Config {
+	return &Config{
+		HTTPAddr:        ":8080",
+		P2PPort:         10000,
+		UploadDir:       "./uploads",
+		ThumbnailDir:    "./thumbnails",
+		DataDir:         "./data",
+		MaxFileSize:     300 * 1024 * 1024, // 300 Mo
+		ChunkSize:       256 * 1024,        // 256 Ko par chunk
+		MinFreeSpace:    1 << 30,           // 1 Go
+		ChangeLogSize:   1000,
+		ShutdownTimeout: 30 * time.Second,
+		Storage: StorageConfig{
+			Backend: StorageLocal,
+			S3:      S3Config{Region: "us-east-1"},
+		},
+		Log: LogConfig{Format: "text", Level: "info"},
+	}
+}
+
+// CatalogFile renvoie le fichier du catalogue et de l'historique des changements
+func (c *Config) CatalogFile() string {
+	return c.DataDir + "/catalog.json"
+}
+
+// setting relie un réglage à sa variable d'environnement et à son flag
+type setting struct {
+	env   string      // variable d'environnement
+	flag  string      // nom du flag
+	value interface{} // pointeur vers le champ de Config
+	usage string
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"PIPBINGO_HTTP_ADDR", "http-addr", &c.HTTPAddr, "adresse de l'API HTTP"},
+		{"PIPBINGO_P2P_PORT", "p2p-port", &c.P2PPort, "port TCP du nœud libp2p"},
+		{"PIPBINGO_UPLOAD_DIR", "upload-dir", &c.UploadDir, "dossier du stockage local"},
+		{"PIPBINGO_THUMBNAIL_DIR", "thumbnail-dir", &c.ThumbnailDir, "dossier des miniatures"},
+		{"PIPBINGO_DATA_DIR", "data-dir", &c.DataDir, "dossier du catalogue persisté"},
+		{"PIPBINGO_MAX_FILE_SIZE", "max-file-size", &c.MaxFileSize, "taille max d'un upload en octets"},
+		{"PIPBINGO_CHUNK_SIZE", "chunk-size", &c.ChunkSize, "taille des chunks P2P en octets"},
+		{"PIPBINGO_MIN_FREE_SPACE", "min-free-space", &c.MinFreeSpace, "espace disque gardé libre après un upload"},
+		{"PIPBINGO_CHANGE_LOG_SIZE", "change-log-size", &c.ChangeLogSize, "changements du catalogue gardés pour /changes"},
+		{"PIPBINGO_SHUTDOWN_TIMEOUT", "shutdown-timeout", &c.ShutdownTimeout, "délai laissé aux transferts en cours à l'arrêt"},
+		{"PIPBINGO_STORAGE", "storage", &c.Storage.Backend, "backend de stockage: local, s3 ou memory"},
+		{"PIPBINGO_S3_ENDPOINT", "s3-endpoint", &c.Storage.S3.Endpoint, "endpoint S3 (http://localhost:9000)"},
+		{"PIPBINGO_S3_BUCKET", "s3-bucket", &c.Storage.S3.Bucket, "bucket S3"},
+		{"PIPBINGO_S3_REGION", "s3-region", &c.Storage.S3.Region, "région S3"},
+		{"PIPBINGO_S3_ACCESS_KEY", "s3-access-key", &c.Storage.S3.AccessKey, "clé d'accès S3"},
+		{"PIPBINGO_S3_SECRET_KEY", "s3-secret-key", &c.Storage.S3.SecretKey, "clé secrète S3 (préférer l'environnement)"},
+		{"PIPBINGO_LOG_FORMAT", "log-format", &c.Log.Format, "format des logs: text ou json"},
+		{"PIPBINGO_LOG_LEVEL", "log-level", &c.Log.Level, "niveau des logs: debug, info, warn ou error"},
+		{"PIPBINGO_LOG_LEVELS", "log-levels", &c.Log.Levels, "niveaux par sous-système (p2p=debug,http=warn)"},
+	}
+}
+
+// loadConfig construit la configuration effective à partir des arguments de la ligne de commande.
+// Les arguments qui suivent les flags (commande admin...) sont renvoyés tels quels.
+func loadConfig(args []string) (*Config, []string, error) {
+	c := defaultConfig()
+	settings := c.settings()
+
+	fs := flag.NewFlagSet("pipbingo-server", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("PIPBINGO_CONFIG"), "fichier de configuration YAML")
+	raw := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		raw[s.flag] = fs.String(s.flag, shared.FormatValue(s.value), s.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	// 1. Fichier YAML (facultatif s'il n'a pas été demandé explicitement)
+	path, explicit := *configPath, *configPath != ""
+	if !explicit {
+		path = DefaultConfigFile
+	}
+	if err := c.loadFile(path, explicit); err != nil {
+		return nil, nil, err
+	}
+
+	// 2. Variables d'environnement
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok {
+			if err := shared.SetValue(s.value, value); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+
+	// 3. Flags passés explicitement
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && flagErr == nil {
+				if err := shared.SetValue(s.value, *raw[s.flag]); err != nil {
+					flagErr = fmt.Errorf("-%s: %w", s.flag, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, nil, flagErr
+	}
+
+	return c, fs.Args(), c.validate()
+}
+
+// loadFile applique un fichier YAML; les clés inconnues sont refusées pour repérer les fautes de frappe
+func (c *Config) loadFile(path string, required bool) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("configuration: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("configuration %s: %w", path, err)
+	}
+	return nil
+}
+
+// validate vérifie la cohérence des réglages
+func (c *Config) validate() error {
+	var errs []error
+	if _, _, err := net.SplitHostPort(c.HTTPAddr); err != nil {
+		errs = append(errs, fmt.Errorf("http_addr invalide %q: %w", c.HTTPAddr, err))
+	}
+	if c.P2PPort < 1 || c.P2PPort > 65535 {
+		errs = append(errs, fmt.Errorf("p2p_port invalide: %d", c.P2PPort))
+	}
+	for name, dir := range map[string]string{"upload_dir": c.UploadDir, "thumbnail_dir": c.ThumbnailDir, "data_dir": c.DataDir} {
+		if dir == "" {
+			errs = append(errs, fmt.Errorf("%s vide", name))
+		}
+	}
+	if c.MaxFileSize <= 0 {
+		errs = append(errs, fmt.Errorf("max_file_size doit être positive"))
+	}
+	if c.ChunkSize < 16*1024 || c.ChunkSize > 4*1024*1024 {
+		errs = append(errs, fmt.Errorf("chunk_size doit être entre 16 Ko et 4 Mo: %d", c.ChunkSize))
+	}
+	if c.MinFreeSpace < 0 {
+		errs = append(errs, fmt.Errorf("min_free_space négatif"))
+	}
+	if c.ChangeLogSize < 1 {
+		errs = append(errs, fmt.Errorf("change_log_size doit être positif"))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdown_timeout doit être positif"))
+	}
+
+	switch c.Storage.Backend {
+	case StorageLocal, StorageMemory:
+	case StorageS3:
+		if c.Storage.S3.Endpoint == "" || c.Storage.S3.Bucket == "" {
+			errs = append(errs, fmt.Errorf("storage.s3: endpoint et bucket requis"))
+		} else if u, err := url.Parse(c.Storage.S3.Endpoint); err != nil || u.Host == "" {
+			errs = append(errs, fmt.Errorf("storage.s3.endpoint invalide: %q", c.Storage.S3.Endpoint))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage.backend inconnu: %q", c.Storage.Backend))
+	}
+
+	errs = append(errs, shared.ValidateLogConfig(c.Log, logSubsystems)...)
+
+	return errors.Join(errs...)
+}
+
+// redacted renvoie une copie de la configuration sans les secrets
+func (c *Config) redacted() Config {
+	safe := *c
+	if safe.Storage.S3.SecretKey != "" {
+		safe.Storage.S3.SecretKey = "********"
+	}
+	if key := safe.Storage.S3.AccessKey; len(key) > 4 {
+		safe.Storage.S3.AccessKey = key[:4] + "********"
+	}
+	return safe
+}
+
+// handleGetConfig renvoie la configuration effective, secrets masqués
+func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cfg.redacted())
+}
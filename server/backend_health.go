@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// SANTÉ
+// ============================================
+
+// États d'un composant, du meilleur au pire (pipbingo/shared)
+const (
+	HealthOK       = shared.HealthOK
+	HealthDegraded = shared.HealthDegraded // fonctionne, mais avec une limite (uploads refusés...)
+	HealthFail     = shared.HealthFail
+)
+
+// Résultat d'un composant et réponse de /health/live et /health/ready
+type (
+	ComponentHealth = shared.ComponentHealth
+	HealthReport    = shared.HealthReport
+)
+
+// handleLive répond tant que le processus sert des requêtes HTTP
+func (s *Server) handleLive(w http.ResponseWriter, r *http.Request) {
+	shared.WriteHealth(w, HealthReport{Status: HealthOK, CheckedAt: time.Now()})
+}
+
+// handleReady vérifie que le serveur peut accepter des uploads et servir des chunks
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	report := shared.RunHealthChecks(r.Context(), map[string]shared.HealthCheck{
+		"storage": s.checkStorageWritable,
+		"disk":    s.checkFreeDisk,
+		"p2p":     s.checkP2PHost,
+		"catalog": s.checkCatalogStore,
+	})
+	if report.Status != HealthOK {
+		requestLogger(r).Info("serveur pas prêt", "status", report.Status)
+	}
+	shared.WriteHealth(w, report)
+}
+
+// checkStorageWritable écrit puis supprime un petit blob témoin
+func (s *Server) checkStorageWritable(ctx context.Context) (string, string, error) {
+	name := ".health-" + shared.NewCorrelationID()
+	if _, err := s.store.Put(ctx, name, strings.NewReader("ok"), 2); err != nil {
+		return "", "", fmt.Errorf("écriture impossible: %w", err)
+	}
+	if err := s.store.Delete(ctx, name); err != nil {
+		return "", "", fmt.Errorf("suppression impossible: %w", err)
+	}
+	return HealthOK, cfg.Storage.Backend, nil
+}
+
+// checkFreeDisk compare l'espace libre du stockage à min_free_space
+func (s *Server) checkFreeDisk(ctx context.Context) (string, string, error) {
+	reporter, ok := s.store.(SpaceReporter)
+	if !ok {
+		return HealthOK, "espace non limité par ce stockage", nil
+	}
+
+	free, err := reporter.FreeSpace()
+	if err != nil {
+		return "", "", fmt.Errorf("espace disque inconnu: %w", err)
+	}
+	message := fmt.Sprintf("%d Mo libres, %d Mo de réserve", free>>20, cfg.MinFreeSpace>>20)
+	if free < cfg.MinFreeSpace {
+		return HealthDegraded, message, nil
+	}
+	return HealthOK, message, nil
+}
+
+// checkP2PHost vérifie que le nœud libp2p écoute et accepte des streams
+func (s *Server) checkP2PHost(ctx context.Context) (string, string, error) {
+	if s.p2pHost == nil {
+		return "", "", errors.New("nœud P2P non démarré")
+	}
+	if s.isShuttingDown() {
+		return "", "", errors.New("arrêt en cours")
+	}
+	addrs := s.p2pHost.Addrs()
+	if len(addrs) == 0 {
+		return "", "", errors.New("aucune adresse d'écoute")
+	}
+	return HealthOK, fmt.Sprintf("%d adresses, %d peers", len(addrs), len(s.p2pHost.Network().Peers())), nil
+}
+
+// checkCatalogStore vérifie que le catalogue est chargé et que son dossier accepte l'écriture
+func (s *Server) checkCatalogStore(ctx context.Context) (string, string, error) {
+	s.catalogLock.RLock()
+	videos, seq := len(s.catalog), s.seq
+	s.catalogLock.RUnlock()
+
+	probe, err := os.CreateTemp(cfg.DataDir, ".health-*")
+	if err != nil {
+		return "", "", fmt.Errorf("catalogue non persistable: %w", err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	return HealthOK, fmt.Sprintf("%d vidéos, changement n°%d", videos, seq), nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// JOURNALISATION
+// ============================================
+
+// LogConfig règle le format et les niveaux des logs (pipbingo/shared)
+type LogConfig = shared.LogConfig
+
+// Un logger par sous-système, pour régler la verbosité de chacun séparément
+var (
+	logMain    *slog.Logger // démarrage, arrêt
+	logHTTP    *slog.Logger // requêtes de l'API
+	logP2P     *slog.Logger // streams libp2p, chunks, tracker
+	logCatalog *slog.Logger // catalogue et traitement des vidéos
+	logStorage *slog.Logger // stockage des blobs
+)
+
+// logSubsystems liste les sous-systèmes acceptés dans log.levels
+var logSubsystems = []string{"main", "http", "p2p", "catalog", "storage"}
+
+func init() {
+	setupLogging(defaultConfig().Log)
+}
+
+// setupLogging crée les loggers des sous-systèmes (configuration déjà validée).
+// Le package log standard est redirigé vers logMain.
+func setupLogging(conf LogConfig) {
+	logger := shared.SubsystemLoggers(conf)
+	logMain = logger("main")
+	logHTTP = logger("http")
+	logP2P = logger("p2p")
+	logCatalog = logger("catalog")
+	logStorage = logger("storage")
+	slog.SetDefault(logMain)
+}
+
+// fatal journalise une erreur bloquante et arrête le processus
+func fatal(msg string, args ...any) {
+	logMain.Error(msg, args...)
+	os.Exit(1)
+}
+
+// ============================================
+// CORRÉLATION DES REQUÊTES HTTP
+// ============================================
+
+// requestLogger renvoie le logger de la requête, porteur de son request_id
+func requestLogger(r *http.Request) *slog.Logger {
+	return shared.RequestLogger(r, logHTTP)
+}
@@ -3,72 +3,51 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
-	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/multiformats/go-multiaddr"
 	"github.com/rs/cors"
+
+	"pipbingo/shared"
 )
 
 // ============================================
 // MODÈLES DE DONNÉES
 // ============================================
 
-// Video représente une vidéo dans le catalogue
-type Video struct {
-	ID          string    `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Filename    string    `json:"filename"`
-	Thumbnail   string    `json:"thumbnail"`
-	Duration    int       `json:"duration"`    // en secondes
-	Size        int64     `json:"size"`        // en octets
-	Creator     string    `json:"creator"`
-	UploadedAt  time.Time `json:"uploaded_at"`
-}
-
-// P2PRequest représente une demande de fichier P2P
-type P2PRequest struct {
-	Action     string `json:"action"`
-	Filename   string `json:"filename"`
-	ChunkIndex int    `json:"chunk_index"`
-}
-
-// P2PResponse représente la réponse P2P
-type P2PResponse struct {
-	Status      string `json:"status"`
-	ChunkData   []byte `json:"chunk_data,omitempty"`
-	ChunkIndex  int    `json:"chunk_index"`
-	TotalChunks int    `json:"total_chunks"`
-	Error       string `json:"error,omitempty"`
-}
+// Les types échangés avec les clients (catalogue, protocole P2P) sont définis
+// dans le module partagé pipbingo/shared, utilisé aussi par le daemon
+type (
+	Video       = shared.Video
+	P2PRequest  = shared.P2PRequest
+	P2PResponse = shared.P2PResponse
+)
 
 // ============================================
 // CONFIGURATION GLOBALE
 // ============================================
 
+// Les réglages modifiables (ports, dossiers, limites...) sont dans Config (backend_config.go)
 const (
-	HTTPPort           = ":8080"
-	P2PPort            = 10000
-	UploadDir          = "./uploads"
-	ThumbnailDir       = "./thumbnails"
-	MaxFileSize        = 300 * 1024 * 1024 // 300 Mo
-	MaxVideoDuration   = 10 * 60           // 10 minutes
-	P2PProtocolID      = "/pipbingo/get/1.0.0"
-	ChunkSize          = 256 * 1024 // 256 Ko par chunk
+	MaxVideoDuration = 10 * 60 // 10 minutes
+	P2PProtocolID    = shared.ProtocolID
+	EventKeepAlive   = 15 * time.Second // Commentaire SSE envoyé aux clients inactifs
 )
 
 // ============================================
@@ -76,14 +55,29 @@ const (
 // ============================================
 
 type Server struct {
-	catalog     map[string]*Video
-	catalogLock sync.RWMutex
-	p2pHost     host.Host
+	catalog       map[string]*Video
+	seq           uint64                      // Numéro du dernier changement du catalogue
+	changes       []CatalogChange             // Derniers changements, pour /changes
+	watchers      map[chan CatalogChange]bool // Abonnés au flux de changements
+	checksums     map[string]string           // SHA-256 de chaque fichier (par nom), pour admin verify
+	catalogLock   sync.RWMutex
+	p2pHost       host.Host
+	manifests     map[string]*manifestEntry
+	manifestsLock sync.Mutex
+	tracker       *Tracker
+	store         BlobStore
+	transfers     sync.WaitGroup // Streams P2P et traitements en cours, attendus à l'arrêt
+	shuttingDown  bool
+	transfersLock sync.Mutex
 }
 
 func NewServer() *Server {
 	return &Server{
-		catalog: make(map[string]*Video),
+		catalog:   make(map[string]*Video),
+		watchers:  make(map[chan CatalogChange]bool),
+		checksums: make(map[string]string),
+		manifests: make(map[string]*manifestEntry),
+		tracker:   NewTracker(),
 	}
 }
 
@@ -92,12 +86,8 @@ func NewServer() *Server {
 // ============================================
 
 func (s *Server) Initialize() error {
-	// Créer les dossiers nécessaires
-	dirs := []string{UploadDir, ThumbnailDir}
-	for _, dir := range dirs {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("impossible de créer %s: %w", dir, err)
-		}
+	if err := s.openStorage(); err != nil {
+		return err
 	}
 
 	// Initialiser le nœud P2P
@@ -108,16 +98,35 @@ func (s *Server) Initialize() error {
 	// Charger le catalogue existant
 	s.loadCatalog()
 
-	log.Println("✅ Serveur initialisé avec succès")
+	// Exposer l'état du nœud et du catalogue sur /metrics
+	s.registerServerMetrics()
+
+	logMain.Info("serveur initialisé")
+	return nil
+}
+
+// openStorage crée les dossiers nécessaires et ouvre le stockage des vidéos (disque local, S3...)
+func (s *Server) openStorage() error {
+	dirs := []string{cfg.ThumbnailDir, cfg.DataDir}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("impossible de créer %s: %w", dir, err)
+		}
+	}
+
+	store, err := newBlobStore(cfg.Storage)
+	if err != nil {
+		return fmt.Errorf("erreur stockage: %w", err)
+	}
+	s.store = store
+	logStorage.Info("stockage ouvert", "backend", cfg.Storage.Backend)
 	return nil
 }
 
 // initP2PNode démarre le nœud libp2p
 func (s *Server) initP2PNode() error {
-	ctx := context.Background()
-
 	// Configuration du nœud
-	listenAddr := fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", P2PPort)
+	listenAddr := fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", cfg.P2PPort)
 	addr, err := multiaddr.NewMultiaddr(listenAddr)
 	if err != nil {
 		return err
@@ -127,6 +136,7 @@ func (s *Server) initP2PNode() error {
 	h, err := libp2p.New(
 		libp2p.ListenAddrs(addr),
 		libp2p.EnableRelay(), // Permet le relaying pour traverser les NAT
+		libp2p.BandwidthReporter(bandwidthCounter),
 	)
 	if err != nil {
 		return err
@@ -137,78 +147,94 @@ func (s *Server) initP2PNode() error {
 	// Configurer le protocole custom
 	h.SetStreamHandler(protocol.ID(P2PProtocolID), s.handleP2PStream)
 
-	log.Printf("🌐 Nœud P2P démarré")
-	log.Printf("   ID: %s", h.ID())
-	log.Printf("   Addrs: %v", h.Addrs())
+	logP2P.Info("nœud P2P démarré", "peer_id", h.ID(), "addrs", h.Addrs())
 
 	return nil
 }
 
 // handleP2PStream gère les demandes de fichiers P2P
 func (s *Server) handleP2PStream(stream network.Stream) {
+	if !s.beginTransfer() {
+		stream.Reset()
+		return
+	}
+	defer s.endTransfer()
 	defer stream.Close()
 
+	metrics.p2p.ActiveStreams.Inc()
+	defer metrics.p2p.ActiveStreams.Dec()
+	call := &shared.P2PCall{
+		Stream: stream,
+		Log:    logP2P.With("stream_id", shared.NewCorrelationID(), "peer", stream.Conn().RemotePeer().ShortString()),
+	}
+	start := time.Now()
+
 	// Lire la requête
 	decoder := json.NewDecoder(stream)
 	var req P2PRequest
 	if err := decoder.Decode(&req); err != nil {
-		log.Printf("❌ Erreur décodage requête P2P: %v", err)
-		s.sendP2PError(stream, "invalid_request")
+		call.Log.Warn("requête P2P illisible", "err", err)
+		s.sendP2PError(call, shared.CodeInvalidRequest, shared.Details{"reason": err.Error()})
+		metrics.p2p.Observe("", call, start)
 		return
 	}
+	defer metrics.p2p.Observe(req.Action, call, start)
 
-	log.Printf("📥 Requête P2P reçue: %s (chunk %d)", req.Filename, req.ChunkIndex)
+	call.Log = call.Log.With("action", req.Action, "file", req.Filename)
+	call.Log.Debug("requête P2P reçue", "chunk", req.ChunkIndex)
 
 	// Traiter selon l'action
 	switch req.Action {
-	case "request_file":
-		s.handleFileRequest(stream, req)
+	case shared.ActionRequestFile:
+		s.handleFileRequest(call, req)
+	case shared.ActionGetManifest:
+		s.handleManifestRequest(call, req)
+	case shared.ActionAnnounce:
+		s.handleAnnounceRequest(call, req)
 	default:
-		s.sendP2PError(stream, "unknown_action")
+		s.sendP2PError(call, shared.CodeUnknownAction, shared.Details{"action": req.Action})
 	}
 }
 
 // handleFileRequest envoie un chunk de fichier
 func (s *Server) handleFileRequest(stream network.Stream, req P2PRequest) {
-	filePath := filepath.Join(UploadDir, filepath.Base(req.Filename))
+	filename := filepath.Base(req.Filename)
+	ctx := context.Background()
 
 	// Vérifier l'existence du fichier
-	fileInfo, err := os.Stat(filePath)
+	fileInfo, err := s.store.Stat(ctx, filename)
 	if err != nil {
-		log.Printf("❌ Fichier introuvable: %s", req.Filename)
-		s.sendP2PError(stream, "file_not_found")
+		streamLogger(stream).Info("fichier introuvable")
+		s.sendP2PError(stream, shared.CodeFileNotFound, shared.Details{"filename": filename})
 		return
 	}
 
 	// Calculer le nombre total de chunks
-	totalChunks := int(fileInfo.Size()/ChunkSize) + 1
-
-	// Ouvrir le fichier
-	file, err := os.Open(filePath)
-	if err != nil {
-		s.sendP2PError(stream, "read_error")
+	totalChunks := chunkCount(fileInfo.Size)
+	if req.ChunkIndex < 0 || req.ChunkIndex >= totalChunks {
+		s.sendP2PError(stream, shared.CodeInvalidChunk, shared.Details{"chunk_index": req.ChunkIndex, "total_chunks": totalChunks})
 		return
 	}
-	defer file.Close()
 
-	// Se positionner au bon chunk
-	offset := int64(req.ChunkIndex) * ChunkSize
-	if _, err := file.Seek(offset, 0); err != nil {
-		s.sendP2PError(stream, "seek_error")
+	// Lire uniquement la plage du chunk demandé
+	offset := int64(req.ChunkIndex) * int64(cfg.ChunkSize)
+	chunk, err := s.store.GetRange(ctx, filename, offset, int64(cfg.ChunkSize))
+	if err != nil {
+		s.sendP2PError(stream, shared.CodeReadError, nil)
 		return
 	}
+	defer chunk.Close()
 
-	// Lire le chunk
-	chunkData := make([]byte, ChunkSize)
-	n, err := file.Read(chunkData)
-	if err != nil && err != io.EOF {
-		s.sendP2PError(stream, "read_error")
+	chunkData := make([]byte, cfg.ChunkSize)
+	n, err := io.ReadFull(chunk, chunkData)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		s.sendP2PError(stream, shared.CodeReadError, nil)
 		return
 	}
 
 	// Préparer la réponse
 	response := P2PResponse{
-		Status:      "success",
+		Status:      shared.StatusSuccess,
 		ChunkData:   chunkData[:n],
 		ChunkIndex:  req.ChunkIndex,
 		TotalChunks: totalChunks,
@@ -217,19 +243,18 @@ func (s *Server) handleFileRequest(stream network.Stream, req P2PRequest) {
 	// Envoyer la réponse
 	encoder := json.NewEncoder(stream)
 	if err := encoder.Encode(response); err != nil {
-		log.Printf("❌ Erreur envoi réponse: %v", err)
+		streamLogger(stream).Warn("envoi du chunk échoué", "chunk", req.ChunkIndex, "err", err)
+		shared.SetP2PResult(stream, "send_error")
+		return
 	}
 
-	log.Printf("✅ Chunk %d/%d envoyé pour %s", req.ChunkIndex+1, totalChunks, req.Filename)
+	streamLogger(stream).Debug("chunk envoyé", "chunk", req.ChunkIndex, "total_chunks", totalChunks, "bytes", n)
 }
 
-// sendP2PError envoie une erreur P2P
-func (s *Server) sendP2PError(stream network.Stream, errMsg string) {
-	response := P2PResponse{
-		Status: "error",
-		Error:  errMsg,
-	}
-	json.NewEncoder(stream).Encode(response)
+// sendP2PError envoie une erreur P2P: code, message et détails (voir shared.P2PErrorResponse)
+func (s *Server) sendP2PError(stream network.Stream, code string, details shared.Details) {
+	shared.SetP2PResult(stream, code)
+	json.NewEncoder(stream).Encode(shared.P2PErrorResponse(code, details))
 }
 
 // ============================================
@@ -238,43 +263,53 @@ func (s *Server) sendP2PError(stream network.Stream, errMsg string) {
 
 // handleUpload gère l'upload de vidéos
 func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
-	// Parser le multipart form (limite 300 Mo)
-	if err := r.ParseMultipartForm(MaxFileSize); err != nil {
-		http.Error(w, "Fichier trop volumineux (max 300 Mo)", http.StatusBadRequest)
+	// Refuser d'emblée un upload que le stockage ne peut pas accueillir
+	expected := r.ContentLength
+	if expected <= 0 {
+		expected = cfg.MaxFileSize
+	}
+	if err := checkFreeSpace(s.store, expected); err != nil {
+		requestLogger(r).Warn("upload refusé", "err", err)
+		shared.WriteError(w, r, shared.CodeInsufficientStorage, shared.Details{"reason": err.Error()})
+		return
+	}
+
+	// Parser le multipart form (limite max_file_size)
+	if err := r.ParseMultipartForm(cfg.MaxFileSize); err != nil {
+		shared.WriteError(w, r, shared.CodeFileTooLarge, shared.Details{"max_mb": cfg.MaxFileSize >> 20})
 		return
 	}
 
 	// Récupérer le fichier
 	file, header, err := r.FormFile("video")
 	if err != nil {
-		http.Error(w, "Aucun fichier fourni", http.StatusBadRequest)
+		shared.WriteError(w, r, shared.CodeNoFile, nil)
 		return
 	}
 	defer file.Close()
 
 	// Valider le type MIME
 	if !strings.HasPrefix(header.Header.Get("Content-Type"), "video/") {
-		http.Error(w, "Le fichier doit être une vidéo", http.StatusBadRequest)
+		shared.WriteError(w, r, shared.CodeNotAVideo, shared.Details{"content_type": header.Header.Get("Content-Type")})
 		return
 	}
 
 	// Générer un nom de fichier sécurisé
 	filename := fmt.Sprintf("video_%d%s", time.Now().Unix(), filepath.Ext(header.Filename))
-	destPath := filepath.Join(UploadDir, filename)
 
-	// Sauvegarder le fichier
-	dest, err := os.Create(destPath)
-	if err != nil {
-		http.Error(w, "Erreur de sauvegarde", http.StatusInternalServerError)
+	// Sauvegarder le fichier dans le stockage
+	size, err := s.store.Put(r.Context(), filename, file, header.Size)
+	if errors.Is(err, syscall.ENOSPC) {
+		requestLogger(r).Error("disque plein pendant l'upload", "file", filename)
+		shared.WriteError(w, r, shared.CodeInsufficientStorage, nil)
 		return
 	}
-	defer dest.Close()
-
-	size, err := io.Copy(dest, file)
 	if err != nil {
-		http.Error(w, "Erreur de copie", http.StatusInternalServerError)
+		requestLogger(r).Error("sauvegarde de l'upload échouée", "file", filename, "err", err)
+		shared.WriteError(w, r, shared.CodeStorageError, nil)
 		return
 	}
+	metrics.uploadBytes.Add(float64(size))
 
 	// Créer l'entrée vidéo
 	video := &Video{
@@ -286,21 +321,24 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		Creator:     r.FormValue("creator"),
 		UploadedAt:  time.Now(),
 		Thumbnail:   "/thumbnails/default.jpg", // À implémenter: génération miniature
+		Status:      VideoProcessing,
 	}
 
 	// Ajouter au catalogue
-	s.catalogLock.Lock()
-	s.catalog[video.ID] = video
-	s.catalogLock.Unlock()
+	s.addVideo(video)
 
-	log.Printf("✅ Vidéo uploadée: %s (%s)", video.Title, filename)
+	requestLogger(r).Info("vidéo uploadée", "video_id", video.ID, "title", video.Title, "file", filename, "bytes", size)
+
+	// Pré-calculer le manifest pour les premiers clients, puis marquer la vidéo prête
+	s.startProcessing(video.ID, filename)
 
 	// Répondre avec les infos
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(video)
 }
 
-// handleList renvoie le catalogue
+// handleList renvoie le catalogue. L'en-tête X-Catalog-Seq donne le numéro du
+// dernier changement inclus, à passer ensuite à /changes?since=N.
 func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
 	s.catalogLock.RLock()
 	defer s.catalogLock.RUnlock()
@@ -312,15 +350,18 @@ func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Catalog-Seq", strconv.FormatUint(s.seq, 10))
 	json.NewEncoder(w).Encode(videos)
 }
 
 // handlePeerInfo renvoie les infos du nœud P2P
 func (s *Server) handlePeerInfo(w http.ResponseWriter, r *http.Request) {
-	info := map[string]interface{}{
-		"peer_id": s.p2pHost.ID().String(),
-		"addrs":   s.p2pHost.Addrs(),
-		"peers":   len(s.p2pHost.Network().Peers()),
+	info := shared.ServerPeerInfo{
+		PeerID: s.p2pHost.ID().String(),
+		Peers:  len(s.p2pHost.Network().Peers()),
+	}
+	for _, addr := range s.p2pHost.Addrs() {
+		info.Addrs = append(info.Addrs, addr.String())
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -331,85 +372,117 @@ func (s *Server) handlePeerInfo(w http.ResponseWriter, r *http.Request) {
 // UTILITAIRES
 // ============================================
 
-// loadCatalog charge le catalogue depuis le disque
-func (s *Server) loadCatalog() {
-	files, err := os.ReadDir(UploadDir)
-	if err != nil {
-		log.Printf("⚠️ Impossible de lire %s: %v", UploadDir, err)
-		return
-	}
-
-	for _, file := range files {
-		if !file.IsDir() && strings.HasPrefix(file.Name(), "video_") {
-			info, _ := file.Info()
-			video := &Video{
-				ID:         generateID(),
-				Title:      strings.TrimSuffix(file.Name(), filepath.Ext(file.Name())),
-				Filename:   file.Name(),
-				Size:       info.Size(),
-				UploadedAt: info.ModTime(),
-				Creator:    "Anonymous",
-				Thumbnail:  "/thumbnails/default.jpg",
-			}
-			s.catalog[video.ID] = video
-		}
-	}
-
-	log.Printf("📚 Catalogue chargé: %d vidéos", len(s.catalog))
-}
-
 // generateID génère un ID unique
 func generateID() string {
 	return fmt.Sprintf("%d", time.Now().UnixNano())
 }
 
 // ============================================
-// MAIN
+// ROUTEUR HTTP
 // ============================================
 
-func main() {
-	log.Println("🎬 Démarrage de pip bin Go Server...")
-
-	// Créer le serveur
-	server := NewServer()
-	if err := server.Initialize(); err != nil {
-		log.Fatalf("❌ Erreur initialisation: %v", err)
-	}
-
-	// Configurer le routeur HTTP
+// newRouter déclare les routes de l'API; chacune doit être décrite dans backend_openapi.go
+func newRouter(server *Server) *mux.Router {
 	router := mux.NewRouter()
+	router.NotFoundHandler = shared.NotFoundHandler()
+	router.MethodNotAllowedHandler = shared.MethodNotAllowedHandler()
 
 	// Routes API
-	router.HandleFunc("/upload", server.handleUpload).Methods("POST")
+	router.Handle("/upload", instrumentUpload(server.handleUpload)).Methods("POST")
 	router.HandleFunc("/list", server.handleList).Methods("GET")
+	router.HandleFunc("/videos/{id}", server.handleGetVideo).Methods("GET")
+	router.HandleFunc("/changes", server.handleChanges).Methods("GET")
+	router.HandleFunc("/changes/stream", server.handleChangesStream).Methods("GET")
 	router.HandleFunc("/peer-info", server.handlePeerInfo).Methods("GET")
+	router.HandleFunc("/config", server.handleGetConfig).Methods("GET")
+	router.Handle("/metrics", handleMetrics()).Methods("GET")
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	}).Methods("GET")
+	router.HandleFunc("/health/live", server.handleLive).Methods("GET")
+	router.HandleFunc("/health/ready", server.handleReady).Methods("GET")
+	router.HandleFunc("/openapi.json", handleOpenAPI()).Methods("GET")
 
-	// Servir les fichiers statiques (uploads, thumbnails)
-	router.PathPrefix("/uploads/").Handler(
-		http.StripPrefix("/uploads/", http.FileServer(http.Dir(UploadDir))),
-	)
+	// Servir les vidéos depuis le stockage et les miniatures depuis le disque
+	router.HandleFunc("/uploads/{filename}", server.handleServeUpload).Methods("GET", "HEAD")
 	router.PathPrefix("/thumbnails/").Handler(
-		http.StripPrefix("/thumbnails/", http.FileServer(http.Dir(ThumbnailDir))),
+		http.StripPrefix("/thumbnails/", http.FileServer(http.Dir(cfg.ThumbnailDir))),
 	)
 
+	return router
+}
+
+// ============================================
+// MAIN
+// ============================================
+
+func main() {
+	// Charger la configuration: défauts, fichier YAML, environnement puis flags
+	loaded, args, err := loadConfig(os.Args[1:])
+	if err != nil {
+		fatal("configuration invalide", "err", err)
+	}
+	cfg = loaded
+	setupLogging(cfg.Log)
+
+	// pipbingo-server [options] admin <commande>: maintenance hors ligne du catalogue et du stockage
+	if len(args) > 0 && args[0] == "admin" {
+		os.Exit(runAdmin(args[1:]))
+	}
+	if len(args) > 0 {
+		fatal("argument inattendu", "arg", args[0])
+	}
+	logMain.Info("démarrage de pip bin Go Server")
+
+	// Créer le serveur
+	server := NewServer()
+	if err := server.Initialize(); err != nil {
+		fatal("initialisation impossible", "err", err)
+	}
+
+	// Configurer le routeur HTTP
+	router := newRouter(server)
+
 	// Configuration CORS
 	corsHandler := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"*"},
+		ExposedHeaders:   []string{"X-Catalog-Seq", "X-Request-ID"},
 		AllowCredentials: true,
 	})
 
 	// Démarrer le serveur HTTP
-	log.Printf("🚀 Serveur HTTP démarré sur http://localhost%s", HTTPPort)
-	log.Printf("🌐 Nœud P2P actif sur le port %d", P2PPort)
-	log.Println("📡 Prêt à recevoir des uploads et à seeder des vidéos!")
+	logMain.Info("prêt à recevoir des uploads et à seeder des vidéos", "http_addr", cfg.HTTPAddr, "p2p_port", cfg.P2PPort)
+
+	// Arrêt propre sur SIGINT ou SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	if err := http.ListenAndServe(HTTPPort, corsHandler.Handler(router)); err != nil {
-		log.Fatalf("❌ Erreur serveur HTTP: %v", err)
+	// Chaque requête reçoit un request_id repris dans ses logs
+	httpServer := &http.Server{Addr: cfg.HTTPAddr, Handler: shared.WithRequestLogging(logHTTP, corsHandler.Handler(router))}
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("serveur HTTP arrêté", "err", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop() // Un second signal arrête immédiatement le processus
+	logMain.Info("arrêt demandé, fin des transferts en cours", "timeout", cfg.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// Plus de nouveau travail, puis les requêtes HTTP en cours (uploads) et les transferts P2P se terminent
+	server.stopAccepting()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		logMain.Warn("requêtes HTTP interrompues", "err", err)
+		httpServer.Close()
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logMain.Warn("fermeture du nœud P2P", "err", err)
 	}
+	logMain.Info("serveur arrêté")
 }
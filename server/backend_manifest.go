@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// MANIFESTS (EMPREINTES DES CHUNKS)
+// ============================================
+
+// Manifest décrit le découpage d'un fichier en chunks et l'empreinte de chacun (pipbingo/shared)
+type Manifest = shared.Manifest
+
+// manifestEntry garde un manifest calculé tant que le fichier ne change pas.
+// L'entrée est publiée dès le début du calcul: les demandes concurrentes pour le
+// même fichier attendent done au lieu de relire le blob.
+type manifestEntry struct {
+	manifest *Manifest
+	sha256   string // empreinte du fichier entier, calculée dans la même lecture
+	size     int64
+	modTime  time.Time
+	done     chan struct{} // fermé à la fin du calcul
+	err      error         // échec du calcul (l'entrée est alors retirée du cache)
+}
+
+// chunkCount renvoie le nombre de chunks d'un fichier de la taille donnée
+func chunkCount(size int64) int {
+	return int((size + int64(cfg.ChunkSize) - 1) / int64(cfg.ChunkSize))
+}
+
+// getManifest renvoie le manifest d'un fichier uploadé, en le calculant si besoin
+func (s *Server) getManifest(filename string) (*Manifest, error) {
+	entry, err := s.manifestEntry(filename)
+	if err != nil {
+		return nil, err
+	}
+	return entry.manifest, nil
+}
+
+// manifestEntry renvoie le manifest et l'empreinte d'un fichier, depuis le cache s'il n'a pas bougé
+func (s *Server) manifestEntry(filename string) (*manifestEntry, error) {
+	filename = filepath.Base(filename)
+	ctx := context.Background()
+
+	info, err := s.store.Stat(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// Réutiliser le manifest si le fichier n'a pas bougé, ou attendre le calcul en cours.
+	// Le verrou ne couvre que la table: la lecture du blob se fait en dehors.
+	s.manifestsLock.Lock()
+	entry, ok := s.manifests[filename]
+	if ok && entry.size == info.Size && entry.modTime.Equal(info.ModTime) {
+		s.manifestsLock.Unlock()
+		<-entry.done
+		if entry.err != nil {
+			return nil, entry.err
+		}
+		return entry, nil
+	}
+	entry = &manifestEntry{size: info.Size, modTime: info.ModTime, done: make(chan struct{})}
+	s.manifests[filename] = entry
+	s.manifestsLock.Unlock()
+
+	start := time.Now()
+	entry.manifest, entry.sha256, entry.err = s.computeManifest(ctx, filename)
+	if entry.err != nil {
+		s.manifestsLock.Lock()
+		if s.manifests[filename] == entry {
+			delete(s.manifests, filename)
+		}
+		s.manifestsLock.Unlock()
+		close(entry.done)
+		return nil, entry.err
+	}
+	close(entry.done)
+
+	logCatalog.Info("manifest calculé", "file", filename, "chunks", len(entry.manifest.ChunkHashes),
+		"duration", time.Since(start).Round(time.Millisecond))
+	return entry, nil
+}
+
+// computeManifest lit un blob en entier: manifest par chunk et empreinte du fichier
+func (s *Server) computeManifest(ctx context.Context, filename string) (*Manifest, string, error) {
+	file, err := s.store.GetRange(ctx, filename, 0, -1)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	manifest, err := buildManifest(io.TeeReader(file, hash), filename)
+	if err != nil {
+		return nil, "", err
+	}
+	return manifest, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// buildManifest lit un fichier et calcule l'empreinte de chaque chunk
+func buildManifest(file io.Reader, filename string) (*Manifest, error) {
+	manifest := &Manifest{
+		Filename:  filename,
+		ChunkSize: cfg.ChunkSize,
+	}
+
+	buf := make([]byte, cfg.ChunkSize)
+	for {
+		n, err := io.ReadFull(file, buf)
+		if n > 0 {
+			sum := sha256.Sum256(buf[:n])
+			manifest.ChunkHashes = append(manifest.ChunkHashes, hex.EncodeToString(sum[:]))
+			manifest.Size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("lecture de %s: %w", filename, err)
+		}
+	}
+
+	return manifest, nil
+}
+
+// handleManifestRequest envoie le manifest d'un fichier
+func (s *Server) handleManifestRequest(stream network.Stream, req P2PRequest) {
+	manifest, err := s.getManifest(req.Filename)
+	if err != nil {
+		streamLogger(stream).Info("manifest indisponible", "err", err)
+		s.sendP2PError(stream, shared.CodeFileNotFound, shared.Details{"filename": filepath.Base(req.Filename)})
+		return
+	}
+
+	response := P2PResponse{
+		Status:      shared.StatusSuccess,
+		TotalChunks: len(manifest.ChunkHashes),
+		Manifest:    manifest,
+	}
+
+	if err := json.NewEncoder(stream).Encode(response); err != nil {
+		streamLogger(stream).Warn("envoi du manifest échoué", "err", err)
+	}
+}
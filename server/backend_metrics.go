@@ -0,0 +1,109 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+
+	p2pmetrics "github.com/libp2p/go-libp2p/core/metrics"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// MÉTRIQUES PROMETHEUS
+// ============================================
+
+// bandwidthCounter compte les octets échangés par le nœud libp2p, par protocole et par peer
+var bandwidthCounter = p2pmetrics.NewBandwidthCounter()
+
+// serverMetrics regroupe les métriques exposées sur /metrics
+type serverMetrics struct {
+	registry    *prometheus.Registry
+	uploads     *prometheus.CounterVec
+	uploadTime  *prometheus.HistogramVec
+	uploadBytes prometheus.Counter
+	p2p         *shared.P2PMetrics
+}
+
+var metrics = newServerMetrics()
+
+func newServerMetrics() *serverMetrics {
+	m := &serverMetrics{
+		registry: prometheus.NewRegistry(),
+		p2p:      shared.NewP2PMetrics(shared.ActionRequestFile, shared.ActionGetManifest, shared.ActionAnnounce),
+		uploads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pipbingo_uploads_total",
+			Help: "Uploads de vidéos reçus, par code HTTP de la réponse.",
+		}, []string{"code"}),
+		uploadTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "pipbingo_upload_duration_seconds",
+			Help:    "Durée des uploads de vidéos, par code HTTP de la réponse.",
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 12), // 100 ms à ~3 min
+		}, []string{"code"}),
+		uploadBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "pipbingo_upload_bytes_total",
+			Help: "Octets de vidéos enregistrés dans le stockage.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.uploads, m.uploadTime, m.uploadBytes,
+		shared.NewBandwidthCollector(bandwidthCounter),
+	)
+	m.registry.MustRegister(m.p2p.Collectors()...)
+	return m
+}
+
+// registerServerMetrics ajoute les métriques calculées à la lecture depuis l'état du serveur
+func (s *Server) registerServerMetrics() {
+	metrics.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "pipbingo_connected_peers",
+			Help: "Peers connectés au nœud libp2p.",
+		}, func() float64 {
+			return float64(len(s.p2pHost.Network().Peers()))
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "pipbingo_catalog_videos",
+			Help: "Vidéos du catalogue.",
+		}, func() float64 {
+			s.catalogLock.RLock()
+			defer s.catalogLock.RUnlock()
+			return float64(len(s.catalog))
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "pipbingo_catalog_seq",
+			Help: "Numéro du dernier changement du catalogue.",
+		}, func() float64 {
+			s.catalogLock.RLock()
+			defer s.catalogLock.RUnlock()
+			return float64(s.seq)
+		}),
+	)
+}
+
+// instrumentUpload compte les uploads et mesure leur durée par code HTTP
+func instrumentUpload(next http.HandlerFunc) http.Handler {
+	return promhttp.InstrumentHandlerDuration(metrics.uploadTime,
+		promhttp.InstrumentHandlerCounter(metrics.uploads, next))
+}
+
+// handleMetrics expose les métriques au format texte Prometheus
+func handleMetrics() http.Handler {
+	return promhttp.HandlerFor(metrics.registry, promhttp.HandlerOpts{})
+}
+
+// ============================================
+// REQUÊTES P2P
+// ============================================
+
+// streamLogger renvoie le logger d'un stream P2P entrant
+func streamLogger(stream network.Stream) *slog.Logger {
+	return shared.StreamLogger(stream, logP2P)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// SPÉCIFICATION OPENAPI
+// ============================================
+
+// apiRoutes documente chaque route du routeur HTTP. Une route ajoutée dans newRouter
+// sans entrée ici, ou une entrée sans route, fait échouer TestOpenAPIRoutes.
+var apiRoutes = []shared.APIRoute{
+	{Method: "POST", Path: "/upload", Tag: "catalogue", Summary: "Uploader une vidéo (traitée ensuite en arrière-plan)",
+		Form: []shared.APIParam{
+			{Name: "video", Type: "file", Description: "fichier vidéo (video/*)", Required: true},
+			{Name: "title", Type: "string"},
+			{Name: "description", Type: "string"},
+			{Name: "creator", Type: "string"},
+		},
+		Response: Video{}, Errors: []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType,
+			http.StatusInternalServerError, http.StatusInsufficientStorage}},
+	{Method: "GET", Path: "/list", Tag: "catalogue", Summary: "Lister le catalogue (en-tête X-Catalog-Seq: dernier changement inclus)",
+		Response: []Video{}},
+	{Method: "GET", Path: "/videos/{id}", Tag: "catalogue", Summary: "Lire une vidéo",
+		Response: Video{}, Errors: []int{http.StatusNotFound}},
+	{Method: "GET", Path: "/changes", Tag: "catalogue", Summary: "Changements du catalogue postérieurs à since",
+		Query:    []shared.APIParam{{Name: "since", Type: "integer", Description: "dernier changement connu (X-Catalog-Seq)"}},
+		Response: shared.ChangesPage{}, Errors: []int{http.StatusBadRequest, http.StatusGone}},
+	{Method: "GET", Path: "/changes/stream", Tag: "catalogue", Summary: "Flux SSE des changements (événement reset si since est trop ancien)",
+		Query:       []shared.APIParam{{Name: "since", Type: "integer", Description: "dernier changement connu; à défaut Last-Event-ID"}},
+		ContentType: "text/event-stream", Errors: []int{http.StatusBadRequest, http.StatusServiceUnavailable}},
+	{Method: "GET", Path: "/peer-info", Tag: "p2p", Summary: "Identité et adresses du nœud P2P du serveur",
+		Response: shared.ServerPeerInfo{}},
+	{Method: "GET", Path: "/config", Tag: "exploitation", Summary: "Configuration effective (secrets masqués)",
+		Response: Config{}},
+	{Method: "GET", Path: "/metrics", Tag: "exploitation", Summary: "Métriques Prometheus",
+		ContentType: "text/plain"},
+	{Method: "GET", Path: "/health", Tag: "exploitation", Summary: "Répond OK (conservé pour compatibilité)",
+		ContentType: "text/plain"},
+	{Method: "GET", Path: "/health/live", Tag: "exploitation", Summary: "Le processus répond",
+		Response: HealthReport{}},
+	{Method: "GET", Path: "/health/ready", Tag: "exploitation", Summary: "Stockage, disque, nœud P2P et catalogue opérationnels",
+		Response: HealthReport{}, Errors: []int{http.StatusServiceUnavailable}},
+	{Method: "GET", Path: "/openapi.json", Tag: "exploitation", Summary: "Cette spécification",
+		ContentType: "application/json"},
+	{Method: "GET", Path: "/uploads/{filename}", Tag: "fichiers", Summary: "Lire une vidéo uploadée (requêtes Range acceptées)",
+		ContentType: "video/*", Errors: []int{http.StatusNotFound, http.StatusInternalServerError}},
+	{Method: "HEAD", Path: "/uploads/{filename}", Tag: "fichiers", Summary: "Taille et date d'une vidéo uploadée",
+		Errors: []int{http.StatusNotFound}},
+	{Method: "GET", Path: "/thumbnails/{name}", Tag: "fichiers", Summary: "Miniature d'une vidéo",
+		ContentType: "image/*", Errors: []int{http.StatusNotFound}},
+}
+
+// handleOpenAPI sert la spécification OpenAPI 3 de l'API HTTP
+func handleOpenAPI() http.HandlerFunc {
+	spec, err := json.MarshalIndent(shared.OpenAPISpec("pip bin Go - serveur central",
+		"Catalogue, uploads et flux de changements du serveur central.", apiRoutes), "", "  ")
+	if err != nil {
+		fatal("spécification OpenAPI invalide", "err", err)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	}
+}
+
+// pathVariable repère les variables {id} d'un chemin documenté
+var pathVariable = regexp.MustCompile(`\{[^}]+\}`)
+
+// checkOpenAPIRoutes vérifie que chaque route du routeur figure dans apiRoutes, et
+// que chaque route documentée est servie par le routeur
+func checkOpenAPIRoutes(router *mux.Router) error {
+	var missing []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{""} // PathPrefix: toutes les méthodes
+		}
+		for _, method := range methods {
+			if !shared.Documented(apiRoutes, method, path) {
+				missing = append(missing, strings.TrimSpace(method+" "+path))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var unserved []string
+	for _, route := range apiRoutes {
+		req, err := http.NewRequest(route.Method, pathVariable.ReplaceAllString(route.Path, "x"), nil)
+		if err != nil {
+			return err
+		}
+		var match mux.RouteMatch
+		if !router.Match(req, &match) || match.MatchErr != nil {
+			unserved = append(unserved, route.Method+" "+route.Path)
+		}
+	}
+
+	var errs []error
+	if len(missing) > 0 {
+		errs = append(errs, fmt.Errorf("routes absentes de la spécification OpenAPI: %s", strings.Join(missing, ", ")))
+	}
+	if len(unserved) > 0 {
+		errs = append(errs, fmt.Errorf("routes documentées absentes du routeur: %s", strings.Join(unserved, ", ")))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// ============================================
+// ARRÊT PROPRE
+// ============================================
+
+// beginTransfer enregistre un travail en cours (stream P2P, traitement d'un upload)
+// que l'arrêt doit attendre; renvoie false une fois l'arrêt commencé
+func (s *Server) beginTransfer() bool {
+	s.transfersLock.Lock()
+	defer s.transfersLock.Unlock()
+
+	if s.shuttingDown {
+		return false
+	}
+	s.transfers.Add(1)
+	return true
+}
+
+// endTransfer signale la fin d'un travail enregistré par beginTransfer
+func (s *Server) endTransfer() {
+	s.transfers.Done()
+}
+
+// isShuttingDown indique si l'arrêt a commencé
+func (s *Server) isShuttingDown() bool {
+	s.transfersLock.Lock()
+	defer s.transfersLock.Unlock()
+	return s.shuttingDown
+}
+
+// startProcessing lance le traitement d'une vidéo en tâche de fond. Pendant l'arrêt,
+// la vidéo reste en processing et son traitement reprendra au prochain démarrage.
+func (s *Server) startProcessing(id, filename string) {
+	if !s.beginTransfer() {
+		return
+	}
+	go func() {
+		defer s.endTransfer()
+		s.processVideo(id, filename)
+	}()
+}
+
+// stopAccepting refuse les nouveaux streams P2P et ferme les flux de changements,
+// pour que l'arrêt du serveur HTTP n'attende pas des connexions SSE sans fin
+func (s *Server) stopAccepting() {
+	s.transfersLock.Lock()
+	s.shuttingDown = true
+	s.transfersLock.Unlock()
+
+	s.p2pHost.RemoveStreamHandler(protocol.ID(P2PProtocolID))
+
+	s.catalogLock.Lock()
+	for watcher := range s.watchers {
+		delete(s.watchers, watcher)
+		close(watcher)
+	}
+	s.catalogLock.Unlock()
+}
+
+// Shutdown attend les transferts en cours jusqu'à l'échéance de ctx, persiste
+// le catalogue puis ferme le nœud P2P. Appelé après stopAccepting.
+func (s *Server) Shutdown(ctx context.Context) error {
+	drained := make(chan struct{})
+	go func() {
+		s.transfers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		logMain.Info("transferts en cours terminés")
+	case <-ctx.Done():
+		logMain.Warn("délai d'arrêt dépassé, transferts restants interrompus")
+	}
+
+	s.catalogLock.Lock()
+	s.saveCatalogLocked()
+	s.catalogLock.Unlock()
+	logCatalog.Info("catalogue sauvegardé")
+
+	return s.p2pHost.Close()
+}
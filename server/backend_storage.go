@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// STOCKAGE DES VIDÉOS (BLOB STORE)
+// ============================================
+
+var (
+	// ErrBlobNotFound est renvoyée quand un blob n'existe pas
+	ErrBlobNotFound = errors.New("blob introuvable")
+	// ErrInsufficientStorage est renvoyée quand le stockage n'a plus la place (réserve comprise)
+	ErrInsufficientStorage = errors.New("espace de stockage insuffisant")
+)
+
+// BlobInfo décrit un blob stocké
+type BlobInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// BlobStore abstrait le stockage des vidéos uploadées: disque local, S3, mémoire...
+type BlobStore interface {
+	// Put écrit un blob complet; size vaut -1 si inconnue
+	Put(ctx context.Context, name string, r io.Reader, size int64) (int64, error)
+	// GetRange lit length octets à partir de offset (length < 0: jusqu'à la fin)
+	GetRange(ctx context.Context, name string, offset, length int64) (io.ReadCloser, error)
+	Stat(ctx context.Context, name string) (BlobInfo, error)
+	Delete(ctx context.Context, name string) error
+	// List renvoie les blobs dont le nom commence par prefix
+	List(ctx context.Context, prefix string) ([]BlobInfo, error)
+}
+
+// SpaceReporter est implémentée par les stockages dont la place est limitée
+type SpaceReporter interface {
+	FreeSpace() (int64, error)
+}
+
+// checkFreeSpace vérifie que le stockage peut accueillir size octets en gardant cfg.MinFreeSpace de réserve
+func checkFreeSpace(store BlobStore, size int64) error {
+	reporter, ok := store.(SpaceReporter)
+	if !ok {
+		return nil
+	}
+
+	free, err := reporter.FreeSpace()
+	if err != nil {
+		return fmt.Errorf("espace disque inconnu: %w", err)
+	}
+	if free-size < cfg.MinFreeSpace {
+		return fmt.Errorf("%w: %d Mo libres, %d Mo demandés, %d Mo de réserve",
+			ErrInsufficientStorage, free>>20, size>>20, cfg.MinFreeSpace>>20)
+	}
+	return nil
+}
+
+// ctxReader interrompt une lecture dès que le contexte est annulé, pour que Put
+// n'écrive pas un upload abandonné jusqu'au bout
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr ctxReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+// Backends de stockage disponibles
+const (
+	StorageLocal  = "local"
+	StorageS3     = "s3"
+	StorageMemory = "memory"
+)
+
+// newBlobStore crée le backend choisi par la configuration (local par défaut)
+func newBlobStore(conf StorageConfig) (BlobStore, error) {
+	switch backend := conf.Backend; backend {
+	case "", StorageLocal:
+		return NewLocalStore(cfg.UploadDir)
+	case StorageS3:
+		s3 := conf.S3
+		return NewS3Store(s3.Endpoint, s3.Bucket, s3.Region, s3.AccessKey, s3.SecretKey)
+	case StorageMemory:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("backend de stockage inconnu: %q", backend)
+	}
+}
+
+// ============================================
+// STOCKAGE LOCAL
+// ============================================
+
+// LocalStore stocke les blobs dans un dossier du disque local
+type LocalStore struct {
+	dir string
+}
+
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("impossible de créer %s: %w", dir, err)
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+// path renvoie le chemin d'un blob, sans possibilité de sortir du dossier
+func (ls *LocalStore) path(name string) string {
+	return filepath.Join(ls.dir, filepath.Base(name))
+}
+
+// Put écrit le blob dans un fichier temporaire puis le renomme
+func (ls *LocalStore) Put(ctx context.Context, name string, r io.Reader, size int64) (int64, error) {
+	tmp, err := os.CreateTemp(ls.dir, ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, ctxReader{ctx, r})
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return written, err
+	}
+	if size >= 0 && written != size {
+		return written, fmt.Errorf("%d octets écrits au lieu de %d", written, size)
+	}
+
+	return written, os.Rename(tmp.Name(), ls.path(name))
+}
+
+func (ls *LocalStore) GetRange(ctx context.Context, name string, offset, length int64) (io.ReadCloser, error) {
+	file, err := os.Open(ls.path(name))
+	if os.IsNotExist(err) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if length < 0 {
+		return file, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, length), file}, nil
+}
+
+// FreeSpace renvoie l'espace disponible sur le disque du dossier
+func (ls *LocalStore) FreeSpace() (int64, error) {
+	return shared.DiskFree(ls.dir)
+}
+
+func (ls *LocalStore) Stat(ctx context.Context, name string) (BlobInfo, error) {
+	info, err := os.Stat(ls.path(name))
+	if os.IsNotExist(err) {
+		return BlobInfo{}, ErrBlobNotFound
+	}
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	return BlobInfo{Name: filepath.Base(name), Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (ls *LocalStore) Delete(ctx context.Context, name string) error {
+	err := os.Remove(ls.path(name))
+	if os.IsNotExist(err) {
+		return ErrBlobNotFound
+	}
+	return err
+}
+
+func (ls *LocalStore) List(ctx context.Context, prefix string) ([]BlobInfo, error) {
+	entries, err := os.ReadDir(ls.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var blobs []BlobInfo
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		blobs = append(blobs, BlobInfo{Name: entry.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return blobs, nil
+}
+
+// ============================================
+// STOCKAGE EN MÉMOIRE
+// ============================================
+
+// MemoryStore garde les blobs en mémoire: utile en développement et
+// comme faux stockage pour essayer le serveur sans disque ni S3
+type MemoryStore struct {
+	blobs map[string]memoryBlob
+	lock  sync.RWMutex
+}
+
+type memoryBlob struct {
+	data    []byte
+	modTime time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{blobs: make(map[string]memoryBlob)}
+}
+
+func (ms *MemoryStore) Put(ctx context.Context, name string, r io.Reader, size int64) (int64, error) {
+	data, err := io.ReadAll(ctxReader{ctx, r})
+	if err != nil {
+		return int64(len(data)), err
+	}
+	if size >= 0 && int64(len(data)) != size {
+		return int64(len(data)), fmt.Errorf("%d octets reçus au lieu de %d", len(data), size)
+	}
+
+	ms.lock.Lock()
+	ms.blobs[name] = memoryBlob{data: data, modTime: time.Now()}
+	ms.lock.Unlock()
+	return int64(len(data)), nil
+}
+
+func (ms *MemoryStore) GetRange(ctx context.Context, name string, offset, length int64) (io.ReadCloser, error) {
+	ms.lock.RLock()
+	blob, ok := ms.blobs[name]
+	ms.lock.RUnlock()
+	if !ok {
+		return nil, ErrBlobNotFound
+	}
+
+	size := int64(len(blob.data))
+	if offset > size {
+		offset = size
+	}
+	end := size
+	if length >= 0 && offset+length < size {
+		end = offset + length
+	}
+	return io.NopCloser(bytes.NewReader(blob.data[offset:end])), nil
+}
+
+func (ms *MemoryStore) Stat(ctx context.Context, name string) (BlobInfo, error) {
+	ms.lock.RLock()
+	defer ms.lock.RUnlock()
+
+	blob, ok := ms.blobs[name]
+	if !ok {
+		return BlobInfo{}, ErrBlobNotFound
+	}
+	return BlobInfo{Name: name, Size: int64(len(blob.data)), ModTime: blob.modTime}, nil
+}
+
+func (ms *MemoryStore) Delete(ctx context.Context, name string) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	if _, ok := ms.blobs[name]; !ok {
+		return ErrBlobNotFound
+	}
+	delete(ms.blobs, name)
+	return nil
+}
+
+func (ms *MemoryStore) List(ctx context.Context, prefix string) ([]BlobInfo, error) {
+	ms.lock.RLock()
+	defer ms.lock.RUnlock()
+
+	var blobs []BlobInfo
+	for name, blob := range ms.blobs {
+		if strings.HasPrefix(name, prefix) {
+			blobs = append(blobs, BlobInfo{Name: name, Size: int64(len(blob.data)), ModTime: blob.modTime})
+		}
+	}
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].Name < blobs[j].Name })
+	return blobs, nil
+}
+
+// ============================================
+// LECTURE HTTP DES BLOBS
+// ============================================
+
+// blobReadSeeker expose un blob comme un io.ReadSeeker pour http.ServeContent.
+// Chaque seek ferme la lecture en cours; la suivante repart de l'offset demandé.
+type blobReadSeeker struct {
+	ctx    context.Context
+	store  BlobStore
+	name   string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (br *blobReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += br.offset
+	case io.SeekEnd:
+		offset += br.size
+	default:
+		return 0, fmt.Errorf("whence %d invalide", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("offset négatif")
+	}
+
+	if offset != br.offset && br.body != nil {
+		br.body.Close()
+		br.body = nil
+	}
+	br.offset = offset
+	return offset, nil
+}
+
+func (br *blobReadSeeker) Read(p []byte) (int, error) {
+	if br.offset >= br.size {
+		return 0, io.EOF
+	}
+	if br.body == nil {
+		body, err := br.store.GetRange(br.ctx, br.name, br.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		br.body = body
+	}
+
+	n, err := br.body.Read(p)
+	br.offset += int64(n)
+	return n, err
+}
+
+func (br *blobReadSeeker) Close() error {
+	if br.body != nil {
+		return br.body.Close()
+	}
+	return nil
+}
+
+// handleServeUpload sert une vidéo du stockage avec support des requêtes Range
+func (s *Server) handleServeUpload(w http.ResponseWriter, r *http.Request) {
+	name := filepath.Base(mux.Vars(r)["filename"])
+
+	info, err := s.store.Stat(r.Context(), name)
+	if errors.Is(err, ErrBlobNotFound) {
+		shared.WriteError(w, r, shared.CodeFileNotFound, shared.Details{"filename": name})
+		return
+	}
+	if err != nil {
+		shared.WriteError(w, r, shared.CodeStorageError, nil)
+		return
+	}
+
+	reader := &blobReadSeeker{ctx: r.Context(), store: s.store, name: name, size: info.Size}
+	defer reader.Close()
+
+	http.ServeContent(w, r, name, info.ModTime, reader)
+}
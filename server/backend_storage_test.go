@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// ============================================
+// CONTRAT DES BLOB STORES
+// ============================================
+
+// TestBlobStoreContract vérifie que chaque backend se comporte de la même façon
+// vu du serveur: écriture, lectures partielles, métadonnées, liste et absences
+func TestBlobStoreContract(t *testing.T) {
+	backends := map[string]func(t *testing.T) BlobStore{
+		StorageLocal: func(t *testing.T) BlobStore {
+			store, err := NewLocalStore(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return store
+		},
+		StorageMemory: func(t *testing.T) BlobStore { return NewMemoryStore() },
+		StorageS3:     func(t *testing.T) BlobStore { return newFakeS3(t) },
+	}
+
+	for name, newStore := range backends {
+		t.Run(name, func(t *testing.T) {
+			testBlobStore(t, newStore(t))
+		})
+	}
+}
+
+func testBlobStore(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+	const content = "0123456789abcdefghij"
+
+	// Put: taille connue, taille inconnue, écrasement
+	blobs := map[string]string{
+		"video_1.mp4":       content,
+		"video_2.mp4":       "deux",
+		"video 3 (été).mp4": "trois",
+		"video_4.mp4":       "quatre",
+		"thumbnail_1.jpg":   "jpeg",
+	}
+	for name, data := range blobs {
+		n, err := store.Put(ctx, name, strings.NewReader(data), int64(len(data)))
+		if err != nil || n != int64(len(data)) {
+			t.Fatalf("Put(%q) = %d, %v", name, n, err)
+		}
+	}
+	if n, err := store.Put(ctx, "video_2.mp4", strings.NewReader("deux bis"), -1); err != nil || n != 8 {
+		t.Fatalf("Put taille inconnue = %d, %v", n, err)
+	}
+	blobs["video_2.mp4"] = "deux bis"
+
+	// Stat
+	for name, data := range blobs {
+		info, err := store.Stat(ctx, name)
+		if err != nil {
+			t.Fatalf("Stat(%q): %v", name, err)
+		}
+		if info.Name != name || info.Size != int64(len(data)) || info.ModTime.IsZero() {
+			t.Errorf("Stat(%q) = %+v", name, info)
+		}
+	}
+
+	// GetRange
+	ranges := []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, -1, content},
+		{0, 5, "01234"},
+		{10, 5, "abcde"},
+		{15, -1, "fghij"},
+		{15, 100, "fghij"}, // plage qui dépasse la fin
+		{19, 1, "j"},
+		{3, 0, ""},
+	}
+	for _, r := range ranges {
+		body, err := store.GetRange(ctx, "video_1.mp4", r.offset, r.length)
+		if err != nil {
+			t.Errorf("GetRange(%d, %d): %v", r.offset, r.length, err)
+			continue
+		}
+		data, err := io.ReadAll(body)
+		body.Close()
+		if err != nil || string(data) != r.want {
+			t.Errorf("GetRange(%d, %d) = %q, %v, attendu %q", r.offset, r.length, data, err, r.want)
+		}
+	}
+	if body, err := store.GetRange(ctx, "video 3 (été).mp4", 0, -1); err != nil {
+		t.Errorf("GetRange nom à encoder: %v", err)
+	} else {
+		data, _ := io.ReadAll(body)
+		body.Close()
+		if string(data) != "trois" {
+			t.Errorf("GetRange nom à encoder = %q", data)
+		}
+	}
+
+	// List: préfixe, ordre alphabétique, plusieurs pages sur S3
+	listed, err := store.List(ctx, "video")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var names []string
+	for _, info := range listed {
+		names = append(names, info.Name)
+		if info.Size != int64(len(blobs[info.Name])) {
+			t.Errorf("List: %s de %d octets, attendu %d", info.Name, info.Size, len(blobs[info.Name]))
+		}
+	}
+	if got, want := strings.Join(names, ","), "video 3 (été).mp4,video_1.mp4,video_2.mp4,video_4.mp4"; got != want {
+		t.Errorf("List(video) = %s, attendu %s", got, want)
+	}
+	if listed, err := store.List(ctx, "absent"); err != nil || len(listed) != 0 {
+		t.Errorf("List(absent) = %v, %v", listed, err)
+	}
+
+	// Put avec un contexte annulé: rien n'est écrit
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	for _, size := range []int64{5, -1} {
+		if _, err := store.Put(cancelled, "annule.mp4", strings.NewReader("abcde"), size); !errors.Is(err, context.Canceled) {
+			t.Errorf("Put annulé (taille %d): %v, attendu context.Canceled", size, err)
+		}
+	}
+	if _, err := store.Stat(ctx, "annule.mp4"); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("Stat après Put annulé: %v, attendu ErrBlobNotFound", err)
+	}
+
+	// Delete puis absences
+	if err := store.Delete(ctx, "video_1.mp4"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Stat(ctx, "video_1.mp4"); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("Stat après Delete: %v, attendu ErrBlobNotFound", err)
+	}
+	if _, err := store.GetRange(ctx, "video_1.mp4", 0, -1); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("GetRange après Delete: %v, attendu ErrBlobNotFound", err)
+	}
+	if err := store.Delete(ctx, "video_1.mp4"); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("second Delete: %v, attendu ErrBlobNotFound", err)
+	}
+	if listed, err := store.List(ctx, "video_1"); err != nil || len(listed) != 0 {
+		t.Errorf("List après Delete = %v, %v", listed, err)
+	}
+}
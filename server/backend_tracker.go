@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"pipbingo/shared"
+)
+
+// ============================================
+// TRACKER (DÉCOUVERTE DES PEERS PAR FICHIER)
+// ============================================
+
+// PeerInfo décrit un peer joignable pour un fichier (pipbingo/shared)
+type PeerInfo = shared.PeerInfo
+
+// Tracker retient quels daemons ont annoncé quels fichiers
+type Tracker struct {
+	swarms map[string]map[peer.ID]time.Time
+	lock   sync.Mutex
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		swarms: make(map[string]map[peer.ID]time.Time),
+	}
+}
+
+// announce enregistre un peer pour un fichier et renvoie les autres membres du swarm
+func (t *Tracker) announce(filename string, from peer.ID, isConnected func(peer.ID) bool) []peer.ID {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	swarm, ok := t.swarms[filename]
+	if !ok {
+		swarm = make(map[peer.ID]time.Time)
+		t.swarms[filename] = swarm
+	}
+	swarm[from] = time.Now()
+
+	others := make([]peer.ID, 0, len(swarm))
+	for id := range swarm {
+		if id == from {
+			continue
+		}
+		// Oublier les peers qui ne sont plus connectés au serveur
+		if !isConnected(id) {
+			delete(swarm, id)
+			continue
+		}
+		others = append(others, id)
+	}
+
+	return others
+}
+
+// handleAnnounceRequest inscrit le peer distant dans le swarm et renvoie les autres membres
+func (s *Server) handleAnnounceRequest(stream network.Stream, req P2PRequest) {
+	filename := filepath.Base(req.Filename)
+	from := stream.Conn().RemotePeer()
+
+	isConnected := func(id peer.ID) bool {
+		return s.p2pHost.Network().Connectedness(id) == network.Connected
+	}
+
+	var peers []PeerInfo
+	for _, id := range s.tracker.announce(filename, from, isConnected) {
+		info := PeerInfo{ID: id.String()}
+		for _, addr := range s.p2pHost.Peerstore().Addrs(id) {
+			info.Addrs = append(info.Addrs, addr.String())
+		}
+		peers = append(peers, info)
+	}
+
+	response := P2PResponse{
+		Status: shared.StatusSuccess,
+		Peers:  peers,
+	}
+
+	if err := json.NewEncoder(stream).Encode(response); err != nil {
+		streamLogger(stream).Warn("envoi de la liste de peers échoué", "err", err)
+	}
+
+	streamLogger(stream).Info("annonce reçue", "swarm_peers", len(peers))
+}
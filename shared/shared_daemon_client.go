@@ -0,0 +1,199 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ============================================
+// CLIENT DE L'API LOCALE DU DAEMON
+// ============================================
+
+// DaemonClient appelle l'API HTTP locale du daemon (téléchargements, cache, peers)
+type DaemonClient struct {
+	apiClient
+}
+
+// NewDaemonClient crée un client pour le daemon joignable à baseURL (http://localhost:9090)
+func NewDaemonClient(baseURL string) *DaemonClient {
+	return &DaemonClient{apiClient: newAPIClient(baseURL)}
+}
+
+func downloadPath(filename string, action string) string {
+	path := "/downloads/" + url.PathEscape(filename)
+	if action != "" {
+		path += "/" + action
+	}
+	return path
+}
+
+// Download met un fichier en file de téléchargement (plus grande priorité servie d'abord)
+func (c *DaemonClient) Download(ctx context.Context, filename string, priority int) error {
+	_, err := c.do(ctx, http.MethodPost, "/download", DownloadRequest{Filename: filename, Priority: priority}, nil)
+	return err
+}
+
+// Downloads renvoie les téléchargements triés par priorité
+func (c *DaemonClient) Downloads(ctx context.Context) (*DownloadList, error) {
+	var list DownloadList
+	if _, err := c.do(ctx, http.MethodGet, "/downloads", nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// DownloadStatus renvoie l'état d'un téléchargement
+func (c *DaemonClient) DownloadStatus(ctx context.Context, filename string) (*DownloadStatus, error) {
+	return c.downloadAction(ctx, http.MethodGet, filename, "", nil)
+}
+
+// Pause met un téléchargement en pause
+func (c *DaemonClient) Pause(ctx context.Context, filename string) (*DownloadStatus, error) {
+	return c.downloadAction(ctx, http.MethodPost, filename, "pause", nil)
+}
+
+// Resume relance un téléchargement en pause ou en erreur
+func (c *DaemonClient) Resume(ctx context.Context, filename string) (*DownloadStatus, error) {
+	return c.downloadAction(ctx, http.MethodPost, filename, "resume", nil)
+}
+
+// SetPriority change la priorité d'un téléchargement
+func (c *DaemonClient) SetPriority(ctx context.Context, filename string, priority int) (*DownloadStatus, error) {
+	return c.downloadAction(ctx, http.MethodPost, filename, "priority", map[string]int{"priority": priority})
+}
+
+// Cancel annule un téléchargement et retire son entrée
+func (c *DaemonClient) Cancel(ctx context.Context, filename string) error {
+	_, err := c.do(ctx, http.MethodDelete, downloadPath(filename, ""), nil, nil)
+	return err
+}
+
+// downloadAction appelle une route /downloads/{id}; nil si l'entrée a disparu (204)
+func (c *DaemonClient) downloadAction(ctx context.Context, method, filename, action string, in interface{}) (*DownloadStatus, error) {
+	var status DownloadStatus
+	if _, err := c.do(ctx, method, downloadPath(filename, action), in, &status); err != nil {
+		return nil, err
+	}
+	if status.Filename == "" {
+		return nil, nil
+	}
+	return &status, nil
+}
+
+// SetConcurrency change le nombre de téléchargements simultanés
+func (c *DaemonClient) SetConcurrency(ctx context.Context, maxConcurrent int) error {
+	_, err := c.do(ctx, http.MethodPut, "/downloads/concurrency", map[string]int{"max_concurrent": maxConcurrent}, nil)
+	return err
+}
+
+// Stats renvoie les statistiques P2P globales
+func (c *DaemonClient) Stats(ctx context.Context) (*StatsSnapshot, error) {
+	var stats StatsSnapshot
+	if _, err := c.do(ctx, http.MethodGet, "/stats", nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// Catalog renvoie le catalogue vu par le daemon (complet en ligne, limité au cache hors ligne)
+func (c *DaemonClient) Catalog(ctx context.Context) (*CatalogView, error) {
+	var view CatalogView
+	if _, err := c.do(ctx, http.MethodGet, "/catalog", nil, &view); err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+// Cache renvoie l'occupation du cache et l'état de chaque fichier
+func (c *DaemonClient) Cache(ctx context.Context) (*CacheView, error) {
+	var view CacheView
+	if _, err := c.do(ctx, http.MethodGet, "/cache", nil, &view); err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+// Pin épingle (ou libère) un fichier du cache: un fichier épinglé n'est jamais évincé
+func (c *DaemonClient) Pin(ctx context.Context, filename string, pinned bool) (*CacheView, error) {
+	method := http.MethodDelete
+	if pinned {
+		method = http.MethodPost
+	}
+	var view CacheView
+	if _, err := c.do(ctx, method, "/cache/"+url.PathEscape(filename)+"/pin", nil, &view); err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+// Peers renvoie l'état de choke des peers connus
+func (c *DaemonClient) Peers(ctx context.Context) (*PeersView, error) {
+	var view PeersView
+	if _, err := c.do(ctx, http.MethodGet, "/peers", nil, &view); err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+// Ready renvoie l'état de santé détaillé du daemon
+func (c *DaemonClient) Ready(ctx context.Context) (*HealthReport, error) {
+	return c.ready(ctx)
+}
+
+// ============================================
+// ÉVÉNEMENTS
+// ============================================
+
+// EventFilter restreint les événements reçus; vide = tous
+type EventFilter struct {
+	Video string   // un seul fichier (les statistiques globales passent toujours)
+	Types []string // EventDownload, EventStats...
+}
+
+// EventStream est le flux temps réel des événements du daemon
+type EventStream struct {
+	sse *sseStream
+}
+
+// OpenEvents s'abonne aux événements du daemon; l'état courant est rejoué à l'ouverture
+func (c *DaemonClient) OpenEvents(ctx context.Context, filter EventFilter) (*EventStream, error) {
+	query := url.Values{}
+	if filter.Video != "" {
+		query.Set("video", filter.Video)
+	}
+	if len(filter.Types) > 0 {
+		query.Set("types", strings.Join(filter.Types, ","))
+	}
+	path := "/events"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	sse, err := c.openSSE(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return &EventStream{sse: sse}, nil
+}
+
+// Next attend l'événement suivant
+func (s *EventStream) Next() (Event, error) {
+	raw, err := s.sse.next()
+	if err != nil {
+		return Event{}, err
+	}
+	var ev Event
+	if err := json.Unmarshal([]byte(raw.Data), &ev); err != nil {
+		return Event{}, fmt.Errorf("événement illisible: %w", err)
+	}
+	return ev, nil
+}
+
+// Close ferme le flux
+func (s *EventStream) Close() error {
+	return s.sse.Close()
+}
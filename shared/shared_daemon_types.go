@@ -0,0 +1,140 @@
+package shared
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ============================================
+// TÉLÉCHARGEMENTS (API DU DAEMON)
+// ============================================
+
+// États d'un téléchargement
+const (
+	StateQueued      = "queued"
+	StateDownloading = "downloading"
+	StatePaused      = "paused"
+	StateCompleted   = "completed"
+	StateSeeding     = "seeding"
+	StateError       = "error"
+	StateCancelled   = "cancelled"
+)
+
+// DownloadStatus représente l'état d'un téléchargement
+type DownloadStatus struct {
+	Filename        string     `json:"filename"`
+	Status          string     `json:"status"` // queued, downloading, paused, completed, seeding, error
+	Priority        int        `json:"priority"`
+	Error           string     `json:"error,omitempty"`
+	ErrorCode       string     `json:"error_code,omitempty"` // code d'erreur (Code*): insufficient_storage...
+	Progress        float64    `json:"progress"`
+	BytesDownloaded int64      `json:"bytes_downloaded"`
+	TotalBytes      int64      `json:"total_bytes"`
+	PeersConnected  int        `json:"peers_connected"`
+	DownloadSpeed   float64    `json:"download_speed"` // Ko/s
+	StartedAt       time.Time  `json:"started_at"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+}
+
+// DownloadRequest est le corps de POST /download
+type DownloadRequest struct {
+	Filename string `json:"filename"`
+	Priority int    `json:"priority"`
+}
+
+// DownloadList est la réponse de GET /downloads, triée par priorité
+type DownloadList struct {
+	MaxConcurrent int              `json:"max_concurrent"`
+	Downloads     []DownloadStatus `json:"downloads"`
+}
+
+// StatsSnapshot regroupe les statistiques P2P globales
+type StatsSnapshot struct {
+	PeerID                 string `json:"peer_id"`
+	ConnectedPeers         int    `json:"connected_peers"`
+	SeedingFiles           int    `json:"seeding_files"`
+	DownloadingFiles       int    `json:"downloading_files"`
+	QueuedFiles            int    `json:"queued_files"`
+	CacheFiles             int    `json:"cache_files"`
+	MaxConcurrentDownloads int    `json:"max_concurrent_downloads"`
+	UnchokedPeers          int    `json:"unchoked_peers"`
+}
+
+// ============================================
+// CATALOGUE, CACHE ET PEERS DU DAEMON
+// ============================================
+
+// CatalogEntry est une vidéo du catalogue telle que servie par le daemon
+type CatalogEntry struct {
+	Video
+	Cached         bool   `json:"cached"`                    // vidéo complète dans le cache, lisible sans le serveur
+	Offline        bool   `json:"offline"`                   // servie hors ligne: seule la lecture depuis le cache est possible
+	LocalThumbnail string `json:"local_thumbnail,omitempty"` // miniature servie par le daemon
+}
+
+// CatalogView est la réponse de GET /catalog
+type CatalogView struct {
+	Online   bool           `json:"online"`
+	Seq      uint64         `json:"seq"`
+	SyncedAt time.Time      `json:"synced_at"`
+	Videos   []CatalogEntry `json:"videos"`
+}
+
+// CacheFileInfo est l'état d'un fichier du cache tel que renvoyé par l'API
+type CacheFileInfo struct {
+	Filename   string    `json:"filename"`
+	Size       int64     `json:"size"`
+	LastAccess time.Time `json:"last_access"`
+	Pinned     bool      `json:"pinned"`
+	Seeding    bool      `json:"seeding"`
+}
+
+// CacheView est la réponse de GET /cache
+type CacheView struct {
+	MaxSize  int64           `json:"max_size"` // 0 = illimité
+	Used     int64           `json:"used"`
+	Reserved int64           `json:"reserved"` // place réservée par les téléchargements en cours
+	Files    []CacheFileInfo `json:"files"`
+}
+
+// PeerChokeInfo est l'état d'un peer tel que renvoyé par l'API
+type PeerChokeInfo struct {
+	ID           string  `json:"id"`
+	Unchoked     bool    `json:"unchoked"`
+	Optimistic   bool    `json:"optimistic"`
+	Interested   bool    `json:"interested"`
+	DownloadRate float64 `json:"download_rate"` // o/s reçus de ce peer
+	UploadRate   float64 `json:"upload_rate"`   // o/s envoyés à ce peer
+}
+
+// PeersView est la réponse de GET /peers
+type PeersView struct {
+	UnchokeSlots int             `json:"unchoke_slots"`
+	Peers        []PeerChokeInfo `json:"peers"`
+}
+
+// ============================================
+// ÉVÉNEMENTS TEMPS RÉEL DU DAEMON
+// ============================================
+
+// Types d'événements poussés sur /events
+const (
+	EventDownload        = "download"         // progression ou changement d'état d'un téléchargement
+	EventDownloadRemoved = "download_removed" // téléchargement annulé ou retiré du cache
+	EventSeeding         = "seeding"          // début, arrêt ou statistiques du seeding d'un fichier
+	EventStats           = "stats"            // statistiques globales (peers connectés, fichiers...)
+)
+
+// Event est un événement reçu de /events. Data se décode selon Type: DownloadStatus
+// pour EventDownload, StatsSnapshot pour EventStats...
+type Event struct {
+	ID       uint64          `json:"id"`
+	Type     string          `json:"type"`
+	Filename string          `json:"filename,omitempty"` // vide pour les événements globaux
+	Data     json.RawMessage `json:"data"`
+}
+
+// Decode décode les données de l'événement dans v
+func (ev Event) Decode(v interface{}) error {
+	return json.Unmarshal(ev.Data, v)
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package shared
+
+import "syscall"
+
+// DiskFree renvoie l'espace disponible (en octets) sur le système de fichiers contenant path
+func DiskFree(path string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	// Bavail: blocs disponibles pour un utilisateur non privilégié
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
@@ -0,0 +1,226 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ============================================
+// CODES D'ERREUR
+// ============================================
+
+// Codes d'erreur stables, communs à l'API HTTP (champ code) et au protocole P2P
+// (champ error). Un client les compare tels quels: ne jamais renommer un code existant.
+const (
+	// Génériques
+	CodeInvalidRequest       = "invalid_request"
+	CodeInternal             = "internal_error"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeStorageError         = "storage_error"
+	CodeInsufficientStorage  = "insufficient_storage"
+	CodeShuttingDown         = "shutting_down"
+	CodeStreamingUnsupported = "streaming_unsupported"
+
+	// Catalogue et uploads (serveur)
+	CodeVideoNotFound = "video_not_found"
+	CodeFileTooLarge  = "file_too_large"
+	CodeNoFile        = "no_file"
+	CodeNotAVideo     = "not_a_video"
+	CodeInvalidSince  = "invalid_since"
+	CodeSinceTooOld   = "since_too_old"
+
+	// Daemon
+	CodeDownloadNotFound     = "download_not_found"
+	CodeInvalidTransition    = "invalid_transition"
+	CodeNotInCache           = "not_in_cache"
+	CodeCacheFull            = "cache_full"
+	CodeUnknownFileSize      = "unknown_file_size"
+	CodeMissingPosition      = "missing_position"
+	CodeInvalidLimits        = "invalid_limits"
+	CodeScrubRunning         = "scrub_running"
+	CodeNotSeeding           = "not_seeding"
+	CodeSeedingPolicyReached = "seeding_policy_reached"
+
+	// Protocole P2P
+	CodeUnknownAction    = "unknown_action"
+	CodeFileNotFound     = "file_not_found"
+	CodeFileNotAvailable = "file_not_available" // fichier en cours de téléchargement, chunk absent
+	CodeInvalidChunk     = "invalid_chunk"
+	CodeReadError        = "read_error"
+	CodeNoUploadSlot     = "no_upload_slot"
+	CodeInvalidBitfield  = "invalid_bitfield"
+	CodeUnknownPeer      = "unknown_peer" // have reçu avant tout échange de bitfield
+)
+
+// Langues des messages d'erreur
+const (
+	LangFR          = "fr"
+	LangEN          = "en"
+	DefaultLanguage = LangFR
+)
+
+// errorInfo associe un code à son statut HTTP et à ses messages. Un {nom} dans un
+// message est remplacé par le détail du même nom.
+type errorInfo struct {
+	status int
+	fr, en string
+}
+
+var errorCatalog = map[string]errorInfo{
+	CodeInvalidRequest:       {http.StatusBadRequest, "Requête invalide", "Invalid request"},
+	CodeInternal:             {http.StatusInternalServerError, "Erreur interne", "Internal error"},
+	CodeNotFound:             {http.StatusNotFound, "Introuvable", "Not found"},
+	CodeMethodNotAllowed:     {http.StatusMethodNotAllowed, "Méthode non autorisée", "Method not allowed"},
+	CodeStorageError:         {http.StatusInternalServerError, "Erreur de stockage", "Storage error"},
+	CodeInsufficientStorage:  {http.StatusInsufficientStorage, "Espace de stockage insuffisant", "Insufficient storage"},
+	CodeShuttingDown:         {http.StatusServiceUnavailable, "Arrêt en cours", "Shutting down"},
+	CodeStreamingUnsupported: {http.StatusInternalServerError, "Streaming non supporté", "Streaming not supported"},
+
+	CodeVideoNotFound: {http.StatusNotFound, "Vidéo introuvable", "Video not found"},
+	CodeFileTooLarge:  {http.StatusRequestEntityTooLarge, "Fichier trop volumineux (max {max_mb} Mo)", "File too large (max {max_mb} MB)"},
+	CodeNoFile:        {http.StatusBadRequest, "Aucun fichier fourni", "No file provided"},
+	CodeNotAVideo:     {http.StatusUnsupportedMediaType, "Le fichier doit être une vidéo", "The file must be a video"},
+	CodeInvalidSince:  {http.StatusBadRequest, "Paramètre since invalide", "Invalid since parameter"},
+	CodeSinceTooOld: {http.StatusGone, "Historique des changements dépassé: recharger le catalogue",
+		"Change history exceeded: reload the catalog"},
+
+	CodeDownloadNotFound:     {http.StatusNotFound, "Téléchargement introuvable", "Download not found"},
+	CodeInvalidTransition:    {http.StatusConflict, "Changement d'état impossible", "Invalid state transition"},
+	CodeNotInCache:           {http.StatusNotFound, "Fichier absent du cache", "File not in cache"},
+	CodeCacheFull:            {http.StatusConflict, "Cache plein: pas assez de vidéos évinçables", "Cache full: not enough evictable videos"},
+	CodeUnknownFileSize:      {http.StatusConflict, "Taille du fichier inconnue", "Unknown file size"},
+	CodeMissingPosition:      {http.StatusBadRequest, "offset ou position/duration requis", "offset or position/duration required"},
+	CodeInvalidLimits:        {http.StatusBadRequest, "Limites invalides", "Invalid limits"},
+	CodeScrubRunning:         {http.StatusConflict, "Vérification du cache déjà en cours", "Cache scrub already running"},
+	CodeNotSeeding:           {http.StatusNotFound, "Fichier non partagé", "File not seeding"},
+	CodeSeedingPolicyReached: {http.StatusConflict, "Politique de seeding déjà atteinte", "Seeding policy already reached"},
+
+	CodeUnknownAction:    {http.StatusBadRequest, "Action inconnue", "Unknown action"},
+	CodeFileNotFound:     {http.StatusNotFound, "Fichier introuvable", "File not found"},
+	CodeFileNotAvailable: {http.StatusNotFound, "Fichier pas encore disponible chez ce peer", "File not yet available from this peer"},
+	CodeInvalidChunk:     {http.StatusBadRequest, "Numéro de chunk invalide", "Invalid chunk index"},
+	CodeReadError:        {http.StatusInternalServerError, "Erreur de lecture", "Read error"},
+	CodeNoUploadSlot:     {http.StatusServiceUnavailable, "Aucun slot d'envoi libre", "No free upload slot"},
+	CodeInvalidBitfield:  {http.StatusBadRequest, "Bitfield invalide", "Invalid bitfield"},
+	CodeUnknownPeer:      {http.StatusConflict, "Aucun bitfield échangé avec ce peer", "No bitfield exchanged with this peer"},
+	StatusChoked:         {http.StatusServiceUnavailable, "Le peer ne nous sert pas pour l'instant", "The peer is choking us"},
+}
+
+// ErrorCodes renvoie les codes connus, triés
+func ErrorCodes() []string {
+	codes := make([]string, 0, len(errorCatalog))
+	for code := range errorCatalog {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// ErrorStatus renvoie le statut HTTP d'un code (500 pour un code inconnu)
+func ErrorStatus(code string) int {
+	if info, ok := errorCatalog[code]; ok {
+		return info.status
+	}
+	return http.StatusInternalServerError
+}
+
+// ErrorMessage renvoie le message d'un code dans la langue demandée (fr ou en),
+// détails substitués. Un code inconnu est renvoyé tel quel.
+func ErrorMessage(code, lang string, details Details) string {
+	info, ok := errorCatalog[code]
+	if !ok {
+		return code
+	}
+	message := info.fr
+	if lang == LangEN {
+		message = info.en
+	}
+	for key, value := range details {
+		message = strings.ReplaceAll(message, "{"+key+"}", fmt.Sprint(value))
+	}
+	return message
+}
+
+// ============================================
+// ENVELOPPE D'ERREUR HTTP
+// ============================================
+
+// Details complète une erreur: valeurs substituées dans le message et renvoyées
+// telles quelles au client (reason porte la cause technique, non traduite)
+type Details map[string]interface{}
+
+// ErrorBody est le corps JSON de toute réponse d'erreur de l'API HTTP
+type ErrorBody struct {
+	Code      string  `json:"code"`
+	Status    int     `json:"status"`
+	Message   string  `json:"message"` // dans la langue de Accept-Language (fr par défaut)
+	Details   Details `json:"details,omitempty"`
+	RequestID string  `json:"request_id,omitempty"`
+}
+
+// NewErrorBody construit l'enveloppe d'un code dans une langue
+func NewErrorBody(code, lang string, details Details) ErrorBody {
+	return ErrorBody{
+		Code:    code,
+		Status:  ErrorStatus(code),
+		Message: ErrorMessage(code, lang, details),
+		Details: details,
+	}
+}
+
+// WriteError répond avec l'enveloppe d'erreur d'un code, message localisé d'après
+// Accept-Language. Le request_id est repris de l'en-tête X-Request-ID déjà posé.
+func WriteError(w http.ResponseWriter, r *http.Request, code string, details Details) {
+	lang := Language(r.Header.Get("Accept-Language"))
+	body := NewErrorBody(code, lang, details)
+	body.RequestID = w.Header().Get("X-Request-ID")
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Language", lang)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(body.Status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// NotFoundHandler répond not_found aux chemins qu'aucune route ne connaît
+func NotFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, CodeNotFound, nil)
+	})
+}
+
+// MethodNotAllowedHandler répond method_not_allowed à un chemin connu appelé
+// avec une autre méthode
+func MethodNotAllowedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, CodeMethodNotAllowed, Details{"method": r.Method})
+	})
+}
+
+// Language choisit fr ou en d'après un en-tête Accept-Language ("en-US,en;q=0.9,fr;q=0.8"):
+// la langue supportée de plus fort poids l'emporte, le français à défaut
+func Language(acceptLanguage string) string {
+	best, bestWeight := DefaultLanguage, 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+
+		lang, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if (lang == LangFR || lang == LangEN) && weight > bestWeight {
+			best, bestWeight = lang, weight
+		}
+	}
+	return best
+}
@@ -0,0 +1,130 @@
+package shared
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ============================================
+// ERREURS
+// ============================================
+
+// Erreurs à tester avec errors.Is, quel que soit le transport (HTTP ou P2P)
+var (
+	ErrNotFound       = errors.New("introuvable")
+	ErrConflict       = errors.New("conflit avec l'état courant")
+	ErrUnavailable    = errors.New("service indisponible")
+	ErrHistoryExpired = errors.New("historique des changements dépassé") // recharger le catalogue complet
+	ErrChoked         = errors.New("le peer nous a chokés")
+	ErrBusy           = errors.New("le peer n'a plus de slot d'envoi libre")
+)
+
+// APIError est une réponse d'erreur de l'API HTTP du serveur ou du daemon
+type APIError struct {
+	StatusCode int
+	Code       string // code machine stable (Code*), s'il est fourni
+	Message    string // message localisé d'après Accept-Language
+	Details    Details
+	RequestID  string
+}
+
+func (e *APIError) Error() string {
+	switch {
+	case e.Message == "":
+		return fmt.Sprintf("HTTP %d", e.StatusCode)
+	case e.Code == "" || e.Code == e.Message:
+		return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("HTTP %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Is rattache les codes HTTP aux erreurs génériques du package
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrUnavailable:
+		return e.StatusCode == http.StatusServiceUnavailable
+	case ErrHistoryExpired:
+		return e.StatusCode == http.StatusGone
+	}
+	return false
+}
+
+// readAPIError construit l'erreur d'une réponse non 2xx: enveloppe ErrorBody,
+// ancien corps {"error"} ou texte brut
+func readAPIError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	var envelope struct {
+		ErrorBody
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &envelope) == nil {
+		apiErr.Code = envelope.Code
+		if apiErr.Code == "" {
+			apiErr.Code = envelope.Error
+		}
+		apiErr.Message = envelope.Message
+		apiErr.Details = envelope.Details
+		apiErr.RequestID = envelope.RequestID
+		if apiErr.Message == "" {
+			apiErr.Message = apiErr.Code
+		}
+		return apiErr
+	}
+
+	apiErr.Message = strings.TrimSpace(string(body))
+	return apiErr
+}
+
+// P2PError est une réponse P2P en erreur ou un refus (choke)
+type P2PError struct {
+	Action  string
+	Code    string // code d'erreur (Code*), ou StatusChoked
+	Message string
+	Details Details
+}
+
+func (e *P2PError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("erreur distante (%s): %s", e.Action, e.Code)
+	}
+	return fmt.Sprintf("erreur distante (%s): %s (%s)", e.Action, e.Code, e.Message)
+}
+
+// Status renvoie le statut HTTP équivalent au code, pour relayer l'erreur sur l'API HTTP
+func (e *P2PError) Status() int {
+	return ErrorStatus(e.Code)
+}
+
+// Is rattache les codes du protocole aux erreurs génériques du package
+func (e *P2PError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Code == CodeFileNotFound || e.Code == CodeFileNotAvailable
+	case ErrBusy:
+		return e.Code == CodeNoUploadSlot
+	case ErrChoked:
+		return e.Code == StatusChoked
+	}
+	return false
+}
+
+// P2PErrorResponse construit la réponse P2P d'un code d'erreur, message en français
+// (pas d'Accept-Language sur le protocole P2P)
+func P2PErrorResponse(code string, details Details) P2PResponse {
+	return P2PResponse{
+		Status:  StatusError,
+		Error:   code,
+		Message: ErrorMessage(code, DefaultLanguage, details),
+		Details: details,
+	}
+}
@@ -0,0 +1,86 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ============================================
+// VÉRIFICATIONS DE SANTÉ
+// ============================================
+
+// HealthCheckTimeout borne la durée de chaque vérification de /health/ready
+const HealthCheckTimeout = 5 * time.Second
+
+// HealthCheck vérifie un composant: un état HealthOK ou HealthDegraded accompagné
+// d'un message, ou une erreur pour HealthFail
+type HealthCheck func(ctx context.Context) (string, string, error)
+
+// healthRank ordonne les états pour retenir le pire
+func healthRank(status string) int {
+	switch status {
+	case HealthOK:
+		return 0
+	case HealthDegraded:
+		return 1
+	}
+	return 2
+}
+
+// RunHealthChecks exécute les vérifications en parallèle; l'état global est le pire des composants
+func RunHealthChecks(ctx context.Context, checks map[string]HealthCheck) HealthReport {
+	report := HealthReport{
+		Status:    HealthOK,
+		Checks:    make(map[string]ComponentHealth, len(checks)),
+		CheckedAt: time.Now(),
+	}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check HealthCheck) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			status, message, err := check(checkCtx)
+			if err != nil {
+				status, message = HealthFail, err.Error()
+			}
+			result := ComponentHealth{
+				Status:    status,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+				Message:   message,
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			report.Checks[name] = result
+			if healthRank(status) > healthRank(report.Status) {
+				report.Status = status
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// WriteHealth répond 503 si un composant est en échec, 200 sinon
+func WriteHealth(w http.ResponseWriter, report HealthReport) {
+	code := http.StatusOK
+	if report.Status == HealthFail {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
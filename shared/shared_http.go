@@ -0,0 +1,232 @@
+package shared
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ============================================
+// CLIENT HTTP COMMUN
+// ============================================
+
+// Réglages par défaut des clients HTTP
+const (
+	DefaultRequestTimeout    = 30 * time.Second // durée max d'une requête hors flux
+	DefaultStreamIdleTimeout = 45 * time.Second // les serveurs envoient un keepalive toutes les 15 s
+)
+
+// apiClient porte ce qui est commun aux clients du serveur et du daemon
+type apiClient struct {
+	BaseURL           string
+	HTTPClient        *http.Client  // sans Timeout: il couperait aussi les flux SSE
+	Retry             RetryPolicy   // appliquée aux requêtes idempotentes (GET, PUT, DELETE)
+	Timeout           time.Duration // durée max d'une requête hors flux (0 = pas de limite)
+	StreamIdleTimeout time.Duration // flux SSE coupé sans aucune ligne reçue pendant cette durée
+	Language          string        // langue des messages d'erreur (Accept-Language), vide = français
+}
+
+func newAPIClient(baseURL string) apiClient {
+	return apiClient{
+		BaseURL:           strings.TrimRight(baseURL, "/"),
+		HTTPClient:        &http.Client{},
+		Retry:             DefaultRetryPolicy,
+		Timeout:           DefaultRequestTimeout,
+		StreamIdleTimeout: DefaultStreamIdleTimeout,
+	}
+}
+
+// do envoie une requête JSON et décode la réponse dans out (ignorée si nil ou 204).
+// Les en-têtes de la réponse sont renvoyés pour les métadonnées (X-Catalog-Seq...).
+func (c *apiClient) do(ctx context.Context, method, path string, in, out interface{}) (http.Header, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return nil, err
+		}
+	}
+
+	policy := c.Retry
+	if method == http.MethodPost || method == http.MethodPatch {
+		policy = NoRetry
+	}
+
+	var header http.Header
+	err := policy.Do(ctx, func(ctx context.Context) error {
+		if c.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+			defer cancel()
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		if in != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.Header.Set("Accept", "application/json")
+		c.setLanguage(req)
+
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		return decodeResponse(resp, out, &header)
+	})
+	return header, err
+}
+
+// setLanguage demande les messages d'erreur dans la langue du client
+func (c *apiClient) setLanguage(req *http.Request) {
+	if c.Language != "" {
+		req.Header.Set("Accept-Language", c.Language)
+	}
+}
+
+// decodeResponse décode une réponse 2xx dans out, ou construit l'erreur de l'API
+func decodeResponse(resp *http.Response, out interface{}, header *http.Header) error {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return readAPIError(resp)
+	}
+	*header = resp.Header
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("réponse invalide de %s: %w", resp.Request.URL.Path, err)
+	}
+	return nil
+}
+
+// ready interroge /health/ready. Un rapport en échec (503) est renvoyé avec
+// une erreur ErrUnavailable, pour que l'appelant puisse afficher les composants.
+func (c *apiClient) ready(ctx context.Context) (*HealthReport, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/health/ready", nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setLanguage(req)
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil, readAPIError(resp)
+	}
+
+	var report HealthReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return nil, fmt.Errorf("rapport de santé invalide: %w", err)
+	}
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		return &report, &APIError{StatusCode: resp.StatusCode, Message: "pas prêt: " + report.Status}
+	}
+	return &report, nil
+}
+
+// ============================================
+// FLUX SERVER-SENT EVENTS
+// ============================================
+
+// sseEvent est un événement SSE brut
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// sseStream lit un flux SSE; le flux est coupé s'il reste muet plus de idleTimeout
+type sseStream struct {
+	body     io.ReadCloser
+	scanner  *bufio.Scanner
+	cancel   context.CancelFunc
+	watchdog *time.Timer
+	idle     time.Duration
+}
+
+// openSSE ouvre un flux SSE (GET, sans nouvelle tentative: l'appelant se reconnecte)
+func (c *apiClient) openSSE(ctx context.Context, path string) (*sseStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	c.setLanguage(req)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		cancel()
+		return nil, readAPIError(resp)
+	}
+
+	stream := &sseStream{body: resp.Body, cancel: cancel, idle: c.StreamIdleTimeout}
+	stream.scanner = bufio.NewScanner(resp.Body)
+	stream.scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	if stream.idle > 0 {
+		stream.watchdog = time.AfterFunc(stream.idle, cancel)
+	}
+	return stream, nil
+}
+
+// next renvoie l'événement suivant; les commentaires (keepalive) sont ignorés
+func (s *sseStream) next() (sseEvent, error) {
+	var ev sseEvent
+	for s.scanner.Scan() {
+		if s.watchdog != nil {
+			s.watchdog.Reset(s.idle)
+		}
+		line := s.scanner.Text()
+		switch {
+		case line == "":
+			if ev.Event != "" || ev.Data != "" {
+				return ev, nil
+			}
+		case strings.HasPrefix(line, ":"):
+			// Commentaire
+		case strings.HasPrefix(line, "id:"):
+			ev.ID = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "event:"):
+			ev.Event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			ev.Data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+	if err := s.scanner.Err(); err != nil {
+		return ev, err
+	}
+	return ev, io.ErrUnexpectedEOF
+}
+
+// Close ferme le flux
+func (s *sseStream) Close() error {
+	if s.watchdog != nil {
+		s.watchdog.Stop()
+	}
+	s.cancel()
+	return s.body.Close()
+}
@@ -0,0 +1,184 @@
+package shared
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================
+// JOURNALISATION
+// ============================================
+
+// LogConfig règle le format et les niveaux des logs
+type LogConfig struct {
+	Format string            `yaml:"format" json:"format"` // text ou json
+	Level  string            `yaml:"level" json:"level"`   // debug, info, warn ou error
+	Levels map[string]string `yaml:"levels" json:"levels"` // niveau propre à un sous-système (p2p: debug...)
+}
+
+// ParseLogLevel convertit un niveau (debug, info, warn, error)
+func ParseLogLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(name))
+	return level, err
+}
+
+// ValidateLogConfig vérifie le format, les niveaux et les noms de sous-systèmes
+func ValidateLogConfig(conf LogConfig, subsystems []string) []error {
+	var errs []error
+	if conf.Format != "text" && conf.Format != "json" {
+		errs = append(errs, fmt.Errorf("log.format inconnu: %q (text ou json)", conf.Format))
+	}
+	if _, err := ParseLogLevel(conf.Level); err != nil {
+		errs = append(errs, fmt.Errorf("log.level invalide: %q", conf.Level))
+	}
+	for subsystem, level := range conf.Levels {
+		if !containsString(subsystems, subsystem) {
+			errs = append(errs, fmt.Errorf("log.levels: sous-système inconnu %q (%s)", subsystem, strings.Join(subsystems, ", ")))
+		}
+		if _, err := ParseLogLevel(level); err != nil {
+			errs = append(errs, fmt.Errorf("log.levels.%s invalide: %q", subsystem, level))
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errs
+}
+
+// SubsystemLoggers renvoie de quoi créer le logger de chaque sous-système, sur la
+// sortie d'erreur et au niveau que lui donne une configuration déjà validée
+func SubsystemLoggers(conf LogConfig) func(subsystem string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug} // le filtrage se fait par sous-système
+	var output slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+	if conf.Format == "json" {
+		output = slog.NewJSONHandler(os.Stderr, opts)
+	}
+
+	return func(subsystem string) *slog.Logger {
+		name := conf.Level
+		if override, ok := conf.Levels[subsystem]; ok {
+			name = override
+		}
+		level, err := ParseLogLevel(name)
+		if err != nil {
+			level = slog.LevelInfo
+		}
+		return slog.New(levelHandler{Handler: output, level: level}).With("subsystem", subsystem)
+	}
+}
+
+// levelHandler applique le niveau minimal d'un sous-système
+type levelHandler struct {
+	slog.Handler
+	level slog.Level
+}
+
+func (h levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
+
+// NewCorrelationID génère un identifiant court pour suivre une requête ou un stream dans les logs
+func NewCorrelationID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ============================================
+// CORRÉLATION DES REQUÊTES HTTP
+// ============================================
+
+type loggerKey struct{}
+
+// RequestLogger renvoie le logger de la requête, porteur de son request_id
+// (fallback hors de WithRequestLogging)
+func RequestLogger(r *http.Request, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := r.Context().Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return fallback
+}
+
+// WithRequestLogging attribue un request_id à chaque requête (repris de X-Request-ID s'il
+// est fourni), le renvoie dans la réponse et journalise la requête une fois servie
+func WithRequestLogging(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > 64 {
+			id = NewCorrelationID()
+		}
+		w.Header().Set("X-Request-ID", id)
+
+		logger := logger.With("request_id", id)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), loggerKey{}, logger)))
+
+		level := slog.LevelDebug
+		switch {
+		case rec.status >= 500:
+			level = slog.LevelError
+		case rec.status >= 400:
+			level = slog.LevelInfo
+		}
+		logger.Log(r.Context(), level, "requête HTTP",
+			"method", r.Method, "path", r.URL.Path, "status", rec.status,
+			"bytes", rec.bytes, "duration", time.Since(start))
+	})
+}
+
+// statusRecorder retient le statut et la taille d'une réponse
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(data []byte) (int, error) {
+	n, err := rec.ResponseWriter.Write(data)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Flush garde le streaming SSE possible à travers le middleware
+func (rec *statusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap permet à http.ResponseController d'atteindre la réponse d'origine
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,126 @@
+package shared
+
+import (
+	"log/slog"
+	"time"
+
+	p2pmetrics "github.com/libp2p/go-libp2p/core/metrics"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ============================================
+// REQUÊTES P2P ENTRANTES
+// ============================================
+
+// P2PCall est un stream P2P entrant dont on retient le résultat pour les métriques,
+// avec un logger porteur de son stream_id
+type P2PCall struct {
+	network.Stream
+	Result string // code d'erreur envoyé (choked compris), vide en cas de succès
+	Log    *slog.Logger
+}
+
+// StreamLogger renvoie le logger d'un stream P2P entrant (fallback hors d'un P2PCall)
+func StreamLogger(stream network.Stream, fallback *slog.Logger) *slog.Logger {
+	if call, ok := stream.(*P2PCall); ok && call.Log != nil {
+		return call.Log
+	}
+	return fallback
+}
+
+// SetP2PResult retient le résultat d'une requête P2P (sans effet hors d'un P2PCall)
+func SetP2PResult(stream network.Stream, result string) {
+	if call, ok := stream.(*P2PCall); ok {
+		call.Result = result
+	}
+}
+
+// P2PMetrics regroupe les métriques des requêtes P2P servies par un nœud
+type P2PMetrics struct {
+	Requests      *prometheus.CounterVec
+	ChunkServe    prometheus.Histogram
+	ActiveStreams prometheus.Gauge
+	actions       []string // actions comptées sous leur nom, les autres sous "unknown"
+}
+
+// NewP2PMetrics crée les métriques P2P pour les actions que le nœud sert
+func NewP2PMetrics(actions ...string) *P2PMetrics {
+	return &P2PMetrics{
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pipbingo_p2p_requests_total",
+			Help: "Requêtes P2P reçues, par action et résultat (success ou code d'erreur).",
+		}, []string{"action", "result"}),
+		ChunkServe: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "pipbingo_chunk_serve_seconds",
+			Help:    "Temps de service d'un chunk (lecture et envoi, limite de débit comprise).",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 14), // 1 ms à ~8 s
+		}),
+		ActiveStreams: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "pipbingo_p2p_active_streams",
+			Help: "Streams P2P entrants en cours de traitement.",
+		}),
+		actions: actions,
+	}
+}
+
+// Collectors renvoie les métriques à enregistrer
+func (m *P2PMetrics) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.Requests, m.ChunkServe, m.ActiveStreams}
+}
+
+// Observe comptabilise une requête P2P traitée
+func (m *P2PMetrics) Observe(action string, call *P2PCall, start time.Time) {
+	if !containsString(m.actions, action) {
+		action = "unknown" // borne le nombre de séries
+	}
+	result := call.Result
+	if result == "" {
+		result = StatusSuccess
+	}
+
+	m.Requests.WithLabelValues(action, result).Inc()
+	if action == ActionRequestFile && call.Result == "" {
+		m.ChunkServe.Observe(time.Since(start).Seconds())
+	}
+}
+
+// ============================================
+// BANDE PASSANTE LIBP2P
+// ============================================
+
+// bandwidthCollector lit les compteurs de bande passante de libp2p à chaque scrape
+type bandwidthCollector struct {
+	counter *p2pmetrics.BandwidthCounter
+	bytes   *prometheus.Desc
+	rate    *prometheus.Desc
+}
+
+// NewBandwidthCollector expose les compteurs passés à libp2p.BandwidthReporter
+func NewBandwidthCollector(counter *p2pmetrics.BandwidthCounter) prometheus.Collector {
+	return bandwidthCollector{
+		counter: counter,
+		bytes: prometheus.NewDesc("pipbingo_p2p_bytes_total",
+			"Octets échangés par le nœud libp2p, par protocole et direction.",
+			[]string{"protocol", "direction"}, nil),
+		rate: prometheus.NewDesc("pipbingo_p2p_bytes_per_second",
+			"Débit courant du nœud libp2p, par direction.",
+			[]string{"direction"}, nil),
+	}
+}
+
+func (c bandwidthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.bytes
+	ch <- c.rate
+}
+
+func (c bandwidthCollector) Collect(ch chan<- prometheus.Metric) {
+	for proto, stats := range c.counter.GetBandwidthByProtocol() {
+		ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.CounterValue, float64(stats.TotalIn), string(proto), "in")
+		ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.CounterValue, float64(stats.TotalOut), string(proto), "out")
+	}
+
+	totals := c.counter.GetBandwidthTotals()
+	ch <- prometheus.MustNewConstMetric(c.rate, prometheus.GaugeValue, totals.RateIn, "in")
+	ch <- prometheus.MustNewConstMetric(c.rate, prometheus.GaugeValue, totals.RateOut, "out")
+}
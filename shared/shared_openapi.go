@@ -0,0 +1,334 @@
+package shared
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================
+// SPÉCIFICATION OPENAPI
+// ============================================
+
+// APIRoute documente une route HTTP. Les schémas des corps JSON sont déduits
+// par réflexion des types Go (tags json): ils suivent les types sans effort.
+type APIRoute struct {
+	Method      string
+	Path        string // gabarit du routeur: /videos/{id}
+	Tag         string
+	Summary     string
+	Query       []APIParam
+	Request     interface{} // valeur du type du corps JSON attendu, nil = pas de corps
+	Form        []APIParam  // champs d'un corps multipart/form-data (Type "file" pour un fichier)
+	Response    interface{} // valeur du type de la réponse JSON, nil = voir ContentType
+	ContentType string      // réponse non JSON: text/plain, text/event-stream, video/*...
+	Status      int         // code de succès (200 par défaut)
+	Errors      []int       // codes d'erreur possibles
+}
+
+// APIParam documente un paramètre de requête (?since=12)
+type APIParam struct {
+	Name        string
+	Type        string // string, integer, boolean (file dans un formulaire)
+	Description string
+	Required    bool
+}
+
+// OpenAPISpec construit le document OpenAPI 3 de routes
+func OpenAPISpec(title, description string, routes []APIRoute) map[string]interface{} {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]map[string]interface{})
+
+	for _, route := range routes {
+		item, ok := paths[route.Path]
+		if !ok {
+			item = make(map[string]interface{})
+			paths[route.Path] = item
+		}
+		item[strings.ToLower(route.Method)] = route.operation(schemas)
+	}
+
+	errorSchema(schemas)
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       title,
+			"description": description,
+			"version":     "1.0.0",
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+// operation décrit la route au format OpenAPI
+func (route APIRoute) operation(schemas map[string]interface{}) map[string]interface{} {
+	op := map[string]interface{}{
+		"summary":     route.Summary,
+		"operationId": operationID(route.Method, route.Path),
+	}
+	if route.Tag != "" {
+		op["tags"] = []string{route.Tag}
+	}
+
+	var params []interface{}
+	for _, name := range pathParams(route.Path) {
+		params = append(params, map[string]interface{}{
+			"name": name, "in": "path", "required": true,
+			"schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, param := range route.Query {
+		kind := param.Type
+		if kind == "" {
+			kind = "string"
+		}
+		params = append(params, map[string]interface{}{
+			"name": param.Name, "in": "query", "required": param.Required,
+			"description": param.Description,
+			"schema":      map[string]interface{}{"type": kind},
+		})
+	}
+	if len(route.Errors) > 0 {
+		params = append(params, map[string]interface{}{
+			"name": "Accept-Language", "in": "header", "required": false,
+			"description": "langue du message d'erreur: fr (défaut) ou en",
+			"schema":      map[string]interface{}{"type": "string"},
+		})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if route.Request != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  jsonContent(schemaOf(reflect.TypeOf(route.Request), schemas)),
+		}
+	}
+
+	if len(route.Form) > 0 {
+		properties := make(map[string]interface{})
+		var required []string
+		for _, field := range route.Form {
+			schema := map[string]interface{}{"type": field.Type, "description": field.Description}
+			if field.Type == "file" {
+				schema["type"], schema["format"] = "string", "binary"
+			}
+			properties[field.Name] = schema
+			if field.Required {
+				required = append(required, field.Name)
+			}
+		}
+		form := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			form["required"] = required
+		}
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{"multipart/form-data": map[string]interface{}{"schema": form}},
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	switch {
+	case route.Response != nil:
+		success["content"] = jsonContent(schemaOf(reflect.TypeOf(route.Response), schemas))
+	case route.ContentType != "":
+		success["content"] = map[string]interface{}{route.ContentType: map[string]interface{}{}}
+	}
+	responses := map[string]interface{}{strconv.Itoa(status): success}
+	for _, code := range route.Errors {
+		responses[strconv.Itoa(code)] = map[string]interface{}{
+			"description": http.StatusText(code),
+			"content":     jsonContent(schemaOf(reflect.TypeOf(ErrorBody{}), schemas)),
+		}
+	}
+	op["responses"] = responses
+
+	return op
+}
+
+// errorSchema déclare l'enveloppe d'erreur, codes connus énumérés
+func errorSchema(schemas map[string]interface{}) {
+	schemaOf(reflect.TypeOf(ErrorBody{}), schemas)
+	body := schemas[schemaName(reflect.TypeOf(ErrorBody{}))].(map[string]interface{})
+	properties := body["properties"].(map[string]interface{})
+	properties["code"] = map[string]interface{}{"type": "string", "enum": ErrorCodes()}
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+// pathParams renvoie les variables d'un gabarit ({id}, {filename:.*})
+func pathParams(path string) []string {
+	var names []string
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			name, _, _ := strings.Cut(part[1:len(part)-1], ":")
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// operationID dérive un identifiant stable: GET /videos/{id} donne get_videos_id
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '-' || r == '.' }) {
+		name, _, _ := strings.Cut(strings.Trim(part, "{}"), ":")
+		id += "_" + name
+	}
+	return id
+}
+
+// ============================================
+// SCHÉMAS DÉDUITS DES TYPES GO
+// ============================================
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaOf renvoie le schéma d'un type; les structures nommées sont déclarées
+// une fois dans schemas et référencées par $ref
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]interface{}{"type": "integer", "format": "int64", "description": "nanosecondes"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"} // base64
+		}
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		name := schemaName(t)
+		if _, ok := schemas[name]; !ok {
+			schemas[name] = map[string]interface{}{} // réservé: types récursifs
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+// schemaName nomme un schéma d'après son type Go (seedingFileView donne SeedingFileView)
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// structSchema décrit les champs JSON d'une structure, champs embarqués compris
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	collectFields(t, schemas, properties, &required)
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// collectFields ajoute les champs de t; un champ déjà vu (moins profond) garde la priorité,
+// comme pour encoding/json
+func collectFields(t reflect.Type, schemas map[string]interface{}, properties map[string]interface{}, required *[]string) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				embedded = append(embedded, fieldType)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if _, seen := properties[name]; seen {
+			continue
+		}
+
+		properties[name] = schemaOf(field.Type, schemas)
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			*required = append(*required, name)
+		}
+	}
+
+	for _, fieldType := range embedded {
+		collectFields(fieldType, schemas, properties, required)
+	}
+}
+
+// ============================================
+// COUVERTURE DES ROUTES
+// ============================================
+
+// Documented indique si une route du routeur figure dans routes. Une route sans
+// méthode (PathPrefix, method vide) est couverte par un chemin documenté sous son préfixe.
+func Documented(routes []APIRoute, method, path string) bool {
+	for _, route := range routes {
+		if method == "" {
+			if strings.HasPrefix(route.Path, path) {
+				return true
+			}
+			continue
+		}
+		if route.Path == path && strings.EqualFold(route.Method, method) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// ============================================
+// CLIENT DU PROTOCOLE P2P
+// ============================================
+
+// P2PClient envoie des requêtes du protocole ProtocolID à un peer (serveur ou daemon)
+type P2PClient struct {
+	Host host.Host
+
+	// WrapStream, si défini, enveloppe chaque stream ouvert (limitation de débit, métriques...)
+	WrapStream func(network.Stream) network.Stream
+}
+
+// NewP2PClient crée un client P2P à partir d'un nœud libp2p déjà démarré
+func NewP2PClient(h host.Host) *P2PClient {
+	return &P2PClient{Host: h}
+}
+
+// Do envoie une requête sur un nouveau stream et attend la réponse. L'échéance et
+// l'annulation du contexte s'appliquent à tout l'échange. Une réponse error ou choked
+// donne un *P2PError, renvoyé avec la réponse.
+func (c *P2PClient) Do(ctx context.Context, peerID peer.ID, request P2PRequest) (*P2PResponse, error) {
+	stream, err := c.Host.NewStream(ctx, peerID, protocol.ID(ProtocolID))
+	if err != nil {
+		return nil, fmt.Errorf("impossible d'ouvrir le stream: %w", err)
+	}
+	defer stream.Close()
+	if c.WrapStream != nil {
+		stream = c.WrapStream(stream)
+	}
+
+	// Respecter l'échéance et l'annulation du contexte pendant l'échange
+	if deadline, ok := ctx.Deadline(); ok {
+		stream.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { stream.Reset() })
+	defer stop()
+
+	if err := json.NewEncoder(stream).Encode(request); err != nil {
+		return nil, fmt.Errorf("erreur envoi requête %s: %w", request.Action, err)
+	}
+
+	var response P2PResponse
+	if err := json.NewDecoder(stream).Decode(&response); err != nil {
+		return nil, fmt.Errorf("erreur lecture réponse %s: %w", request.Action, err)
+	}
+
+	switch response.Status {
+	case StatusError:
+		return &response, &P2PError{Action: request.Action, Code: response.Error,
+			Message: response.Message, Details: response.Details}
+	case StatusChoked:
+		return &response, &P2PError{Action: request.Action, Code: StatusChoked,
+			Message: ErrorMessage(StatusChoked, DefaultLanguage, nil)}
+	}
+	return &response, nil
+}
+
+// Manifest demande le manifest d'un fichier
+func (c *P2PClient) Manifest(ctx context.Context, peerID peer.ID, filename string) (*Manifest, error) {
+	response, err := c.Do(ctx, peerID, P2PRequest{Action: ActionGetManifest, Filename: filename})
+	if err != nil {
+		return nil, err
+	}
+	if response.Manifest == nil || response.Manifest.Filename != filename {
+		return nil, fmt.Errorf("manifest invalide pour %s", filename)
+	}
+	if err := response.Manifest.Validate(); err != nil {
+		return nil, fmt.Errorf("manifest invalide pour %s: %w", filename, err)
+	}
+	return response.Manifest, nil
+}
+
+// Chunk demande un chunk et vérifie son empreinte si manifest est fourni
+func (c *P2PClient) Chunk(ctx context.Context, peerID peer.ID, filename string, index int, manifest *Manifest) ([]byte, error) {
+	response, err := c.Do(ctx, peerID, P2PRequest{Action: ActionRequestFile, Filename: filename, ChunkIndex: index})
+	if err != nil {
+		return nil, err
+	}
+	if manifest != nil {
+		if err := manifest.VerifyChunk(index, response.ChunkData); err != nil {
+			return nil, err
+		}
+	}
+	return response.ChunkData, nil
+}
+
+// Announce inscrit le nœud au swarm d'un fichier auprès du tracker et renvoie les autres peers
+func (c *P2PClient) Announce(ctx context.Context, tracker peer.ID, filename string) ([]PeerInfo, error) {
+	response, err := c.Do(ctx, tracker, P2PRequest{Action: ActionAnnounce, Filename: filename})
+	if err != nil {
+		return nil, err
+	}
+	return response.Peers, nil
+}
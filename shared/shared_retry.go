@@ -0,0 +1,73 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ============================================
+// NOUVELLES TENTATIVES
+// ============================================
+
+// RetryPolicy règle les nouvelles tentatives d'une requête idempotente
+// après une erreur réseau ou une réponse 429, 502, 503 ou 504
+type RetryPolicy struct {
+	MaxAttempts    int           // tentatives au total (1 = pas de nouvelle tentative)
+	InitialBackoff time.Duration // attente avant la 2e tentative, doublée ensuite
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy est la politique des clients créés par NewServerClient et NewDaemonClient
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+}
+
+// NoRetry désactive les nouvelles tentatives
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// Do exécute fn jusqu'à son succès, une erreur définitive, l'épuisement des tentatives
+// ou l'annulation du contexte
+func (p RetryPolicy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	backoff := p.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= p.MaxAttempts || !Retryable(err) {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		if backoff *= 2; p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+// Retryable indique si une erreur est passagère: coupure réseau, serveur surchargé ou en redémarrage
+func Retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
@@ -0,0 +1,213 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strconv"
+)
+
+// ============================================
+// CLIENT DE L'API DU SERVEUR
+// ============================================
+
+// ServerClient appelle l'API HTTP du serveur (catalogue, uploads, changements)
+type ServerClient struct {
+	apiClient
+}
+
+// NewServerClient crée un client pour le serveur joignable à baseURL (http://localhost:8080)
+func NewServerClient(baseURL string) *ServerClient {
+	return &ServerClient{apiClient: newAPIClient(baseURL)}
+}
+
+// List renvoie le catalogue et le numéro du dernier changement qu'il inclut,
+// à passer ensuite à Changes ou OpenChanges
+func (c *ServerClient) List(ctx context.Context) ([]*Video, uint64, error) {
+	var videos []*Video
+	header, err := c.do(ctx, http.MethodGet, "/list", nil, &videos)
+	if err != nil {
+		return nil, 0, err
+	}
+	seq, err := strconv.ParseUint(header.Get("X-Catalog-Seq"), 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("X-Catalog-Seq invalide: %w", err)
+	}
+	return videos, seq, nil
+}
+
+// Video renvoie une vidéo du catalogue
+func (c *ServerClient) Video(ctx context.Context, id string) (*Video, error) {
+	var video Video
+	if _, err := c.do(ctx, http.MethodGet, "/videos/"+url.PathEscape(id), nil, &video); err != nil {
+		return nil, err
+	}
+	return &video, nil
+}
+
+// PeerInfo renvoie l'identité P2P du serveur
+func (c *ServerClient) PeerInfo(ctx context.Context) (*ServerPeerInfo, error) {
+	var info ServerPeerInfo
+	if _, err := c.do(ctx, http.MethodGet, "/peer-info", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Ready renvoie l'état de santé détaillé du serveur
+func (c *ServerClient) Ready(ctx context.Context) (*HealthReport, error) {
+	return c.ready(ctx)
+}
+
+// ============================================
+// UPLOAD
+// ============================================
+
+// UploadRequest décrit une vidéo à uploader
+type UploadRequest struct {
+	Filename    string    // nom d'origine, pour l'extension
+	ContentType string    // video/mp4 par défaut
+	Body        io.Reader // contenu, envoyé en flux sans être chargé en mémoire
+	Title       string
+	Description string
+	Creator     string
+}
+
+// Upload envoie une vidéo au serveur; elle est renvoyée à l'état processing.
+// L'upload n'est jamais retenté: le corps n'est lisible qu'une fois.
+func (c *ServerClient) Upload(ctx context.Context, upload UploadRequest) (*Video, error) {
+	contentType := upload.ContentType
+	if contentType == "" {
+		contentType = "video/mp4"
+	}
+
+	reader, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		err := writeUploadForm(form, upload, contentType)
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/upload", reader)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Accept", "application/json")
+	c.setLanguage(req)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var video Video
+	var header http.Header
+	if err := decodeResponse(resp, &video, &header); err != nil {
+		return nil, err
+	}
+	return &video, nil
+}
+
+// writeUploadForm écrit les champs du formulaire puis le fichier
+func writeUploadForm(form *multipart.Writer, upload UploadRequest, contentType string) error {
+	for name, value := range map[string]string{
+		"title":       upload.Title,
+		"description": upload.Description,
+		"creator":     upload.Creator,
+	} {
+		if err := form.WriteField(name, value); err != nil {
+			return err
+		}
+	}
+
+	part := make(textproto.MIMEHeader)
+	part.Set("Content-Disposition", fmt.Sprintf(`form-data; name="video"; filename=%q`, filepath.Base(upload.Filename)))
+	part.Set("Content-Type", contentType)
+	file, err := form.CreatePart(part)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, upload.Body)
+	return err
+}
+
+// ============================================
+// CHANGEMENTS DU CATALOGUE
+// ============================================
+
+// Changes renvoie les changements postérieurs à since. ErrHistoryExpired (errors.Is)
+// signale que l'historique ne remonte plus jusque-là: recharger le catalogue avec List.
+func (c *ServerClient) Changes(ctx context.Context, since uint64) (*ChangesPage, error) {
+	var page ChangesPage
+	if _, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/changes?since=%d", since), nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// ChangeEvent est un événement du flux de changements: un changement, ou un Reset
+// quand l'historique du serveur ne remonte plus jusqu'au numéro demandé
+type ChangeEvent struct {
+	Reset  bool
+	Seq    uint64
+	Change CatalogChange
+}
+
+// ChangeStream est le flux temps réel des changements du catalogue
+type ChangeStream struct {
+	sse *sseStream
+}
+
+// OpenChanges s'abonne aux changements postérieurs à since. Le flux est coupé
+// si le serveur reste muet plus de StreamIdleTimeout; se réabonner avec le dernier Seq reçu.
+func (c *ServerClient) OpenChanges(ctx context.Context, since uint64) (*ChangeStream, error) {
+	sse, err := c.openSSE(ctx, fmt.Sprintf("/changes/stream?since=%d", since))
+	if err != nil {
+		return nil, err
+	}
+	return &ChangeStream{sse: sse}, nil
+}
+
+// Next attend le changement suivant
+func (s *ChangeStream) Next() (ChangeEvent, error) {
+	for {
+		raw, err := s.sse.next()
+		if err != nil {
+			return ChangeEvent{}, err
+		}
+
+		if raw.Event == "reset" {
+			var reset struct {
+				Seq uint64 `json:"seq"`
+			}
+			if err := json.Unmarshal([]byte(raw.Data), &reset); err != nil {
+				return ChangeEvent{}, fmt.Errorf("reset illisible: %w", err)
+			}
+			return ChangeEvent{Reset: true, Seq: reset.Seq}, nil
+		}
+
+		var change CatalogChange
+		if err := json.Unmarshal([]byte(raw.Data), &change); err != nil {
+			// Événement inconnu ou corrompu: passer au suivant
+			continue
+		}
+		return ChangeEvent{Seq: change.Seq, Change: change}, nil
+	}
+}
+
+// Close ferme le flux
+func (s *ChangeStream) Close() error {
+	return s.sse.Close()
+}
@@ -0,0 +1,101 @@
+package shared
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================
+// RÉGLAGES (ENVIRONNEMENT ET FLAGS)
+// ============================================
+
+// SetValue convertit une valeur texte (environnement, flag) vers le type du champ
+func SetValue(target interface{}, value string) error {
+	switch p := target.(type) {
+	case *string:
+		*p = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*p = n
+	case *int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*p = n
+	case *float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		*p = f
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*p = b
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*p = d
+	case *[]string:
+		*p = nil
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*p = append(*p, item)
+			}
+		}
+	case *map[string]string:
+		*p = make(map[string]string)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			key, val, ok := strings.Cut(item, "=")
+			if !ok {
+				return fmt.Errorf("clé=valeur attendu: %q", item)
+			}
+			(*p)[strings.TrimSpace(key)] = strings.TrimSpace(val)
+		}
+	default:
+		return fmt.Errorf("type de réglage non supporté: %T", target)
+	}
+	return nil
+}
+
+// FormatValue affiche la valeur par défaut d'un réglage dans l'aide des flags
+func FormatValue(target interface{}) string {
+	switch p := target.(type) {
+	case *string:
+		return *p
+	case *int:
+		return strconv.Itoa(*p)
+	case *int64:
+		return strconv.FormatInt(*p, 10)
+	case *float64:
+		return strconv.FormatFloat(*p, 'g', -1, 64)
+	case *bool:
+		return strconv.FormatBool(*p)
+	case *time.Duration:
+		return p.String()
+	case *[]string:
+		return strings.Join(*p, ",")
+	case *map[string]string:
+		items := make([]string, 0, len(*p))
+		for key, val := range *p {
+			items = append(items, key+"="+val)
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	}
+	return ""
+}
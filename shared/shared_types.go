@@ -0,0 +1,216 @@
+// Package shared regroupe les types échangés par le serveur, le daemon et leurs clients
+// (API HTTP et protocole P2P), des clients typés pour chacun d'eux et le socle commun
+// des deux binaires (santé, logs, métriques, réglages).
+// Le serveur et le daemon s'appuient sur ces mêmes types: ils ne peuvent pas diverger.
+package shared
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// ============================================
+// CATALOGUE (API DU SERVEUR)
+// ============================================
+
+// Video représente une vidéo dans le catalogue
+type Video struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Filename    string    `json:"filename"`
+	Thumbnail   string    `json:"thumbnail"`
+	Duration    int       `json:"duration"` // en secondes
+	Size        int64     `json:"size"`     // en octets
+	Creator     string    `json:"creator"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+	Status      string    `json:"status"` // processing, ready
+}
+
+// États d'une vidéo du catalogue
+const (
+	VideoProcessing = "processing" // uploadée, manifest en cours de calcul
+	VideoReady      = "ready"      // prête à être téléchargée en P2P
+)
+
+// Types de changements du catalogue
+const (
+	ChangeCreated   = "created"
+	ChangeUpdated   = "updated"
+	ChangeDeleted   = "deleted"
+	ChangeProcessed = "processed" // traitement terminé, la vidéo est prête
+)
+
+// CatalogChange est un événement du catalogue, numéroté dans l'ordre où il s'est produit
+type CatalogChange struct {
+	Seq     uint64    `json:"seq"`
+	Type    string    `json:"type"`
+	VideoID string    `json:"video_id"`
+	Video   *Video    `json:"video,omitempty"` // état de la vidéo après le changement (absent si supprimée)
+	At      time.Time `json:"at"`
+}
+
+// ChangesPage est la réponse de GET /changes?since=N
+type ChangesPage struct {
+	Seq     uint64          `json:"seq"` // dernier changement connu du serveur
+	Changes []CatalogChange `json:"changes"`
+}
+
+// ServerPeerInfo est la réponse de GET /peer-info
+type ServerPeerInfo struct {
+	PeerID string   `json:"peer_id"`
+	Addrs  []string `json:"addrs"`
+	Peers  int      `json:"peers"` // peers connectés au serveur
+}
+
+// ============================================
+// PROTOCOLE P2P
+// ============================================
+
+// ProtocolID est l'identifiant libp2p du protocole d'échange de chunks
+const ProtocolID = "/pipbingo/get/1.0.0"
+
+// Actions du protocole P2P
+const (
+	ActionRequestFile = "request_file" // un chunk
+	ActionGetManifest = "get_manifest" // empreintes des chunks
+	ActionAnnounce    = "announce"     // inscription au swarm d'un fichier (tracker du serveur)
+	ActionBitfield    = "bitfield"     // échange initial des chunks possédés (entre daemons)
+	ActionHave        = "have"         // nouveau chunk disponible (entre daemons)
+)
+
+// Statuts d'une réponse P2P
+const (
+	StatusSuccess = "success"
+	StatusError   = "error"
+	StatusChoked  = "choked" // le peer ne nous sert pas pour l'instant
+)
+
+// P2PRequest représente une requête P2P
+type P2PRequest struct {
+	Action     string `json:"action"`
+	Filename   string `json:"filename"`
+	ChunkIndex int    `json:"chunk_index"`
+	Bitfield   []byte `json:"bitfield,omitempty"`
+}
+
+// P2PResponse représente la réponse P2P
+type P2PResponse struct {
+	Status      string     `json:"status"` // success, error, choked
+	ChunkData   []byte     `json:"chunk_data,omitempty"`
+	ChunkIndex  int        `json:"chunk_index"`
+	TotalChunks int        `json:"total_chunks"`
+	Manifest    *Manifest  `json:"manifest,omitempty"`
+	Bitfield    []byte     `json:"bitfield,omitempty"`
+	Peers       []PeerInfo `json:"peers,omitempty"`
+	Error       string     `json:"error,omitempty"`   // code d'erreur (Code*)
+	Message     string     `json:"message,omitempty"` // message du code, en français
+	Details     Details    `json:"details,omitempty"`
+}
+
+// PeerInfo décrit un peer joignable pour un fichier (réponse du tracker)
+type PeerInfo struct {
+	ID    string   `json:"id"`
+	Addrs []string `json:"addrs"`
+}
+
+// Manifest décrit le découpage d'un fichier en chunks et l'empreinte de chacun.
+// Les clients s'en servent pour vérifier chaque chunk reçu et reprendre
+// un téléchargement interrompu.
+type Manifest struct {
+	Filename    string   `json:"filename"`
+	Size        int64    `json:"size"`
+	ChunkSize   int      `json:"chunk_size"`
+	ChunkHashes []string `json:"chunk_hashes"` // SHA-256 hexadécimal de chaque chunk
+}
+
+// TotalChunks renvoie le nombre de chunks décrits par le manifest
+func (m *Manifest) TotalChunks() int {
+	return len(m.ChunkHashes)
+}
+
+// ChunkLength renvoie la taille attendue du chunk i (le dernier peut être plus court)
+func (m *Manifest) ChunkLength(i int) int64 {
+	offset := int64(i) * int64(m.ChunkSize)
+	if remaining := m.Size - offset; remaining < int64(m.ChunkSize) {
+		return remaining
+	}
+	return int64(m.ChunkSize)
+}
+
+// Validate vérifie qu'un manifest reçu d'un peer ou relu du disque est cohérent:
+// taille de chunk positive, une empreinte SHA-256 hexadécimale par chunk
+func (m *Manifest) Validate() error {
+	if m.ChunkSize <= 0 {
+		return fmt.Errorf("taille de chunk invalide: %d", m.ChunkSize)
+	}
+	if m.Size < 0 {
+		return fmt.Errorf("taille invalide: %d", m.Size)
+	}
+	chunks := (m.Size + int64(m.ChunkSize) - 1) / int64(m.ChunkSize)
+	if int64(len(m.ChunkHashes)) != chunks {
+		return fmt.Errorf("%d empreintes pour %d chunks", len(m.ChunkHashes), chunks)
+	}
+	for i, hash := range m.ChunkHashes {
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha256.Size {
+			return fmt.Errorf("chunk %d: empreinte SHA-256 invalide %q", i, hash)
+		}
+	}
+	return nil
+}
+
+// VerifyChunk vérifie l'empreinte d'un chunk
+func (m *Manifest) VerifyChunk(i int, data []byte) error {
+	if i < 0 || i >= m.TotalChunks() {
+		return fmt.Errorf("chunk %d hors limites (%d chunks)", i, m.TotalChunks())
+	}
+	if int64(len(data)) != m.ChunkLength(i) {
+		return fmt.Errorf("chunk %d: %d octets reçus, %d attendus", i, len(data), m.ChunkLength(i))
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != m.ChunkHashes[i] {
+		return fmt.Errorf("chunk %d: empreinte invalide", i)
+	}
+	return nil
+}
+
+// Same indique si deux manifests décrivent exactement le même contenu
+func (m *Manifest) Same(other *Manifest) bool {
+	if other == nil || m.Size != other.Size || m.ChunkSize != other.ChunkSize ||
+		len(m.ChunkHashes) != len(other.ChunkHashes) {
+		return false
+	}
+	for i := range m.ChunkHashes {
+		if m.ChunkHashes[i] != other.ChunkHashes[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ============================================
+// SANTÉ (SERVEUR ET DAEMON)
+// ============================================
+
+// États d'un composant, du meilleur au pire
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded" // fonctionne, mais avec une limite (uploads refusés, hors ligne...)
+	HealthFail     = "fail"
+)
+
+// ComponentHealth est le résultat de la vérification d'un composant
+type ComponentHealth struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Message   string  `json:"message,omitempty"`
+}
+
+// HealthReport est la réponse de /health/live et /health/ready
+type HealthReport struct {
+	Status    string                     `json:"status"`
+	Checks    map[string]ComponentHealth `json:"checks,omitempty"`
+	CheckedAt time.Time                  `json:"checked_at"`
+}